@@ -3,6 +3,7 @@ package gosnmp
 import (
 	"code.google.com/p/biogo.store/llrb"
 	"sync"
+	"sync/atomic"
 )
 
 type TransactionProvider interface {
@@ -21,6 +22,21 @@ type Agent struct {
 	oidTreeLock sync.Mutex
 	oidTree     llrb.Tree
 	txnProvider TransactionProvider
+
+	// the usmStats counters, indexed by UsmErrorType
+	usmStats [UsmErrorType_DECRYPTION_ERROR + 1]uint32
+
+	vacmLock sync.Mutex
+	vacm     *Vacm
+	// the snmpUnknownContexts counter
+	unknownContexts uint32
+
+	communitiesLock       sync.Mutex
+	communities           map[string]*agentCommunity
+	authFailureTrapTarget *authenticationFailureTrapTarget
+	// the snmpInBadCommunityNames and snmpInBadCommunityUses counters
+	badCommunityNames uint32
+	badCommunityUses  uint32
 }
 
 func NewAgent(name string, maxTargets int, logger Logger, txnProvider TransactionProvider) *Agent {
@@ -30,52 +46,245 @@ func NewAgent(name string, maxTargets int, logger Logger, txnProvider Transactio
 func NewAgentWithPort(name string, maxTargets int, port int, logger Logger, txnProvider TransactionProvider) *Agent {
 	agent := new(Agent)
 	agent.incomingRequestProcessor = agent
+	agent.usmErrorProcessor = agent
+	agent.usmUsers = newUsmUserTable()
 	agent.oidTree = llrb.Tree{}
 	agent.txnProvider = txnProvider
-	agent.snmpContext.initContext(name, maxTargets, false, port, logger)
+	// the request tracker is needed for the acknowledgements of informs sent by the agent's notification originators
+	agent.snmpContext.initContext(name, maxTargets, true, port, logger)
 	return agent
 }
 
+// SetVacm sets the access control applied to requests. Until it's called, every request has full access to every handler,
+// except that SNMPv3 requests are refused unless they're sent at the highest security level their user supports.
+func (agent *Agent) SetVacm(vacm *Vacm) {
+	agent.vacmLock.Lock()
+	defer agent.vacmLock.Unlock()
+	agent.vacm = vacm
+}
+
+func (agent *Agent) getVacm() *Vacm {
+	agent.vacmLock.Lock()
+	defer agent.vacmLock.Unlock()
+	return agent.vacm
+}
+
 func (agent *Agent) processCommunityRequest(req *communityRequest) {
 	resp := req.createResponse()
+	if req.version == Version3 {
+		engine := agent.getLocalEngine()
+		if engine == nil {
+			// SNMPv3 hasn't been enabled, so the request couldn't have been authenticated
+			return
+		}
+		resp.v3.engineBoots, resp.v3.engineTime = engine.current()
+	} else if !agent.authenticateCommunity(req, resp) {
+		return
+	}
+	switch req.pduType {
+	case pduType_GET_REQUEST:
+		agent.processGetRequest(req, resp)
+	case pduType_GET_NEXT_REQUEST:
+		agent.processGetNextRequest(req, resp)
+	case pduType_GET_BULK_REQUEST:
+		agent.processGetBulkRequest(req, resp)
+	case pduType_SET_REQUEST:
+		agent.processSetRequest(req, resp)
+	}
+}
+
+// processGetRequest answers a GetRequest with the value of each of the request's oids. Oids that aren't in the request's
+// view, or that no handler serves, are answered with a noSuchObject exception.
+func (agent *Agent) processGetRequest(req *communityRequest, resp *communityResponse) {
+	inView, ok := agent.checkAccess(req, resp, ViewType_READ)
+	if !ok {
+		return
+	}
 	txn := agent.txnProvider.StartTxn()
 	if txn == nil {
-		resp.errorVal = SnmpRequestErrorType_RESOURCE_UNAVAILABLE
-		resp.errorIdx = 1
+		agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, 1)
+		return
 	}
-	for _, requestVb := range req.varbinds {
-		node := agent.lookupHandler(requestVb.GetOid())
+	defer agent.txnProvider.AbortTxn(txn)
+	for i, requestVb := range req.varbinds {
+		var node *oidTreeNode
+		if inView[i] {
+			node = agent.lookupHandler(requestVb.GetOid())
+		}
 		if node == nil {
 			resp.AddVarbind(NewNoSuchObjectVarbind(requestVb.GetOid()))
 			continue
 		}
-		switch req.pduType {
-		case pduType_GET_REQUEST:
-			responseVb, err := node.handler.Get(requestVb.GetOid(), txn)
-			if err != nil {
-				continue
-			}
-			resp.AddVarbind(responseVb)
+		responseVb, err := node.handler.Get(requestVb.GetOid(), txn)
+		if err != nil {
+			agent.Debugf("Agent %s: Get for %v failed - err: %s", agent.name, requestVb.GetOid(), err)
+			agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, i+1)
+			return
+		}
+		resp.AddVarbind(responseVb)
+	}
+	agent.respond(req, resp)
+}
 
-		case pduType_SET_REQUEST:
-			responseVb, err := node.handler.Set(requestVb, txn)
-			if err != nil {
-				continue
-			}
-			resp.AddVarbind(responseVb)
+// processGetNextRequest answers a GetNextRequest with the first instance after each of the request's oids, searching the
+// agent's handlers in lexicographic order. Instances outside the request's view are skipped over.
+func (agent *Agent) processGetNextRequest(req *communityRequest, resp *communityResponse) {
+	view, ok := agent.requestView(req, resp, ViewType_READ)
+	if !ok {
+		return
+	}
+	txn := agent.txnProvider.StartTxn()
+	if txn == nil {
+		agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, 1)
+		return
+	}
+	defer agent.txnProvider.AbortTxn(txn)
+	for i, requestVb := range req.varbinds {
+		responseVb, err := agent.newMibCursor(requestVb.GetOid(), view, txn).next()
+		if err != nil {
+			agent.Debugf("Agent %s: GetNext for %v failed - err: %s", agent.name, requestVb.GetOid(), err)
+			agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, i+1)
+			return
+		}
+		resp.AddVarbind(responseVb)
+	}
+	agent.respond(req, resp)
+}
 
+// checkAccess applies the agent's access control to each of the request's varbinds. It returns whether each varbind is in
+// the request's view. If the request is refused outright, the refusal is sent and checkAccess returns false.
+func (agent *Agent) checkAccess(req *communityRequest, resp *communityResponse, viewType ViewType) ([]bool, bool) {
+	view, ok := agent.requestView(req, resp, viewType)
+	if !ok {
+		return nil, false
+	}
+	inView := make([]bool, len(req.varbinds))
+	for i, vb := range req.varbinds {
+		inView[i] = view == nil || view.Contains(vb.GetOid())
+		// RFC 3413 section 3.2: oids outside a read view don't exist as far as the requester is concerned, but writing
+		// outside the write view is an error.
+		if !inView[i] && viewType == ViewType_WRITE {
+			agent.respondWithError(req, resp, SnmpRequestErrorType_NO_ACCESS, i+1)
+			return nil, false
 		}
 	}
+	return inView, true
+}
+
+// requestView finds the view that a request's oids are checked against, which is nil if the agent has no access control.
+// If the request doesn't have a view at all, the refusal is sent and requestView returns false.
+func (agent *Agent) requestView(req *communityRequest, resp *communityResponse, viewType ViewType) (*View, bool) {
+	model, securityName, level, contextName := requestSecurity(req)
+	vacm := agent.getVacm()
+	if vacm == nil {
+		// Without access control, anyone who knew a user's name could otherwise bypass its keys by sending noAuthNoPriv
+		// requests.
+		if req.version == Version3 && level < req.v3.user.securityLevel() {
+			agent.respondWithError(req, resp, SnmpRequestErrorType_AUTHORIZATION_ERROR, 1)
+			return nil, false
+		}
+		return nil, true
+	}
+	view, err := vacm.lookupView(model, securityName, level, contextName, viewType)
+	if err == nil {
+		return view, true
+	}
+	if err.(VacmError).Type == VacmErrorType_NO_SUCH_CONTEXT {
+		agent.reportUnknownContext(req)
+	} else {
+		agent.respondWithError(req, resp, SnmpRequestErrorType_AUTHORIZATION_ERROR, 1)
+	}
+	return nil, false
+}
+
+// requestSecurity returns the security parameters VACM needs for a request. For community based requests, the community
+// acts as the security name.
+func requestSecurity(req *communityRequest) (model SecurityModel, securityName string, level SecurityLevel, contextName string) {
+	switch req.version {
+	case Version1:
+		return SecurityModel_V1, req.community, SecurityLevel_NO_AUTH_NO_PRIV, ""
+	case Version2c:
+		return SecurityModel_V2C, req.community, SecurityLevel_NO_AUTH_NO_PRIV, ""
+	}
+	level = SecurityLevel_NO_AUTH_NO_PRIV
+	if req.v3.isEncrypted() {
+		level = SecurityLevel_AUTH_PRIV
+	} else if req.v3.isAuthenticated() {
+		level = SecurityLevel_AUTH_NO_PRIV
+	}
+	return SecurityModel_USM, req.v3.userName, level, req.v3.contextName
+}
+
+// respondWithError sends an error response, which carries the request's varbinds unchanged.
+func (agent *Agent) respondWithError(req *communityRequest, resp *communityResponse, errorVal SnmpRequestErrorType, errorIdx int) {
+	resp.errorVal = errorVal
+	resp.errorIdx = int32(errorIdx)
+	resp.varbinds = req.varbinds
+	agent.respond(req, resp)
+}
+
+func (agent *Agent) respond(req *communityRequest, resp *communityResponse) {
+	if req.version == Version1 {
+		convertToV1(req, resp)
+	}
 	agent.sendResponse(resp)
 }
 
+// convertToV1 turns a response into what RFC 3584 section 4.4 requires of an SNMPv1 agent. SNMPv2 error-status values are
+// replaced with their closest SNMPv1 equivalent, and exceptions in the varbinds become a noSuchName error.
+func convertToV1(req *communityRequest, resp *communityResponse) {
+	if resp.errorVal == SnmpRequestErrorType_NO_ERROR {
+		for i, vb := range resp.varbinds {
+			switch vb.(type) {
+			case *NoSuchObjectVarbind, *NoSuchInstanceVarbind, *EndOfMibViewVarbind:
+				resp.errorVal = SnmpRequestErrorType_NO_SUCH_NAME
+				resp.errorIdx = int32(i + 1)
+			}
+			if resp.errorVal != SnmpRequestErrorType_NO_ERROR {
+				break
+			}
+		}
+	}
+	switch resp.errorVal {
+	case SnmpRequestErrorType_NO_ERROR:
+		return
+	case SnmpRequestErrorType_NO_ACCESS, SnmpRequestErrorType_NOT_WRITABLE, SnmpRequestErrorType_NO_CREATION,
+		SnmpRequestErrorType_INCONSISTENT_NAME, SnmpRequestErrorType_AUTHORIZATION_ERROR:
+		resp.errorVal = SnmpRequestErrorType_NO_SUCH_NAME
+	case SnmpRequestErrorType_WRONG_TYPE, SnmpRequestErrorType_WRONG_LENGTH, SnmpRequestErrorType_WRONG_ENCODING,
+		SnmpRequestErrorType_WRONG_VALUE, SnmpRequestErrorType_INCONSISTENT_VALUE:
+		resp.errorVal = SnmpRequestErrorType_BAD_VALUE
+	case SnmpRequestErrorType_RESOURCE_UNAVAILABLE, SnmpRequestErrorType_COMMIT_FAILED, SnmpRequestErrorType_UNDO_FAILED:
+		resp.errorVal = SnmpRequestErrorType_GENERIC_ERROR
+	}
+	resp.varbinds = req.varbinds
+}
+
+// reportUnknownContext answers an SNMPv3 request for a context the agent doesn't have with an snmpUnknownContexts report,
+// sent with the same security as the request.
+func (agent *Agent) reportUnknownContext(req *communityRequest) {
+	count := atomic.AddUint32(&agent.unknownContexts, 1)
+	if req.v3.flags&v3MsgFlags_REPORTABLE == 0 {
+		return
+	}
+	report := newReport(Version3, req.requestId)
+	report.v3 = req.v3.forResponse()
+	report.v3.engineBoots, report.v3.engineTime = agent.getLocalEngine().current()
+	report.AddVarbind(NewCounter32Varbind(SNMP_UNKNOWN_CONTEXTS_OID, count))
+	report.setAddress(req.address)
+	agent.incrementStat(StatType_REPORTS_SENT)
+	agent.sendMessage(report)
+}
+
 func (agent *Agent) lookupHandler(oid ObjectIdentifier) *oidTreeNode {
 	agent.oidTreeLock.Lock()
 	defer agent.oidTreeLock.Unlock()
-	tnode := agent.oidTree.Ceil(oidTreeLookup(oid))
+	tnode := agent.oidTree.Floor(oidTreeLookup(oid))
 	if tnode == nil {
-		// This should only ever hit if no handlers have been added to this agent... Very much a corner case.
-		agent.Errorf("------ Agent %s, YOU APPEAR TO HAVE NO HANDLERS BOUND", agent.name)
+		if agent.oidTree.Len() == 0 {
+			// This should only ever hit if no handlers have been added to this agent... Very much a corner case.
+			agent.Errorf("------ Agent %s, YOU APPEAR TO HAVE NO HANDLERS BOUND", agent.name)
+		}
 		return nil
 	}
 	node := tnode.(*oidTreeNode)
@@ -89,6 +298,17 @@ func (agent *Agent) lookupHandler(oid ObjectIdentifier) *oidTreeNode {
 	return node
 }
 
+// handlerAfter returns the first handler registered at an oid that comes after the given oid, or nil if there isn't one.
+func (agent *Agent) handlerAfter(oid ObjectIdentifier) *oidTreeNode {
+	agent.oidTreeLock.Lock()
+	defer agent.oidTreeLock.Unlock()
+	tnode := agent.oidTree.Ceil(oidTreeSuccessor(oid))
+	if tnode == nil {
+		return nil
+	}
+	return tnode.(*oidTreeNode)
+}
+
 type oidHandler interface {
 	Get(oid ObjectIdentifier, txn interface{}) (Varbind, error)
 	Set(vb Varbind, txn interface{}) (Varbind, error)
@@ -98,6 +318,14 @@ type SingleVarOidHandler interface {
 	oidHandler
 }
 
+// MultiVarOidHandler is implemented by handlers that serve any number of instances in the subtree they're registered at.
+// GetNext returns the first instance in the subtree that comes after oid, or nil if there isn't one. oid may come before
+// the subtree, in which case the subtree's first instance should be returned.
+type MultiVarOidHandler interface {
+	oidHandler
+	GetNext(oid ObjectIdentifier, txn interface{}) (Varbind, error)
+}
+
 func (agent *Agent) RegisterSingleVarOidHandler(oid ObjectIdentifier, handler SingleVarOidHandler) error {
 	agent.oidTreeLock.Lock()
 	defer agent.oidTreeLock.Unlock()
@@ -105,6 +333,14 @@ func (agent *Agent) RegisterSingleVarOidHandler(oid ObjectIdentifier, handler Si
 	return nil
 }
 
+// RegisterMultiVarOidHandler registers a handler for every instance in the subtree at oid.
+func (agent *Agent) RegisterMultiVarOidHandler(oid ObjectIdentifier, handler MultiVarOidHandler) error {
+	agent.oidTreeLock.Lock()
+	defer agent.oidTreeLock.Unlock()
+	agent.oidTree.Insert(&oidTreeNode{oid, true, handler})
+	return nil
+}
+
 type oidTreeNode struct {
 	oid     ObjectIdentifier
 	isMulti bool
@@ -115,8 +351,56 @@ func (a *oidTreeNode) Compare(b llrb.Comparable) int {
 	return a.oid.Compare(b.(*oidTreeNode).oid)
 }
 
+// getNext returns the first instance served by the node's handler that comes after oid, or nil if there isn't one. A
+// single var handler serves just the instance at the node's oid.
+func (node *oidTreeNode) getNext(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	if node.isMulti {
+		return node.handler.(MultiVarOidHandler).GetNext(oid, txn)
+	}
+	if oid.Compare(node.oid) >= 0 {
+		return nil, nil
+	}
+	vb, err := node.handler.Get(node.oid, txn)
+	if err != nil {
+		return nil, err
+	}
+	switch vb.(type) {
+	case *NoSuchObjectVarbind, *NoSuchInstanceVarbind:
+		return nil, nil
+	}
+	return vb, nil
+}
+
 type oidTreeLookup ObjectIdentifier
 
 func (a oidTreeLookup) Compare(b llrb.Comparable) int {
 	return ObjectIdentifier(a).Compare(b.(*oidTreeNode).oid)
 }
+
+// oidTreeSuccessor finds the first node that comes strictly after an oid
+type oidTreeSuccessor ObjectIdentifier
+
+func (a oidTreeSuccessor) Compare(b llrb.Comparable) int {
+	if c := ObjectIdentifier(a).Compare(b.(*oidTreeNode).oid); c != 0 {
+		return c
+	}
+	return 1
+}
+
+// counter32Handler serves a counter maintained by the agent, such as snmpInBadCommunityNames or one of the usmStats
+// counters. The counter is read atomically, so the agent can update it from any goroutine.
+type counter32Handler struct {
+	oid     ObjectIdentifier
+	counter *uint32
+}
+
+func (handler *counter32Handler) Get(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	if oid.Compare(handler.oid) != 0 {
+		return NewNoSuchInstanceVarbindVarbind(oid), nil
+	}
+	return NewCounter32Varbind(oid, atomic.LoadUint32(handler.counter)), nil
+}
+
+func (handler *counter32Handler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	return nil, readOnlyObjectError{vb.GetOid()}
+}
@@ -0,0 +1,161 @@
+package gosnmp
+
+const (
+	// maxUdpPayload is the largest message that can be sent in a single UDP datagram
+	maxUdpPayload = 65507
+	// responseSizeSlack allows for the length fields around the varbinds list growing as varbinds are added to a
+	// response, and for the padding added when it's encrypted.
+	responseSizeSlack = 32
+)
+
+// mibCursor steps through the instances served by an agent's handlers in lexicographic order, skipping any that are
+// outside its view. It remembers the handler the last instance came from, so that stepping to the next instance doesn't
+// need another lookup in the oid tree until that handler's subtree is used up.
+type mibCursor struct {
+	agent *Agent
+	view  *View // nil if everything is in view
+	txn   interface{}
+	oid   ObjectIdentifier
+	node  *oidTreeNode
+	ended bool
+}
+
+func (agent *Agent) newMibCursor(oid ObjectIdentifier, view *View, txn interface{}) *mibCursor {
+	cursor := &mibCursor{agent: agent, view: view, txn: txn, oid: oid}
+	cursor.node = agent.lookupHandler(oid)
+	if cursor.node == nil {
+		cursor.node = agent.handlerAfter(oid)
+	}
+	return cursor
+}
+
+// next returns the first instance after the last one returned, or after the cursor's starting oid the first time it's
+// called. Once there are no instances left, it returns an endOfMibView exception for the last oid every time.
+func (cursor *mibCursor) next() (Varbind, error) {
+	for cursor.node != nil {
+		vb, err := cursor.node.getNext(cursor.oid, cursor.txn)
+		if err != nil {
+			return nil, err
+		}
+		if vb == nil || vb.GetOid().Compare(cursor.oid) <= 0 {
+			cursor.node = cursor.agent.handlerAfter(cursor.node.oid)
+			continue
+		}
+		cursor.oid = vb.GetOid()
+		if cursor.view == nil || cursor.view.Contains(cursor.oid) {
+			return vb, nil
+		}
+	}
+	cursor.ended = true
+	return NewEndOfMibViewVarbind(cursor.oid), nil
+}
+
+// processGetBulkRequest answers a GetBulkRequest as described in RFC 3416 section 4.2.3. Each of the first nonRepeaters
+// oids is answered as it would be by a GetNext, and up to maxRepetitions successors are returned for each of the
+// remaining oids. The response is cut short where adding another varbind would make it too big for the requester.
+func (agent *Agent) processGetBulkRequest(req *communityRequest, resp *communityResponse) {
+	if req.version == Version1 {
+		// SNMPv1 has no GetBulk
+		return
+	}
+	view, ok := agent.requestView(req, resp, ViewType_READ)
+	if !ok {
+		return
+	}
+	txn := agent.txnProvider.StartTxn()
+	if txn == nil {
+		agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, 1)
+		return
+	}
+	defer agent.txnProvider.AbortTxn(txn)
+	sizer, err := agent.newResponseSizer(req, resp)
+	if err != nil {
+		agent.Debugf("Agent %s: couldn't size GetBulk response - err: %s", agent.name, err)
+		agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, 0)
+		return
+	}
+	nonRepeaters := int(req.nonRepeaters)
+	if nonRepeaters < 0 {
+		nonRepeaters = 0
+	} else if nonRepeaters > len(req.varbinds) {
+		nonRepeaters = len(req.varbinds)
+	}
+	for i := 0; i < nonRepeaters; i++ {
+		vb, err := agent.newMibCursor(req.varbinds[i].GetOid(), view, txn).next()
+		if err != nil {
+			agent.Debugf("Agent %s: GetBulk for %v failed - err: %s", agent.name, req.varbinds[i].GetOid(), err)
+			agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, i+1)
+			return
+		}
+		if !sizer.add(vb) {
+			agent.respond(req, resp)
+			return
+		}
+	}
+	cursors := make([]*mibCursor, len(req.varbinds)-nonRepeaters)
+	for j := range cursors {
+		cursors[j] = agent.newMibCursor(req.varbinds[nonRepeaters+j].GetOid(), view, txn)
+	}
+	for repetition := int32(0); repetition < req.maxRepetitions && len(cursors) > 0; repetition++ {
+		allEnded := true
+		for j, cursor := range cursors {
+			vb, err := cursor.next()
+			if err != nil {
+				agent.Debugf("Agent %s: GetBulk for %v failed - err: %s", agent.name, cursor.oid, err)
+				agent.respondWithError(req, resp, SnmpRequestErrorType_GENERIC_ERROR, nonRepeaters+j+1)
+				return
+			}
+			if !sizer.add(vb) {
+				agent.respond(req, resp)
+				return
+			}
+			allEnded = allEnded && cursor.ended
+		}
+		if allEnded {
+			// every further repetition would just repeat the same endOfMibView exceptions
+			break
+		}
+	}
+	agent.respond(req, resp)
+}
+
+// responseSizer adds varbinds to a response for as long as the encoded response will still fit in a message the
+// requester can accept.
+type responseSizer struct {
+	agent     *Agent
+	resp      *communityResponse
+	remaining int
+}
+
+func (agent *Agent) newResponseSizer(req *communityRequest, resp *communityResponse) (*responseSizer, error) {
+	emptyResp, err := resp.encode(agent.berEncoderFactory)
+	if err != nil {
+		return nil, err
+	}
+	return &responseSizer{agent, resp, maxResponseSize(req) - len(emptyResp) - responseSizeSlack}, nil
+}
+
+// add adds the varbind to the response, and returns true, if there's room for it.
+func (sizer *responseSizer) add(vb Varbind) bool {
+	encoder := sizer.agent.berEncoderFactory.newberEncoder()
+	encodedLen, err := encoder.encodeVarbind(vb)
+	encoder.destroy()
+	if err != nil || encodedLen > sizer.remaining {
+		return false
+	}
+	sizer.remaining -= encodedLen
+	sizer.resp.AddVarbind(vb)
+	return true
+}
+
+// maxResponseSize returns the size of the largest response that the requester can accept. SNMPv3 requests carry the
+// requester's msgMaxSize. Community based requesters don't say, so they're assumed to use a buffer the same size as ours.
+func maxResponseSize(req *communityRequest) int {
+	if req.version != Version3 {
+		return v3MsgMaxSize
+	}
+	if req.v3.msgMaxSize > maxUdpPayload {
+		return maxUdpPayload
+	}
+	return int(req.v3.msgMaxSize)
+}
@@ -0,0 +1,127 @@
+package gosnmp
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// CommunityAccess is the access that an SNMPv1 or SNMPv2c request gets from its community.
+type CommunityAccess int
+
+const (
+	CommunityAccess_READ_ONLY  CommunityAccess = 0
+	CommunityAccess_READ_WRITE                 = 1
+)
+
+func (access CommunityAccess) String() string {
+	switch access {
+	case CommunityAccess_READ_ONLY:
+		return "read-only"
+	case CommunityAccess_READ_WRITE:
+		return "read-write"
+	default:
+		return "Unknown"
+	}
+}
+
+// agentCommunity is a community that the agent accepts requests from
+type agentCommunity struct {
+	access         CommunityAccess
+	allowedSources []*net.IPNet
+}
+
+func (community *agentCommunity) allows(addr *net.UDPAddr) bool {
+	if len(community.allowedSources) == 0 {
+		return true
+	}
+	for _, source := range community.allowedSources {
+		if source.Contains(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticationFailureTrapTarget is where the agent sends authenticationFailure traps
+type authenticationFailureTrapTarget struct {
+	community string
+	address   *net.UDPAddr
+}
+
+// AddCommunity makes the agent accept SNMPv1 and SNMPv2c requests with the given community. allowedSources is a list of
+// networks in CIDR form (e.g. "10.0.0.0/8"). If it isn't empty, requests using the community are only accepted from
+// addresses in those networks. Until AddCommunity is first called, requests are accepted with any community.
+//
+// Once communities have been added, requests with any other community, or from a source that isn't allowed, are dropped
+// and counted in snmpInBadCommunityNames. A SetRequest with a read-only community is refused with an authorizationError and
+// counted in snmpInBadCommunityUses. Both counters are served by the agent.
+func (agent *Agent) AddCommunity(community string, access CommunityAccess, allowedSources []string) error {
+	entry := &agentCommunity{access: access}
+	for _, source := range allowedSources {
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			return fmt.Errorf("Invalid source network for community %s: %s", community, err)
+		}
+		entry.allowedSources = append(entry.allowedSources, network)
+	}
+	agent.communitiesLock.Lock()
+	firstCommunity := agent.communities == nil
+	if firstCommunity {
+		agent.communities = make(map[string]*agentCommunity)
+	}
+	agent.communities[community] = entry
+	agent.communitiesLock.Unlock()
+	if firstCommunity {
+		agent.RegisterSingleVarOidHandler(SNMP_IN_BAD_COMMUNITY_NAMES_OID, &counter32Handler{SNMP_IN_BAD_COMMUNITY_NAMES_OID, &agent.badCommunityNames})
+		agent.RegisterSingleVarOidHandler(SNMP_IN_BAD_COMMUNITY_USES_OID, &counter32Handler{SNMP_IN_BAD_COMMUNITY_USES_OID, &agent.badCommunityUses})
+	}
+	return nil
+}
+
+// SetAuthenticationFailureTrapTarget makes the agent send an SNMPv2 authenticationFailure trap, using the given community,
+// to address every time it drops a request for having a bad community. Passing a nil address stops the traps.
+func (agent *Agent) SetAuthenticationFailureTrapTarget(community string, address *net.UDPAddr) {
+	agent.communitiesLock.Lock()
+	defer agent.communitiesLock.Unlock()
+	if address == nil {
+		agent.authFailureTrapTarget = nil
+		return
+	}
+	agent.authFailureTrapTarget = &authenticationFailureTrapTarget{community, address}
+}
+
+// authenticateCommunity checks the community of an SNMPv1 or SNMPv2c request, as described in RFC 3584 section 5.2.1.
+// Requests that fail are dropped, so it returns false without responding. A read-only community used for a SetRequest
+// gets an error response, and authenticateCommunity returns false once that's been sent.
+func (agent *Agent) authenticateCommunity(req *communityRequest, resp *communityResponse) bool {
+	agent.communitiesLock.Lock()
+	communities := agent.communities
+	entry := communities[req.community]
+	trapTarget := agent.authFailureTrapTarget
+	agent.communitiesLock.Unlock()
+	if communities == nil {
+		return true
+	}
+	if entry == nil || !entry.allows(req.address) {
+		atomic.AddUint32(&agent.badCommunityNames, 1)
+		agent.Debugf("Agent %s: dropping request from %s with bad community", agent.name, req.address)
+		if trapTarget != nil {
+			agent.sendAuthenticationFailureTrap(trapTarget)
+		}
+		return false
+	}
+	if req.pduType == pduType_SET_REQUEST && entry.access != CommunityAccess_READ_WRITE {
+		atomic.AddUint32(&agent.badCommunityUses, 1)
+		agent.respondWithError(req, resp, SnmpRequestErrorType_AUTHORIZATION_ERROR, 1)
+		return false
+	}
+	return true
+}
+
+func (agent *Agent) sendAuthenticationFailureTrap(target *authenticationFailureTrapTarget) {
+	trap := newV2Trap(Version2c, agent.sysUpTime(), AUTHENTICATION_FAILURE_TRAP_OID)
+	trap.setCommunity(target.community)
+	trap.setAddress(target.address)
+	agent.sendTrap(trap)
+}
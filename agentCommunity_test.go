@@ -0,0 +1,93 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+	"sync/atomic"
+)
+
+func SetupAgentCommunityTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Agent communities", func() {
+		var (
+			agent      *Agent
+			clientCtxt *ClientContext
+		)
+		BeforeEach(func() {
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2168, logger, new(testTxnProvider))
+			agent.RegisterSingleVarOidHandler(SYS_DESCR_OID, &testStringHandler{val: "community agent"})
+			agent.RegisterSingleVarOidHandler(SYS_NAME_OID, &testStringHandler{val: "name", writable: true})
+			Ω(agent.AddCommunity("public", CommunityAccess_READ_ONLY, nil)).Should(BeNil())
+			Ω(agent.AddCommunity("private", CommunityAccess_READ_WRITE, []string{"127.0.0.0/8"})).Should(BeNil())
+			Ω(agent.AddCommunity("remote", CommunityAccess_READ_WRITE, []string{"192.0.2.0/24"})).Should(BeNil())
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			agent.Shutdown()
+		})
+		newClient := func(community string) *V2cClient {
+			client, err := clientCtxt.NewV2cClientWithPort(community, "localhost", 2168)
+			Ω(err).Should(BeNil())
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+			return client
+		}
+		get := func(client *V2cClient, oid ObjectIdentifier) V2cGetRequest {
+			req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{oid})
+			client.SendRequest(req)
+			return req
+		}
+		set := func(client *V2cClient, oid ObjectIdentifier, val string) V2cSetRequest {
+			req := clientCtxt.AllocateV2cSetRequest()
+			req.AddVarbind(NewStringVarbind(oid, val))
+			client.SendRequest(req)
+			return req
+		}
+
+		It("should reject invalid source networks", func() {
+			Ω(agent.AddCommunity("bad", CommunityAccess_READ_ONLY, []string{"10.0.0.1"})).ShouldNot(BeNil())
+		})
+		It("should serve requests with a configured community", func() {
+			req := get(newClient("public"), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(string(req.Response().Varbinds()[0].(*OctetStringVarbind).Value)).Should(Equal("community agent"))
+		})
+		It("should drop requests with an unknown community", func() {
+			req := get(newClient("secret"), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeAssignableToTypeOf(TimeoutError{}))
+			Ω(atomic.LoadUint32(&agent.badCommunityNames)).Should(Equal(uint32(1)))
+			req = get(newClient("public"), SNMP_IN_BAD_COMMUNITY_NAMES_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().Varbinds()[0].(*Counter32Varbind).Value).Should(Equal(uint32(1)))
+		})
+		It("should drop requests from sources that aren't allowed to use the community", func() {
+			req := get(newClient("remote"), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeAssignableToTypeOf(TimeoutError{}))
+			Ω(atomic.LoadUint32(&agent.badCommunityNames)).Should(Equal(uint32(1)))
+		})
+		It("should refuse sets with a read-only community", func() {
+			req := set(newClient("public"), SYS_NAME_OID, "new name")
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_AUTHORIZATION_ERROR)))
+			Ω(atomic.LoadUint32(&agent.badCommunityUses)).Should(Equal(uint32(1)))
+		})
+		It("should allow sets with a read-write community", func() {
+			req := set(newClient("private"), SYS_NAME_OID, "new name")
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ERROR)))
+		})
+		It("should send an authenticationFailure trap when configured to", func(done Done) {
+			receiver := NewTrapReceiver(<-testIdGenerator, 10, 2169, logger)
+			defer receiver.Shutdown()
+			receiverAddr, _ := net.ResolveUDPAddr("udp", "localhost:2169")
+			agent.SetAuthenticationFailureTrapTarget("traps", receiverAddr)
+			get(newClient("secret"), SYS_DESCR_OID)
+			trap := (<-receiver.Traps()).(*V2Trap)
+			Ω(trap.TrapOid()).Should(Equal(AUTHENTICATION_FAILURE_TRAP_OID))
+			Ω(trap.community).Should(Equal("traps"))
+			close(done)
+		}, 3)
+	})
+}
@@ -0,0 +1,719 @@
+package gosnmp
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// The columns of snmpTargetAddrTable
+const (
+	snmpTargetAddrTDomain     = 2
+	snmpTargetAddrTAddress    = 3
+	snmpTargetAddrTimeout     = 4
+	snmpTargetAddrRetryCount  = 5
+	snmpTargetAddrTagList     = 6
+	snmpTargetAddrParams      = 7
+	snmpTargetAddrStorageType = 8
+	snmpTargetAddrRowStatus   = 9
+)
+
+// The columns of snmpTargetParamsTable
+const (
+	snmpTargetParamsMPModel       = 2
+	snmpTargetParamsSecurityModel = 3
+	snmpTargetParamsSecurityName  = 4
+	snmpTargetParamsSecurityLevel = 5
+	snmpTargetParamsStorageType   = 6
+	snmpTargetParamsRowStatus     = 7
+)
+
+// The columns of snmpNotifyTable
+const (
+	snmpNotifyTag         = 2
+	snmpNotifyType        = 3
+	snmpNotifyStorageType = 4
+	snmpNotifyRowStatus   = 5
+)
+
+// The columns of snmpNotifyFilterTable
+const (
+	snmpNotifyFilterMask        = 2
+	snmpNotifyFilterType        = 3
+	snmpNotifyFilterStorageType = 4
+	snmpNotifyFilterRowStatus   = 5
+)
+
+// The StorageType values from SNMPv2-TC that the tables use
+const (
+	storageType_VOLATILE  = 2
+	storageType_READ_ONLY = 5
+)
+
+// The names of the rows of snmpNotifyTable, which are also the tags that select them
+const (
+	notifyTag_TRAP   = "trap"
+	notifyTag_INFORM = "inform"
+)
+
+type notificationMibError struct {
+	errorVal SnmpRequestErrorType
+	details  string
+}
+
+func (e notificationMibError) Error() string {
+	return e.details
+}
+
+func (e notificationMibError) ErrorStatus() SnmpRequestErrorType {
+	return e.errorVal
+}
+
+// ServeNotificationTargets serves the originator's targets from the agent, in snmpTargetAddrTable and
+// snmpTargetParamsTable from SNMP-TARGET-MIB, along with snmpNotifyTable and snmpNotifyFilterTable from
+// SNMP-NOTIFICATION-MIB, as described in RFC 3413. Managers can then create, change and destroy targets, their params and
+// their filters with SETs, which take effect once the whole request has succeeded.
+//
+// The tables are a view of the originator's configuration, so their layout is simpler than the MIBs allow:
+//   - snmpNotifyTable has two fixed rows, "trap" and "inform", each tagged with its own name. A target's
+//     snmpTargetAddrTagList is either "inform", for targets that are sent informs, or "trap".
+//   - The filter profile of a target is the target's name, so snmpNotifyFilterProfileTable isn't served. Filters are
+//     always active, so their rows can't be created with createAndWait or taken out of service.
+//   - Every target uses snmpUDPDomain with an IPv4 address, and every row is volatile.
+func (agent *Agent) ServeNotificationTargets(originator *NotificationOriginator) error {
+	mib := &notificationMib{originator: originator}
+	if err := agent.RegisterTableHandler(SNMP_TARGET_ADDR_ENTRY_OID, targetAddrTable{mib}); err != nil {
+		return err
+	}
+	if err := agent.RegisterTableHandler(SNMP_TARGET_PARAMS_ENTRY_OID, targetParamsTable{mib}); err != nil {
+		return err
+	}
+	if err := agent.RegisterTableHandler(SNMP_NOTIFY_ENTRY_OID, notifyTable{}); err != nil {
+		return err
+	}
+	return agent.RegisterTableHandler(SNMP_NOTIFY_FILTER_ENTRY_OID, notifyFilterTable{mib})
+}
+
+// notificationConfig holds an originator's targets and params, or a copy of them.
+type notificationConfig struct {
+	targets []NotificationTarget
+	params  map[string]TargetParams
+}
+
+// config returns a copy of the originator's targets and params, with copies of their filters.
+func (originator *NotificationOriginator) config() *notificationConfig {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	config := &notificationConfig{append([]NotificationTarget{}, originator.targets...), make(map[string]TargetParams)}
+	for i := range config.targets {
+		if config.targets[i].Filter != nil {
+			config.targets[i].Filter = config.targets[i].Filter.copy()
+		}
+	}
+	for name, params := range originator.params {
+		config.params[name] = params
+	}
+	return config
+}
+
+// applyConfig makes the originator's targets and params with the given names the same as in config, adding or removing
+// them as necessary. The rest of the originator's targets and params are left as they are.
+func (originator *NotificationOriginator) applyConfig(config *notificationConfig, targetNames map[string]bool, paramsNames map[string]bool) {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	changedTargets := make(map[string]NotificationTarget)
+	for _, target := range config.targets {
+		if targetNames[target.Name] {
+			changedTargets[target.Name] = target
+		}
+	}
+	targets := originator.targets[:0:0]
+	for _, target := range originator.targets {
+		if targetNames[target.Name] {
+			changedTarget, ok := changedTargets[target.Name]
+			if !ok {
+				continue
+			}
+			target = changedTarget
+			delete(changedTargets, target.Name)
+		}
+		targets = append(targets, target)
+	}
+	for _, target := range config.targets {
+		if _, ok := changedTargets[target.Name]; ok {
+			targets = append(targets, target)
+		}
+	}
+	originator.targets = targets
+	for name := range paramsNames {
+		if params, ok := config.params[name]; ok {
+			originator.params[name] = params
+		} else {
+			delete(originator.params, name)
+		}
+	}
+}
+
+// target returns the target with the given name, or nil if there isn't one. Targets without names can't be found.
+func (config *notificationConfig) target(name string) *NotificationTarget {
+	if name == "" {
+		return nil
+	}
+	for i := range config.targets {
+		if config.targets[i].Name == name {
+			return &config.targets[i]
+		}
+	}
+	return nil
+}
+
+// notificationMib holds the state shared by the tables served by ServeNotificationTargets. The changes made by a SET are
+// made to a staged copy of the originator's configuration. If the request is committed, the targets and params whose rows
+// it changed are copied to the originator, so that changes made to the originator's other targets and params while the
+// SET was being processed are kept.
+type notificationMib struct {
+	originator *NotificationOriginator
+	staged     *notificationConfig
+	// the names of the targets and params whose rows the current request changes
+	changedTargets map[string]bool
+	changedParams  map[string]bool
+	// the filter rows created by the current request, which are notReady until CompleteSetRequest makes them active
+	createdFilters map[string]bool
+}
+
+// config returns the configuration that the current request sees, along with a function that must be called once the
+// caller is done with it. Outside a SET, that's the originator's own configuration, which is locked until then, and
+// mustn't be changed.
+func (mib *notificationMib) config() (*notificationConfig, func()) {
+	if mib.staged != nil {
+		return mib.staged, func() {}
+	}
+	mib.originator.lock.Lock()
+	return &notificationConfig{mib.originator.targets, mib.originator.params}, mib.originator.lock.Unlock
+}
+
+// stage returns the configuration that the current request changes.
+func (mib *notificationMib) stage() *notificationConfig {
+	if mib.staged == nil {
+		mib.staged = mib.originator.config()
+		mib.changedTargets = make(map[string]bool)
+		mib.changedParams = make(map[string]bool)
+		mib.createdFilters = make(map[string]bool)
+	}
+	return mib.staged
+}
+
+// stageTarget returns the configuration that the current request changes, recording that it changes the named target.
+func (mib *notificationMib) stageTarget(name string) *notificationConfig {
+	config := mib.stage()
+	mib.changedTargets[name] = true
+	return config
+}
+
+// stageParams returns the configuration that the current request changes, recording that it changes the named params.
+func (mib *notificationMib) stageParams(name string) *notificationConfig {
+	config := mib.stage()
+	mib.changedParams[name] = true
+	return config
+}
+
+func (mib *notificationMib) SetRequestEnded(txn interface{}, committed bool) {
+	if committed && mib.staged != nil {
+		mib.originator.applyConfig(mib.staged, mib.changedTargets, mib.changedParams)
+	}
+	mib.staged = nil
+	mib.changedTargets = nil
+	mib.changedParams = nil
+	mib.createdFilters = nil
+}
+
+// snmpAdminStringIndex is the index of the tables indexed by an IMPLIED SnmpAdminString.
+var snmpAdminStringIndex = []IndexSpec{{Type: IndexType_OCTET_STRING, Implied: true}}
+
+func integerValue(vb Varbind, min int32, max int32) (int32, error) {
+	intVb, ok := vb.(*IntegerVarbind)
+	if !ok {
+		return 0, notificationMibError{SnmpRequestErrorType_WRONG_TYPE, fmt.Sprintf("%v can't be set to a %T", vb.GetOid(), vb)}
+	}
+	if intVb.Value < min || intVb.Value > max {
+		return 0, notificationMibError{SnmpRequestErrorType_WRONG_VALUE, fmt.Sprintf("%v can't be set to %d", vb.GetOid(), intVb.Value)}
+	}
+	return intVb.Value, nil
+}
+
+func octetStringValue(vb Varbind, minLen int, maxLen int) ([]byte, error) {
+	stringVb, ok := vb.(*OctetStringVarbind)
+	if !ok {
+		return nil, notificationMibError{SnmpRequestErrorType_WRONG_TYPE, fmt.Sprintf("%v can't be set to a %T", vb.GetOid(), vb)}
+	}
+	if len(stringVb.Value) < minLen || len(stringVb.Value) > maxLen {
+		return nil, notificationMibError{SnmpRequestErrorType_WRONG_LENGTH,
+			fmt.Sprintf("%v can't be set to a %d byte string", vb.GetOid(), len(stringVb.Value))}
+	}
+	return stringVb.Value, nil
+}
+
+// validateStorageType accepts SETs of a StorageType column, as long as they don't ask for anything but volatile storage.
+func validateStorageType(vb Varbind) error {
+	_, err := integerValue(vb, storageType_VOLATILE, storageType_VOLATILE)
+	return err
+}
+
+// targetAddrTable serves snmpTargetAddrTable, with a row for each of the originator's named targets.
+type targetAddrTable struct {
+	*notificationMib
+}
+
+func (table targetAddrTable) Index() []IndexSpec {
+	return snmpAdminStringIndex
+}
+
+func (table targetAddrTable) Columns() []uint32 {
+	return []uint32{snmpTargetAddrTDomain, snmpTargetAddrTAddress, snmpTargetAddrTimeout, snmpTargetAddrRetryCount,
+		snmpTargetAddrTagList, snmpTargetAddrParams, snmpTargetAddrStorageType, snmpTargetAddrRowStatus}
+}
+
+func (table targetAddrTable) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	config, done := table.config()
+	defer done()
+	var indexes []TableIndex
+	for _, target := range config.targets {
+		if target.Name != "" {
+			indexes = append(indexes, TableIndex{target.Name})
+		}
+	}
+	return nextIndex(index, indexes, snmpAdminStringIndex)
+}
+
+func (table targetAddrTable) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	config, done := table.config()
+	defer done()
+	target := config.target(string(index[0].([]byte)))
+	if target == nil {
+		return nil, nil
+	}
+	switch column {
+	case snmpTargetAddrTDomain:
+		return NewObjectIdentifierVarbind(nil, SNMP_UDP_DOMAIN_OID), nil
+	case snmpTargetAddrTAddress:
+		if target.Address == nil || target.Address.IP.To4() == nil {
+			return nil, nil
+		}
+		return NewOctetStringVarbind(nil, append(append([]byte{}, target.Address.IP.To4()...),
+			byte(target.Address.Port>>8), byte(target.Address.Port))), nil
+	case snmpTargetAddrTimeout:
+		centiseconds := configuredTimeout(target.Timeout, target.TimeoutSeconds) / (10 * time.Millisecond)
+		if centiseconds > math.MaxInt32 {
+			centiseconds = math.MaxInt32
+		}
+		return NewIntegerVarbind(nil, int32(centiseconds)), nil
+	case snmpTargetAddrRetryCount:
+		return NewIntegerVarbind(nil, int32(target.Retries)), nil
+	case snmpTargetAddrTagList:
+		if target.Inform {
+			return NewStringVarbind(nil, notifyTag_INFORM), nil
+		}
+		return NewStringVarbind(nil, notifyTag_TRAP), nil
+	case snmpTargetAddrParams:
+		return NewStringVarbind(nil, target.Params), nil
+	case snmpTargetAddrStorageType:
+		return NewIntegerVarbind(nil, storageType_VOLATILE), nil
+	case snmpTargetAddrRowStatus:
+		if target.status == 0 {
+			return NewIntegerVarbind(nil, int32(RowStatus_ACTIVE)), nil
+		}
+		return NewIntegerVarbind(nil, int32(target.status)), nil
+	}
+	return nil, nil
+}
+
+func (table targetAddrTable) ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	switch column {
+	case snmpTargetAddrTDomain:
+		oidVb, ok := vb.(*ObjectIdentifierVarbind)
+		if !ok {
+			return notificationMibError{SnmpRequestErrorType_WRONG_TYPE, fmt.Sprintf("snmpTargetAddrTDomain can't be set to a %T", vb)}
+		}
+		if !oidVb.Value.Equal(SNMP_UDP_DOMAIN_OID) {
+			return notificationMibError{SnmpRequestErrorType_WRONG_VALUE, fmt.Sprintf("Unsupported transport domain %v", oidVb.Value)}
+		}
+		return nil
+	case snmpTargetAddrTAddress:
+		_, err := octetStringValue(vb, 6, 6)
+		return err
+	case snmpTargetAddrTimeout:
+		_, err := integerValue(vb, 0, 1<<31-1)
+		return err
+	case snmpTargetAddrRetryCount:
+		_, err := integerValue(vb, 0, 255)
+		return err
+	case snmpTargetAddrTagList:
+		tagList, err := octetStringValue(vb, 0, 255)
+		if err == nil && string(tagList) != notifyTag_TRAP && string(tagList) != notifyTag_INFORM {
+			err = notificationMibError{SnmpRequestErrorType_WRONG_VALUE, fmt.Sprintf("Unsupported tag list %q", tagList)}
+		}
+		return err
+	case snmpTargetAddrParams:
+		_, err := octetStringValue(vb, 1, 32)
+		return err
+	case snmpTargetAddrStorageType:
+		return validateStorageType(vb)
+	}
+	return nil
+}
+
+func (table targetAddrTable) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	name := string(index[0].([]byte))
+	target := table.stageTarget(name).target(name)
+	switch column {
+	case snmpTargetAddrTAddress:
+		tAddress := vb.(*OctetStringVarbind).Value
+		target.Address = &net.UDPAddr{IP: net.IPv4(tAddress[0], tAddress[1], tAddress[2], tAddress[3]),
+			Port: int(tAddress[4])<<8 | int(tAddress[5])}
+	case snmpTargetAddrTimeout:
+		// snmpTargetAddrTimeout is in centiseconds
+		target.Timeout = time.Duration(vb.(*IntegerVarbind).Value) * 10 * time.Millisecond
+		target.TimeoutSeconds = 0
+	case snmpTargetAddrRetryCount:
+		target.Retries = int(vb.(*IntegerVarbind).Value)
+	case snmpTargetAddrTagList:
+		target.Inform = string(vb.(*OctetStringVarbind).Value) == notifyTag_INFORM
+	case snmpTargetAddrParams:
+		target.Params = string(vb.(*OctetStringVarbind).Value)
+	}
+	return nil
+}
+
+func (table targetAddrTable) RowStatusColumn() uint32 {
+	return snmpTargetAddrRowStatus
+}
+
+func (table targetAddrTable) RowReady(index TableIndex, txn interface{}) (bool, error) {
+	config, done := table.config()
+	defer done()
+	target := config.target(string(index[0].([]byte)))
+	return target.Address != nil && target.Params != "", nil
+}
+
+func (table targetAddrTable) SetRowStatus(index TableIndex, status RowStatus, txn interface{}) error {
+	name := string(index[0].([]byte))
+	config := table.stageTarget(name)
+	target := config.target(name)
+	switch {
+	case status == RowStatus_DESTROY:
+		targets := config.targets[:0:0]
+		for _, t := range config.targets {
+			if t.Name != name {
+				targets = append(targets, t)
+			}
+		}
+		config.targets = targets
+	case target == nil:
+		config.targets = append(config.targets, NotificationTarget{Name: name, TimeoutSeconds: defaultTargetTimeoutSeconds,
+			Retries: defaultTargetRetries, status: status})
+	default:
+		target.status = status
+	}
+	return nil
+}
+
+// targetParamsTable serves snmpTargetParamsTable, with a row for each of the originator's params. The security model
+// always follows the message processing model, so SETs of snmpTargetParamsSecurityModel are accepted but ignored.
+type targetParamsTable struct {
+	*notificationMib
+}
+
+func (table targetParamsTable) Index() []IndexSpec {
+	return snmpAdminStringIndex
+}
+
+func (table targetParamsTable) Columns() []uint32 {
+	return []uint32{snmpTargetParamsMPModel, snmpTargetParamsSecurityModel, snmpTargetParamsSecurityName,
+		snmpTargetParamsSecurityLevel, snmpTargetParamsStorageType, snmpTargetParamsRowStatus}
+}
+
+func (table targetParamsTable) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	config, done := table.config()
+	defer done()
+	var indexes []TableIndex
+	for name := range config.params {
+		indexes = append(indexes, TableIndex{name})
+	}
+	return nextIndex(index, indexes, snmpAdminStringIndex)
+}
+
+func (table targetParamsTable) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	config, done := table.config()
+	defer done()
+	params, ok := config.params[string(index[0].([]byte))]
+	if !ok {
+		return nil, nil
+	}
+	switch column {
+	case snmpTargetParamsMPModel:
+		return NewIntegerVarbind(nil, int32(params.Version)), nil
+	case snmpTargetParamsSecurityModel:
+		switch params.Version {
+		case Version1:
+			return NewIntegerVarbind(nil, SecurityModel_V1), nil
+		case Version2c:
+			return NewIntegerVarbind(nil, SecurityModel_V2C), nil
+		}
+		return NewIntegerVarbind(nil, SecurityModel_USM), nil
+	case snmpTargetParamsSecurityName:
+		return NewStringVarbind(nil, params.SecurityName), nil
+	case snmpTargetParamsSecurityLevel:
+		if params.SecurityLevel == 0 {
+			return NewIntegerVarbind(nil, int32(SecurityLevel_NO_AUTH_NO_PRIV)), nil
+		}
+		return NewIntegerVarbind(nil, int32(params.SecurityLevel)), nil
+	case snmpTargetParamsStorageType:
+		return NewIntegerVarbind(nil, storageType_VOLATILE), nil
+	case snmpTargetParamsRowStatus:
+		if params.status == 0 {
+			return NewIntegerVarbind(nil, int32(RowStatus_ACTIVE)), nil
+		}
+		return NewIntegerVarbind(nil, int32(params.status)), nil
+	}
+	return nil, nil
+}
+
+func (table targetParamsTable) ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	switch column {
+	case snmpTargetParamsMPModel:
+		mpModel, err := integerValue(vb, 0, 3)
+		if err == nil && SnmpVersion(mpModel) != Version1 && SnmpVersion(mpModel) != Version2c && SnmpVersion(mpModel) != Version3 {
+			err = notificationMibError{SnmpRequestErrorType_WRONG_VALUE, fmt.Sprintf("Unsupported message processing model %d", mpModel)}
+		}
+		return err
+	case snmpTargetParamsSecurityModel:
+		_, err := integerValue(vb, SecurityModel_V1, SecurityModel_USM)
+		return err
+	case snmpTargetParamsSecurityName:
+		_, err := octetStringValue(vb, 0, 255)
+		return err
+	case snmpTargetParamsSecurityLevel:
+		_, err := integerValue(vb, int32(SecurityLevel_NO_AUTH_NO_PRIV), SecurityLevel_AUTH_PRIV)
+		return err
+	case snmpTargetParamsStorageType:
+		return validateStorageType(vb)
+	}
+	return nil
+}
+
+func (table targetParamsTable) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	name := string(index[0].([]byte))
+	config := table.stageParams(name)
+	params := config.params[name]
+	switch column {
+	case snmpTargetParamsMPModel:
+		params.Version = SnmpVersion(vb.(*IntegerVarbind).Value)
+	case snmpTargetParamsSecurityName:
+		params.SecurityName = string(vb.(*OctetStringVarbind).Value)
+	case snmpTargetParamsSecurityLevel:
+		params.SecurityLevel = SecurityLevel(vb.(*IntegerVarbind).Value)
+	}
+	config.params[name] = params
+	return nil
+}
+
+func (table targetParamsTable) RowStatusColumn() uint32 {
+	return snmpTargetParamsRowStatus
+}
+
+func (table targetParamsTable) RowReady(index TableIndex, txn interface{}) (bool, error) {
+	config, done := table.config()
+	defer done()
+	return config.params[string(index[0].([]byte))].SecurityName != "", nil
+}
+
+func (table targetParamsTable) SetRowStatus(index TableIndex, status RowStatus, txn interface{}) error {
+	name := string(index[0].([]byte))
+	config := table.stageParams(name)
+	if status == RowStatus_DESTROY {
+		delete(config.params, name)
+		return nil
+	}
+	params, ok := config.params[name]
+	if !ok {
+		params = TargetParams{Version: Version2c, SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV}
+	}
+	params.status = status
+	config.params[name] = params
+	return nil
+}
+
+// notifyTable serves the fixed rows of snmpNotifyTable.
+type notifyTable struct{}
+
+func (table notifyTable) Index() []IndexSpec {
+	return snmpAdminStringIndex
+}
+
+func (table notifyTable) Columns() []uint32 {
+	return []uint32{snmpNotifyTag, snmpNotifyType, snmpNotifyStorageType, snmpNotifyRowStatus}
+}
+
+func (table notifyTable) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	return nextIndex(index, []TableIndex{{notifyTag_TRAP}, {notifyTag_INFORM}}, snmpAdminStringIndex)
+}
+
+func (table notifyTable) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	name := string(index[0].([]byte))
+	if name != notifyTag_TRAP && name != notifyTag_INFORM {
+		return nil, nil
+	}
+	switch column {
+	case snmpNotifyTag:
+		return NewStringVarbind(nil, name), nil
+	case snmpNotifyType:
+		if name == notifyTag_INFORM {
+			return NewIntegerVarbind(nil, 2), nil
+		}
+		return NewIntegerVarbind(nil, 1), nil
+	case snmpNotifyStorageType:
+		return NewIntegerVarbind(nil, storageType_READ_ONLY), nil
+	case snmpNotifyRowStatus:
+		return NewIntegerVarbind(nil, int32(RowStatus_ACTIVE)), nil
+	}
+	return nil, nil
+}
+
+func (table notifyTable) ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	return readOnlyObjectError{vb.GetOid()}
+}
+
+func (table notifyTable) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	return readOnlyObjectError{vb.GetOid()}
+}
+
+// notifyFilterTable serves snmpNotifyFilterTable, with a row for each family in the filters of the originator's named
+// targets. It's indexed by the target's name and the family's subtree.
+type notifyFilterTable struct {
+	*notificationMib
+}
+
+var notifyFilterIndex = []IndexSpec{{Type: IndexType_OCTET_STRING}, {Type: IndexType_OBJECT_IDENTIFIER, Implied: true}}
+
+func (table notifyFilterTable) Index() []IndexSpec {
+	return notifyFilterIndex
+}
+
+func (table notifyFilterTable) Columns() []uint32 {
+	return []uint32{snmpNotifyFilterMask, snmpNotifyFilterType, snmpNotifyFilterStorageType, snmpNotifyFilterRowStatus}
+}
+
+func (table notifyFilterTable) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	config, done := table.config()
+	defer done()
+	var indexes []TableIndex
+	for _, target := range config.targets {
+		if target.Name == "" || target.Filter == nil {
+			continue
+		}
+		for _, subtree := range target.Filter.subtrees() {
+			indexes = append(indexes, TableIndex{target.Name, subtree})
+		}
+	}
+	return nextIndex(index, indexes, notifyFilterIndex)
+}
+
+// filter returns the target that the filter row belongs to, and the row's subtree.
+func (table notifyFilterTable) filter(config *notificationConfig, index TableIndex) (*NotificationTarget, ObjectIdentifier) {
+	return config.target(string(index[0].([]byte))), index[1].(ObjectIdentifier)
+}
+
+func (table notifyFilterTable) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	config, done := table.config()
+	defer done()
+	target, subtree := table.filter(config, index)
+	if target == nil || target.Filter == nil {
+		return nil, nil
+	}
+	family := target.Filter.family(subtree)
+	if family == nil {
+		return nil, nil
+	}
+	switch column {
+	case snmpNotifyFilterMask:
+		return NewOctetStringVarbind(nil, family.mask), nil
+	case snmpNotifyFilterType:
+		if family.included {
+			return NewIntegerVarbind(nil, 1), nil
+		}
+		return NewIntegerVarbind(nil, 2), nil
+	case snmpNotifyFilterStorageType:
+		return NewIntegerVarbind(nil, storageType_VOLATILE), nil
+	case snmpNotifyFilterRowStatus:
+		if key, _ := EncodeIndex(index, notifyFilterIndex); table.createdFilters[indexKey(key)] {
+			return NewIntegerVarbind(nil, RowStatus_NOT_READY), nil
+		}
+		return NewIntegerVarbind(nil, int32(RowStatus_ACTIVE)), nil
+	}
+	return nil, nil
+}
+
+func (table notifyFilterTable) ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	switch column {
+	case snmpNotifyFilterMask:
+		_, err := octetStringValue(vb, 0, 16)
+		return err
+	case snmpNotifyFilterType:
+		_, err := integerValue(vb, 1, 2)
+		return err
+	case snmpNotifyFilterStorageType:
+		return validateStorageType(vb)
+	}
+	return nil
+}
+
+func (table notifyFilterTable) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	target, subtree := table.filter(table.stageTarget(string(index[0].([]byte))), index)
+	family := target.Filter.family(subtree)
+	switch column {
+	case snmpNotifyFilterMask:
+		family.mask = vb.(*OctetStringVarbind).Value
+	case snmpNotifyFilterType:
+		family.included = vb.(*IntegerVarbind).Value == 1
+	}
+	target.Filter.addFamily(subtree, family.mask, family.included)
+	return nil
+}
+
+func (table notifyFilterTable) RowStatusColumn() uint32 {
+	return snmpNotifyFilterRowStatus
+}
+
+func (table notifyFilterTable) RowReady(index TableIndex, txn interface{}) (bool, error) {
+	return true, nil
+}
+
+func (table notifyFilterTable) SetRowStatus(index TableIndex, status RowStatus, txn interface{}) error {
+	target, subtree := table.filter(table.stageTarget(string(index[0].([]byte))), index)
+	key, err := EncodeIndex(index, notifyFilterIndex)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case RowStatus_NOT_READY:
+		if target == nil {
+			return notificationMibError{SnmpRequestErrorType_INCONSISTENT_NAME, fmt.Sprintf("No notification target named %q", index[0])}
+		}
+		if target.Filter == nil {
+			target.Filter = new(View)
+		}
+		target.Filter.Include(subtree, nil)
+		table.createdFilters[indexKey(key)] = true
+	case RowStatus_ACTIVE:
+		delete(table.createdFilters, indexKey(key))
+	case RowStatus_DESTROY:
+		target.Filter.removeFamily(subtree)
+		if len(target.Filter.subtrees()) == 0 {
+			target.Filter = nil
+		}
+	default:
+		return notificationMibError{SnmpRequestErrorType_INCONSISTENT_VALUE, "Notification filters can't be taken out of service"}
+	}
+	return nil
+}
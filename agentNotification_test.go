@@ -0,0 +1,191 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func SetupAgentNotificationTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Agent notification targets", func() {
+		var (
+			agent        *Agent
+			originator   *NotificationOriginator
+			receiver     *TrapReceiver
+			receiverAddr *net.UDPAddr
+			clientCtxt   *ClientContext
+			client       *V2cClient
+		)
+		linkDownOid := ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 3}
+		linkUpOid := ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 4}
+		// receiverTAddress is snmpTargetAddrTAddress for the receiver: 127.0.0.1, port 2177
+		receiverTAddress := []byte{127, 0, 0, 1, 2177 >> 8, 2177 & 0xff}
+		addrOid := func(column uint32, name string) ObjectIdentifier {
+			oid, _ := EncodeIndex(TableIndex{name}, snmpAdminStringIndex)
+			return append(childOid(SNMP_TARGET_ADDR_ENTRY_OID, column), oid...)
+		}
+		paramsOid := func(column uint32, name string) ObjectIdentifier {
+			oid, _ := EncodeIndex(TableIndex{name}, snmpAdminStringIndex)
+			return append(childOid(SNMP_TARGET_PARAMS_ENTRY_OID, column), oid...)
+		}
+		filterOid := func(column uint32, name string, subtree ObjectIdentifier) ObjectIdentifier {
+			oid, _ := EncodeIndex(TableIndex{name, subtree}, notifyFilterIndex)
+			return append(childOid(SNMP_NOTIFY_FILTER_ENTRY_OID, column), oid...)
+		}
+		BeforeEach(func() {
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2176, logger, new(testTxnProvider))
+			originator = agent.NewNotificationOriginator()
+			Ω(agent.ServeNotificationTargets(originator)).Should(BeNil())
+			receiver = NewTrapReceiver(<-testIdGenerator, 10, 2177, logger)
+			receiverAddr, _ = net.ResolveUDPAddr("udp", "127.0.0.1:2177")
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2176)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			receiver.Shutdown()
+			agent.Shutdown()
+		})
+		set := func(varbinds ...Varbind) SnmpResponse {
+			req := clientCtxt.AllocateV2cSetRequest()
+			for _, vb := range varbinds {
+				req.(*communityRequest).AddVarbind(vb)
+			}
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response()
+		}
+		get := func(oid ObjectIdentifier) Varbind {
+			req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{oid})
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response().Varbinds()[0]
+		}
+		expectError := func(resp SnmpResponse, errorVal SnmpRequestErrorType, errorIdx int32) {
+			Ω(resp.ErrorVal()).Should(Equal(errorVal))
+			Ω(resp.ErrorIdx()).Should(Equal(errorIdx))
+		}
+
+		It("should serve the targets and params added to the originator", func() {
+			Ω(originator.SetTargetParams("v2c", TargetParams{Version: Version2c, SecurityName: "public"})).Should(BeNil())
+			Ω(originator.AddTarget(NotificationTarget{Name: "nms", Address: receiverAddr, Params: "v2c", Inform: true,
+				TimeoutSeconds: 2})).Should(BeNil())
+			Ω(get(addrOid(snmpTargetAddrTAddress, "nms")).(*OctetStringVarbind).Value).Should(Equal(receiverTAddress))
+			Ω(get(addrOid(snmpTargetAddrTimeout, "nms")).(*IntegerVarbind).Value).Should(Equal(int32(200)))
+			Ω(string(get(addrOid(snmpTargetAddrTagList, "nms")).(*OctetStringVarbind).Value)).Should(Equal("inform"))
+			Ω(get(addrOid(snmpTargetAddrRowStatus, "nms")).(*IntegerVarbind).Value).Should(Equal(int32(RowStatus_ACTIVE)))
+			Ω(get(paramsOid(snmpTargetParamsSecurityModel, "v2c")).(*IntegerVarbind).Value).Should(Equal(int32(SecurityModel_V2C)))
+			Ω(string(get(paramsOid(snmpTargetParamsSecurityName, "v2c")).(*OctetStringVarbind).Value)).Should(Equal("public"))
+		})
+		It("should keep the timeouts set in centiseconds", func() {
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_CREATE_AND_WAIT),
+				NewIntegerVarbind(addrOid(snmpTargetAddrTimeout, "nms"), 50)), SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(get(addrOid(snmpTargetAddrTimeout, "nms")).(*IntegerVarbind).Value).Should(Equal(int32(50)))
+			Ω(originator.Targets()[0].Timeout).Should(Equal(500 * time.Millisecond))
+		})
+		It("should send notifications to targets created with SETs", func(done Done) {
+			expectError(set(NewIntegerVarbind(paramsOid(snmpTargetParamsMPModel, "v1"), int32(Version1)),
+				NewStringVarbind(paramsOid(snmpTargetParamsSecurityName, "v1"), "public"),
+				NewIntegerVarbind(paramsOid(snmpTargetParamsRowStatus, "v1"), RowStatus_CREATE_AND_GO)), SnmpRequestErrorType_NO_ERROR, 0)
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_CREATE_AND_WAIT)),
+				SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(get(addrOid(snmpTargetAddrRowStatus, "nms")).(*IntegerVarbind).Value).Should(Equal(int32(RowStatus_NOT_READY)))
+			expectError(set(NewOctetStringVarbind(addrOid(snmpTargetAddrTAddress, "nms"), receiverTAddress),
+				NewStringVarbind(addrOid(snmpTargetAddrParams, "nms"), "v1"),
+				NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), int32(RowStatus_ACTIVE))), SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(originator.Targets()).Should(HaveLen(1))
+			Ω(originator.Targets()[0].Address.String()).Should(Equal(receiverAddr.String()))
+
+			originator.Notify(linkDownOid)
+			trap := (<-receiver.Traps()).(*V1Trap)
+			Ω(trap.GenericTrap()).Should(Equal(GenericTrapType(GenericTrapType_LINK_DOWN)))
+
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_DESTROY)), SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(originator.Targets()).Should(BeEmpty())
+			close(done)
+		}, 3)
+		It("should only send the notifications that pass a target's filter", func(done Done) {
+			Ω(originator.AddTarget(NotificationTarget{Name: "nms", Address: receiverAddr, Version: Version2c, Community: "public"})).Should(BeNil())
+			expectError(set(NewIntegerVarbind(filterOid(snmpNotifyFilterRowStatus, "nms", linkDownOid), RowStatus_CREATE_AND_GO)),
+				SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(get(filterOid(snmpNotifyFilterType, "nms", linkDownOid)).(*IntegerVarbind).Value).Should(Equal(int32(1)))
+			Ω(originator.Targets()[0].Filter.Contains(linkDownOid)).Should(BeTrue())
+
+			originator.Notify(linkUpOid)
+			originator.Notify(linkDownOid)
+			trap := (<-receiver.Traps()).(*V2Trap)
+			Ω(trap.TrapOid()).Should(Equal(linkDownOid))
+
+			expectError(set(NewIntegerVarbind(filterOid(snmpNotifyFilterRowStatus, "nms", linkDownOid), RowStatus_DESTROY)),
+				SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(originator.Targets()[0].Filter).Should(BeNil())
+			close(done)
+		}, 3)
+		It("should refuse filters that can't be created", func() {
+			expectError(set(NewIntegerVarbind(filterOid(snmpNotifyFilterRowStatus, "nobody", linkDownOid), RowStatus_CREATE_AND_GO)),
+				SnmpRequestErrorType_INCONSISTENT_NAME, 1)
+			Ω(originator.AddTarget(NotificationTarget{Name: "nms", Address: receiverAddr, Version: Version2c, Community: "public"})).Should(BeNil())
+			expectError(set(NewIntegerVarbind(filterOid(snmpNotifyFilterRowStatus, "nms", linkDownOid), RowStatus_CREATE_AND_WAIT)),
+				SnmpRequestErrorType_INCONSISTENT_VALUE, 1)
+			Ω(originator.Targets()[0].Filter).Should(BeNil())
+		})
+		It("should leave the originator alone when a SET fails", func() {
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_CREATE_AND_WAIT),
+				NewIntegerVarbind(filterOid(snmpNotifyFilterRowStatus, "nobody", linkDownOid), RowStatus_CREATE_AND_GO)),
+				SnmpRequestErrorType_INCONSISTENT_NAME, 2)
+			Ω(originator.Targets()).Should(BeEmpty())
+			Ω(get(addrOid(snmpTargetAddrRowStatus, "nms"))).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+		})
+		It("should keep the changes made to the originator while a SET is being processed", func() {
+			Ω(originator.AddTarget(NotificationTarget{Name: "old", Address: receiverAddr, Version: Version2c, Community: "public"})).Should(BeNil())
+			mib := &notificationMib{originator: originator}
+			name := TableIndex{[]byte("nms")}
+			Ω(targetAddrTable{mib}.SetRowStatus(name, RowStatus_CREATE_AND_WAIT, nil)).Should(BeNil())
+			Ω(targetParamsTable{mib}.SetRowStatus(TableIndex{[]byte("v1")}, RowStatus_CREATE_AND_WAIT, nil)).Should(BeNil())
+
+			originator.RemoveNamedTarget("old")
+			Ω(originator.AddTarget(NotificationTarget{Name: "new", Address: receiverAddr, Version: Version2c, Community: "public"})).Should(BeNil())
+			Ω(originator.SetTargetParams("v2c", TargetParams{Version: Version2c, SecurityName: "public"})).Should(BeNil())
+			mib.SetRequestEnded(nil, true)
+
+			targets := originator.Targets()
+			Ω(targets).Should(HaveLen(2))
+			Ω(targets[0].Name).Should(Equal("new"))
+			Ω(targets[1].Name).Should(Equal("nms"))
+			Ω(originator.params).Should(HaveKey("v1"))
+			Ω(originator.params).Should(HaveKey("v2c"))
+		})
+		It("should refuse values the tables don't support", func() {
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_CREATE_AND_WAIT),
+				NewStringVarbind(addrOid(snmpTargetAddrTagList, "nms"), "both")), SnmpRequestErrorType_WRONG_VALUE, 2)
+			expectError(set(NewIntegerVarbind(addrOid(snmpTargetAddrRowStatus, "nms"), RowStatus_CREATE_AND_WAIT),
+				NewStringVarbind(addrOid(snmpTargetAddrTAddress, "nms"), "localhost")), SnmpRequestErrorType_WRONG_LENGTH, 2)
+			expectError(set(NewStringVarbind(childOid(SNMP_NOTIFY_ENTRY_OID, snmpNotifyTag, 't', 'r', 'a', 'p'), "other")),
+				SnmpRequestErrorType_NOT_WRITABLE, 1)
+		})
+		It("should send SNMPv3 traps from the agent's engine", func(done Done) {
+			tempDir, err := ioutil.TempDir("", "gosnmp")
+			Ω(err).Should(BeNil())
+			defer os.RemoveAll(tempDir)
+			engineId := mustDecodeHex("80001f8880e9630000d61ff449")
+			Ω(agent.EnableV3(engineId, &FileEngineBootsStore{filepath.Join(tempDir, "engineBoots")})).Should(BeNil())
+			user, _ := NewUsmUser("notifier", AuthProtocol_NONE, "")
+			agent.AddUsmUser(user)
+			Ω(originator.AddTarget(NotificationTarget{Address: receiverAddr, Version: Version3})).ShouldNot(BeNil())
+			Ω(originator.AddTarget(NotificationTarget{Address: receiverAddr, Version: Version3, UserName: "notifier"})).Should(BeNil())
+			originator.Notify(linkDownOid)
+			trap := (<-receiver.Traps()).(*V2Trap)
+			Ω(trap.getVersion()).Should(Equal(SnmpVersion(Version3)))
+			Ω(trap.getV3Params().userName).Should(Equal("notifier"))
+			Ω(trap.getV3Params().engineId).Should(Equal(engineId))
+			close(done)
+		}, 3)
+	})
+}
@@ -0,0 +1,261 @@
+package gosnmp
+
+import "fmt"
+
+// RowStatus is the SNMPv2-TC RowStatus textual convention, used to create and delete the rows of writable tables, as
+// described in RFC 2579.
+type RowStatus int32
+
+const (
+	RowStatus_ACTIVE          RowStatus = 1
+	RowStatus_NOT_IN_SERVICE            = 2
+	RowStatus_NOT_READY                 = 3
+	RowStatus_CREATE_AND_GO             = 4
+	RowStatus_CREATE_AND_WAIT           = 5
+	RowStatus_DESTROY                   = 6
+)
+
+func (status RowStatus) String() string {
+	switch status {
+	case RowStatus_ACTIVE:
+		return "active"
+	case RowStatus_NOT_IN_SERVICE:
+		return "notInService"
+	case RowStatus_NOT_READY:
+		return "notReady"
+	case RowStatus_CREATE_AND_GO:
+		return "createAndGo"
+	case RowStatus_CREATE_AND_WAIT:
+		return "createAndWait"
+	case RowStatus_DESTROY:
+		return "destroy"
+	default:
+		return fmt.Sprintf("RowStatus(%d)", int32(status))
+	}
+}
+
+// RowStatusHandler can be implemented by a TableHandler whose rows are created and deleted through a RowStatus column.
+// The agent handles SETs of that column itself, following the state machine in RFC 2579, rather than passing them to
+// SetCell, so GetCell must return each row's status as an INTEGER, and nil for rows that don't exist. Rows are created,
+// and their status changed, in the request's transaction, and are never undone with UndoSetCell, so that a request that
+// creates a row and fills in its columns either succeeds completely or has no effect.
+type RowStatusHandler interface {
+	TableHandler
+	// RowStatusColumn returns the number of the table's RowStatus column.
+	RowStatusColumn() uint32
+	// RowReady reports whether a row has values for all of the columns it needs before it can be made active.
+	RowReady(index TableIndex, txn interface{}) (bool, error)
+	// SetRowStatus changes the status of a row to RowStatus_ACTIVE, RowStatus_NOT_IN_SERVICE or RowStatus_NOT_READY, or
+	// deletes it for RowStatus_DESTROY. A row that doesn't exist yet is created, with default values for its columns, when
+	// its status is set to RowStatus_NOT_READY. The handler may refuse a change, such as taking a row out of service,
+	// with a HandlerError.
+	SetRowStatus(index TableIndex, status RowStatus, txn interface{}) error
+}
+
+type rowStatusError struct {
+	errorVal SnmpRequestErrorType
+	details  string
+}
+
+func (e rowStatusError) Error() string {
+	return e.details
+}
+
+func (e rowStatusError) ErrorStatus() SnmpRequestErrorType {
+	return e.errorVal
+}
+
+// rowStatusOidHandler extends tableOidHandler for tables with a RowStatus column. It's a SetRequestHandler, since whether a
+// varbind can be set depends on whether another varbind in the request creates or destroys its row.
+type rowStatusOidHandler struct {
+	*tableOidHandler
+	rowStatus    RowStatusHandler
+	statusColumn uint32
+}
+
+// status returns the status of a row, or zero if it doesn't exist.
+func (t *rowStatusOidHandler) status(index TableIndex, txn interface{}) (RowStatus, error) {
+	vb, err := t.handler.GetCell(t.statusColumn, index, txn)
+	if err != nil || vb == nil {
+		return 0, err
+	}
+	intVb, ok := vb.(*IntegerVarbind)
+	if !ok {
+		return 0, fmt.Errorf("RowStatus of %v is a %T", index, vb)
+	}
+	return RowStatus(intVb.Value), nil
+}
+
+func (t *rowStatusOidHandler) ValidateSet(vb Varbind, txn interface{}) error {
+	column, _, ok := t.cell(vb.GetOid())
+	if !ok || column != t.statusColumn {
+		return t.tableOidHandler.ValidateSet(vb, txn)
+	}
+	intVb, ok := vb.(*IntegerVarbind)
+	if !ok {
+		return rowStatusError{SnmpRequestErrorType_WRONG_TYPE, fmt.Sprintf("RowStatus can't be set to a %T", vb)}
+	}
+	switch RowStatus(intVb.Value) {
+	case RowStatus_ACTIVE, RowStatus_NOT_IN_SERVICE, RowStatus_CREATE_AND_GO, RowStatus_CREATE_AND_WAIT, RowStatus_DESTROY:
+		return nil
+	}
+	return rowStatusError{SnmpRequestErrorType_WRONG_VALUE, fmt.Sprintf("RowStatus can't be set to %s", RowStatus(intVb.Value))}
+}
+
+// rowStatusChange is what a SET request does to one of the table's rows
+type rowStatusChange struct {
+	index     TableIndex
+	status    RowStatus // the status when the changes were worked out, zero if the row doesn't exist
+	requested RowStatus // the status the request sets, zero if it only sets other columns
+	position  int       // the position of the varbind that sets the status, or else the first varbind for the row
+}
+
+func (change *rowStatusChange) creates() bool {
+	return change.requested == RowStatus_CREATE_AND_GO || change.requested == RowStatus_CREATE_AND_WAIT
+}
+
+// rowStatusChanges works out what the varbinds do to each row, returning the changes in the order the rows appear in
+// varbinds. If validate is set, it stops at the first varbind that asks for something RFC 2579 doesn't allow, and returns
+// its position.
+func (t *rowStatusOidHandler) rowStatusChanges(varbinds []Varbind, validate bool, txn interface{}) ([]*rowStatusChange, int, error) {
+	var changes []*rowStatusChange
+	byIndex := make(map[string]*rowStatusChange)
+	for i, vb := range varbinds {
+		oid := vb.GetOid()
+		column, index, _ := t.cell(oid)
+		key := indexKey(oid[len(t.entryOid)+1:])
+		change := byIndex[key]
+		if change == nil {
+			status, err := t.status(index, txn)
+			if err != nil {
+				return nil, i, err
+			}
+			change = &rowStatusChange{index: index, status: status, position: i}
+			byIndex[key] = change
+			changes = append(changes, change)
+		}
+		if column != t.statusColumn {
+			if validate && change.requested == RowStatus_DESTROY {
+				return nil, i, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE, fmt.Sprintf("row %v is being destroyed", index)}
+			}
+			continue
+		}
+		if validate && change.requested != 0 {
+			return nil, i, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE, fmt.Sprintf("RowStatus of %v is set twice", index)}
+		}
+		change.requested = RowStatus(vb.(*IntegerVarbind).Value)
+		switch {
+		case !validate:
+		case change.creates() && change.status != 0:
+			return nil, i, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE, fmt.Sprintf("row %v already exists", index)}
+		case (change.requested == RowStatus_ACTIVE || change.requested == RowStatus_NOT_IN_SERVICE) && change.status == 0:
+			return nil, i, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE, fmt.Sprintf("row %v doesn't exist", index)}
+		case change.requested == RowStatus_DESTROY && change.position != i:
+			return nil, i, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE, fmt.Sprintf("row %v is being changed", index)}
+		}
+		change.position = i
+	}
+	return changes, 0, nil
+}
+
+func (t *rowStatusOidHandler) ValidateSetRequest(varbinds []Varbind, txn interface{}) (int, error) {
+	changes, i, err := t.rowStatusChanges(varbinds, true, txn)
+	if err != nil {
+		return i, err
+	}
+	for _, change := range changes {
+		if change.status == 0 && change.requested != RowStatus_DESTROY && !change.creates() {
+			// the row could be created, but only by setting its RowStatus
+			return change.position, rowStatusError{SnmpRequestErrorType_INCONSISTENT_NAME, fmt.Sprintf("row %v doesn't exist", change.index)}
+		}
+	}
+	return 0, nil
+}
+
+// Set creates or destroys the varbind's row when the varbind asks for it, or when it's the first of the row's varbinds to
+// be set in a request that creates the row. Any other change in status is made by CompleteSetRequest, once the row's
+// other columns have been set.
+func (t *rowStatusOidHandler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	column, index, ok := t.cell(vb.GetOid())
+	if !ok {
+		return nil, cellNotFoundError{vb.GetOid()}
+	}
+	status, err := t.status(index, txn)
+	if err != nil {
+		return nil, err
+	}
+	if column != t.statusColumn {
+		if status == 0 {
+			if err := t.rowStatus.SetRowStatus(index, RowStatus_NOT_READY, txn); err != nil {
+				return nil, err
+			}
+		}
+		return t.tableOidHandler.Set(vb, txn)
+	}
+	switch RowStatus(vb.(*IntegerVarbind).Value) {
+	case RowStatus_CREATE_AND_GO, RowStatus_CREATE_AND_WAIT:
+		if status == 0 {
+			err = t.rowStatus.SetRowStatus(index, RowStatus_NOT_READY, txn)
+		}
+	case RowStatus_DESTROY:
+		if status != 0 {
+			err = t.rowStatus.SetRowStatus(index, RowStatus_DESTROY, txn)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vb, nil
+}
+
+func (t *rowStatusOidHandler) UndoSet(vb Varbind, txn interface{}) error {
+	if column, _, _ := t.cell(vb.GetOid()); column == t.statusColumn {
+		// status changes are made in the transaction, and undone by aborting it
+		return nil
+	}
+	return t.tableOidHandler.UndoSet(vb, txn)
+}
+
+// CompleteSetRequest moves each row that the request touched to the status that was asked for, or to notInService if
+// it was notReady and now has all of the columns it needs.
+func (t *rowStatusOidHandler) CompleteSetRequest(varbinds []Varbind, txn interface{}) (int, error) {
+	changes, i, err := t.rowStatusChanges(varbinds, false, txn)
+	if err != nil {
+		return i, err
+	}
+	for _, change := range changes {
+		if change.status == 0 {
+			// rowStatusChanges saw the status after the varbinds were set, so this row was destroyed
+			continue
+		}
+		var newStatus RowStatus
+		switch change.requested {
+		case RowStatus_CREATE_AND_GO, RowStatus_ACTIVE:
+			newStatus = RowStatus_ACTIVE
+		case RowStatus_NOT_IN_SERVICE:
+			newStatus = RowStatus_NOT_IN_SERVICE
+		}
+		if change.status == RowStatus_NOT_READY {
+			ready, err := t.rowStatus.RowReady(change.index, txn)
+			if err != nil {
+				return change.position, err
+			}
+			if !ready {
+				if newStatus != 0 {
+					return change.position, rowStatusError{SnmpRequestErrorType_INCONSISTENT_VALUE,
+						fmt.Sprintf("row %v can't be made %s until all of its columns are set", change.index, newStatus)}
+				}
+				continue
+			}
+			if newStatus == 0 {
+				newStatus = RowStatus_NOT_IN_SERVICE
+			}
+		}
+		if newStatus != 0 && newStatus != change.status {
+			if err := t.rowStatus.SetRowStatus(change.index, newStatus, txn); err != nil {
+				return change.position, err
+			}
+		}
+	}
+	return 0, nil
+}
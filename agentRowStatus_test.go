@@ -0,0 +1,195 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sort"
+)
+
+type testRowStatusRow struct {
+	name   string // required before the row can be made active
+	status RowStatus
+}
+
+// testRowStatusTxnProvider keeps a table of rows indexed by an INTEGER. Each transaction works on a copy of the table,
+// which replaces it when the transaction is committed.
+type testRowStatusTxnProvider struct {
+	rows map[uint32]testRowStatusRow
+}
+
+func (provider *testRowStatusTxnProvider) StartTxn() interface{} {
+	rows := make(map[uint32]testRowStatusRow)
+	for id, row := range provider.rows {
+		rows[id] = row
+	}
+	return rows
+}
+
+func (provider *testRowStatusTxnProvider) CommitTxn(txn interface{}) bool {
+	provider.rows = txn.(map[uint32]testRowStatusRow)
+	return true
+}
+
+func (provider *testRowStatusTxnProvider) AbortTxn(interface{}) {}
+
+// testRowStatusTableHandler serves the table held by testRowStatusTxnProvider, with a name column (2) and a RowStatus
+// column (3).
+type testRowStatusTableHandler struct{}
+
+func (handler testRowStatusTableHandler) Index() []IndexSpec {
+	return []IndexSpec{{Type: IndexType_INTEGER}}
+}
+
+func (handler testRowStatusTableHandler) Columns() []uint32 {
+	return []uint32{2, 3}
+}
+
+func (handler testRowStatusTableHandler) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	var ids []int
+	for id := range txn.(map[uint32]testRowStatusRow) {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if index == nil || uint32(id) > index[0].(uint32) {
+			return TableIndex{uint32(id)}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (handler testRowStatusTableHandler) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	row, ok := txn.(map[uint32]testRowStatusRow)[index[0].(uint32)]
+	switch {
+	case !ok:
+		return nil, nil
+	case column == 2 && row.name != "":
+		return NewStringVarbind(nil, row.name), nil
+	case column == 3:
+		return NewIntegerVarbind(nil, int32(row.status)), nil
+	}
+	return nil, nil
+}
+
+func (handler testRowStatusTableHandler) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	rows := txn.(map[uint32]testRowStatusRow)
+	row := rows[index[0].(uint32)]
+	row.name = string(vb.(*OctetStringVarbind).Value)
+	rows[index[0].(uint32)] = row
+	return nil
+}
+
+func (handler testRowStatusTableHandler) RowStatusColumn() uint32 {
+	return 3
+}
+
+func (handler testRowStatusTableHandler) RowReady(index TableIndex, txn interface{}) (bool, error) {
+	return txn.(map[uint32]testRowStatusRow)[index[0].(uint32)].name != "", nil
+}
+
+func (handler testRowStatusTableHandler) SetRowStatus(index TableIndex, status RowStatus, txn interface{}) error {
+	rows := txn.(map[uint32]testRowStatusRow)
+	if status == RowStatus_DESTROY {
+		delete(rows, index[0].(uint32))
+		return nil
+	}
+	row := rows[index[0].(uint32)]
+	row.status = status
+	rows[index[0].(uint32)] = row
+	return nil
+}
+
+func SetupAgentRowStatusTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Agent RowStatus tables", func() {
+		var (
+			agent      *Agent
+			clientCtxt *ClientContext
+			client     *V2cClient
+		)
+		entryOid := ObjectIdentifier{1, 3, 6, 1, 4, 1, 424242, 3, 1}
+		nameOid := func(id uint32) ObjectIdentifier { return childOid(entryOid, 2, id) }
+		statusOid := func(id uint32) ObjectIdentifier { return childOid(entryOid, 3, id) }
+		BeforeEach(func() {
+			txnProvider := &testRowStatusTxnProvider{map[uint32]testRowStatusRow{1: {"existing", RowStatus_ACTIVE}}}
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2172, logger, txnProvider)
+			Ω(agent.RegisterTableHandler(entryOid, testRowStatusTableHandler{})).Should(BeNil())
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2172)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			agent.Shutdown()
+		})
+		set := func(varbinds ...Varbind) SnmpResponse {
+			req := clientCtxt.AllocateV2cSetRequest()
+			for _, vb := range varbinds {
+				req.(*communityRequest).AddVarbind(vb)
+			}
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response()
+		}
+		status := func(id uint32) Varbind {
+			req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{statusOid(id)})
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response().Varbinds()[0]
+		}
+		expectStatus := func(id uint32, rowStatus RowStatus) {
+			Ω(status(id).(*IntegerVarbind).Value).Should(Equal(int32(rowStatus)))
+		}
+		expectError := func(resp SnmpResponse, errorVal SnmpRequestErrorType, errorIdx int32) {
+			Ω(resp.ErrorVal()).Should(Equal(errorVal))
+			Ω(resp.ErrorIdx()).Should(Equal(errorIdx))
+		}
+
+		It("should create an active row with createAndGo, whatever order the varbinds are in", func() {
+			expectError(set(NewStringVarbind(nameOid(2), "two"), NewIntegerVarbind(statusOid(2), RowStatus_CREATE_AND_GO)),
+				SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(2, RowStatus_ACTIVE)
+			expectError(set(NewIntegerVarbind(statusOid(3), RowStatus_CREATE_AND_GO), NewStringVarbind(nameOid(3), "three")),
+				SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(3, RowStatus_ACTIVE)
+		})
+		It("should refuse createAndGo for a row that isn't ready, and create nothing", func() {
+			resp := set(NewIntegerVarbind(statusOid(2), RowStatus_CREATE_AND_GO), NewStringVarbind(nameOid(3), "three"),
+				NewIntegerVarbind(statusOid(3), RowStatus_CREATE_AND_GO))
+			expectError(resp, SnmpRequestErrorType_INCONSISTENT_VALUE, 1)
+			Ω(status(2)).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+			Ω(status(3)).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+		})
+		It("should take a row created with createAndWait through notReady and notInService to active", func() {
+			expectError(set(NewIntegerVarbind(statusOid(2), RowStatus_CREATE_AND_WAIT)), SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(2, RowStatus_NOT_READY)
+			expectError(set(NewIntegerVarbind(statusOid(2), int32(RowStatus_ACTIVE))), SnmpRequestErrorType_INCONSISTENT_VALUE, 1)
+			expectError(set(NewStringVarbind(nameOid(2), "two")), SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(2, RowStatus_NOT_IN_SERVICE)
+			expectError(set(NewIntegerVarbind(statusOid(2), int32(RowStatus_ACTIVE))), SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(2, RowStatus_ACTIVE)
+			expectError(set(NewIntegerVarbind(statusOid(2), RowStatus_NOT_IN_SERVICE)), SnmpRequestErrorType_NO_ERROR, 0)
+			expectStatus(2, RowStatus_NOT_IN_SERVICE)
+		})
+		It("should destroy rows", func() {
+			expectError(set(NewIntegerVarbind(statusOid(1), RowStatus_DESTROY)), SnmpRequestErrorType_NO_ERROR, 0)
+			Ω(status(1)).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+			// destroying a row that doesn't exist isn't an error
+			expectError(set(NewIntegerVarbind(statusOid(1), RowStatus_DESTROY)), SnmpRequestErrorType_NO_ERROR, 0)
+		})
+		It("should refuse transitions that RFC 2579 doesn't allow", func() {
+			expectError(set(NewIntegerVarbind(statusOid(1), RowStatus_CREATE_AND_WAIT)), SnmpRequestErrorType_INCONSISTENT_VALUE, 1)
+			expectError(set(NewIntegerVarbind(statusOid(2), int32(RowStatus_ACTIVE))), SnmpRequestErrorType_INCONSISTENT_VALUE, 1)
+			expectError(set(NewIntegerVarbind(statusOid(1), RowStatus_NOT_READY)), SnmpRequestErrorType_WRONG_VALUE, 1)
+			expectError(set(NewStringVarbind(statusOid(1), "active")), SnmpRequestErrorType_WRONG_TYPE, 1)
+			expectError(set(NewStringVarbind(nameOid(1), "renamed"), NewIntegerVarbind(statusOid(1), RowStatus_DESTROY)),
+				SnmpRequestErrorType_INCONSISTENT_VALUE, 2)
+			expectStatus(1, RowStatus_ACTIVE)
+		})
+		It("should only create rows through their RowStatus", func() {
+			expectError(set(NewStringVarbind(nameOid(2), "two")), SnmpRequestErrorType_INCONSISTENT_NAME, 1)
+			Ω(status(2)).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+		})
+	})
+}
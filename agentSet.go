@@ -0,0 +1,224 @@
+package gosnmp
+
+// HandlerError is implemented by errors returned from an oid handler that should be reported to the requester with a
+// particular error-status, such as SnmpRequestErrorType_WRONG_TYPE or SnmpRequestErrorType_NOT_WRITABLE. Any other error
+// returned from a handler is reported as a genErr.
+type HandlerError interface {
+	error
+	ErrorStatus() SnmpRequestErrorType
+}
+
+// SetValidator can be implemented by an oid handler to check a SET before any of the request's varbinds are applied.
+// ValidateSet returns an error if the varbind can't be set, without changing anything.
+type SetValidator interface {
+	ValidateSet(vb Varbind, txn interface{}) error
+}
+
+// SetUndoer can be implemented by an oid handler that applies a SET immediately, rather than through the transaction.
+// UndoSet is called to reverse a Set that has already succeeded, when another varbind in the same request fails, or the
+// transaction can't be committed. Handlers that only make changes through the transaction don't need it, since aborting
+// the transaction undoes their changes. A request never sets the same object more than once, so only the last Set of each
+// object has to be remembered.
+type SetUndoer interface {
+	UndoSet(vb Varbind, txn interface{}) error
+}
+
+// SetRequestHandler can be implemented by an oid handler whose varbinds in a SET have to be considered together, such as a
+// table whose rows are created by one varbind and filled in by others. Both methods are given every varbind in the request
+// that the handler serves, in request order, and return the position among them of the varbind that caused an error.
+type SetRequestHandler interface {
+	// ValidateSetRequest is called once each of the varbinds has passed ValidateSet, before any of them are applied.
+	ValidateSetRequest(varbinds []Varbind, txn interface{}) (int, error)
+	// CompleteSetRequest is called once every varbind in the request has been set, before the transaction is committed.
+	// An error causes the whole request to be undone, just as a failed Set does.
+	CompleteSetRequest(varbinds []Varbind, txn interface{}) (int, error)
+}
+
+// SetRequestObserver can be implemented by an oid handler that keeps the changes made by a SET to itself until the request
+// is over, rather than making them in the transaction. SetRequestEnded is called once for each such handler that served
+// any of the request's varbinds, after the transaction has been committed or aborted.
+type SetRequestObserver interface {
+	SetRequestEnded(txn interface{}, committed bool)
+}
+
+// setRequestGroup holds the varbinds of a SET that are served by the same SetRequestHandler, along with their positions in
+// the request.
+type setRequestGroup struct {
+	handler   SetRequestHandler
+	varbinds  []Varbind
+	positions []int
+}
+
+// groupSetRequest collects the varbinds served by each SetRequestHandler, in the order the handlers first appear in the
+// request.
+func groupSetRequest(req *communityRequest, nodes []*oidTreeNode) []*setRequestGroup {
+	var groups []*setRequestGroup
+	byNode := make(map[*oidTreeNode]*setRequestGroup)
+	for i, node := range nodes {
+		handler, ok := node.handler.(SetRequestHandler)
+		if !ok {
+			continue
+		}
+		group := byNode[node]
+		if group == nil {
+			group = &setRequestGroup{handler: handler}
+			byNode[node] = group
+			groups = append(groups, group)
+		}
+		group.varbinds = append(group.varbinds, req.varbinds[i])
+		group.positions = append(group.positions, i)
+	}
+	return groups
+}
+
+// processSetRequest applies a SetRequest atomically, as described in RFC 3416 section 4.2.5. Every varbind is validated
+// before any of them are set, and a request that sets the same object more than once is refused with inconsistentValue. If a set fails, or the transaction can't be committed, every varbind that was already set is
+// undone, and the transaction is aborted. The response then carries commitFailed, or undoFailed if the undo didn't
+// succeed.
+func (agent *Agent) processSetRequest(req *communityRequest, resp *communityResponse) {
+	if _, ok := agent.checkAccess(req, resp, ViewType_WRITE); !ok {
+		return
+	}
+	txn := agent.txnProvider.StartTxn()
+	if txn == nil {
+		agent.respondWithError(req, resp, SnmpRequestErrorType_RESOURCE_UNAVAILABLE, 1)
+		return
+	}
+
+	// validate every varbind before any of them are applied
+	nodes := make([]*oidTreeNode, len(req.varbinds))
+	committed := false
+	defer func() { endSetRequest(nodes, txn, committed) }()
+	targets := make(map[string]bool)
+	for i, vb := range req.varbinds {
+		// Handlers that apply sets immediately can only undo one set of each object, and the outcome of setting an object
+		// twice in the same request isn't defined anyway.
+		if targets[indexKey(vb.GetOid())] {
+			agent.txnProvider.AbortTxn(txn)
+			agent.respondWithError(req, resp, SnmpRequestErrorType_INCONSISTENT_VALUE, i+1)
+			return
+		}
+		targets[indexKey(vb.GetOid())] = true
+		nodes[i] = agent.lookupHandler(vb.GetOid())
+		if nodes[i] == nil {
+			agent.txnProvider.AbortTxn(txn)
+			agent.respondWithError(req, resp, SnmpRequestErrorType_NO_CREATION, i+1)
+			return
+		}
+		if validator, ok := nodes[i].handler.(SetValidator); ok {
+			if err := validator.ValidateSet(vb, txn); err != nil {
+				agent.Debugf("Agent %s: SET of %v rejected - err: %s", agent.name, vb.GetOid(), err)
+				agent.txnProvider.AbortTxn(txn)
+				agent.respondWithError(req, resp, errorStatus(err), i+1)
+				return
+			}
+		}
+	}
+	groups := groupSetRequest(req, nodes)
+	for _, group := range groups {
+		if i, err := group.handler.ValidateSetRequest(group.varbinds, txn); err != nil {
+			agent.Debugf("Agent %s: SET of %v rejected - err: %s", agent.name, group.varbinds[i].GetOid(), err)
+			agent.txnProvider.AbortTxn(txn)
+			agent.respondWithError(req, resp, errorStatus(err), group.positions[i]+1)
+			return
+		}
+	}
+
+	// apply them
+	for i, vb := range req.varbinds {
+		responseVb, err := nodes[i].handler.Set(vb, txn)
+		if err != nil {
+			agent.Debugf("Agent %s: SET of %v failed - err: %s", agent.name, vb.GetOid(), err)
+			agent.undoSets(req, resp, nodes[:i], txn, setErrorStatus(err), i+1)
+			return
+		}
+		if responseVb == nil {
+			responseVb = vb
+		}
+		resp.AddVarbind(responseVb)
+	}
+	for _, group := range groups {
+		if i, err := group.handler.CompleteSetRequest(group.varbinds, txn); err != nil {
+			agent.Debugf("Agent %s: SET of %v couldn't be completed - err: %s", agent.name, group.varbinds[i].GetOid(), err)
+			agent.undoSets(req, resp, nodes, txn, setErrorStatus(err), group.positions[i]+1)
+			return
+		}
+	}
+
+	// and commit
+	if !agent.txnProvider.CommitTxn(txn) {
+		agent.Debugf("Agent %s: SET transaction couldn't be committed", agent.name)
+		// CommitTxn has aborted the transaction, but handlers that don't use it still have to be undone. Which varbind
+		// couldn't be committed isn't known.
+		if !agent.undoApplied(req, nodes, txn) {
+			agent.respondWithError(req, resp, SnmpRequestErrorType_UNDO_FAILED, 0)
+			return
+		}
+		agent.respondWithError(req, resp, SnmpRequestErrorType_COMMIT_FAILED, 0)
+		return
+	}
+	committed = true
+	agent.respond(req, resp)
+}
+
+// endSetRequest tells each SetRequestObserver that served the request's varbinds that the request is over.
+func endSetRequest(nodes []*oidTreeNode, txn interface{}, committed bool) {
+	ended := make(map[*oidTreeNode]bool)
+	for _, node := range nodes {
+		if node == nil || ended[node] {
+			continue
+		}
+		ended[node] = true
+		if observer, ok := node.handler.(SetRequestObserver); ok {
+			observer.SetRequestEnded(txn, committed)
+		}
+	}
+}
+
+// undoSets undoes the varbinds that have already been set, aborts the transaction, and responds with the given error,
+// unless the undo fails.
+func (agent *Agent) undoSets(req *communityRequest, resp *communityResponse, applied []*oidTreeNode, txn interface{}, errorVal SnmpRequestErrorType, errorIdx int) {
+	undone := agent.undoApplied(req, applied, txn)
+	agent.txnProvider.AbortTxn(txn)
+	if !undone {
+		// RFC 3416 section 4.2.5: undoFailed always carries an error-index of zero
+		agent.respondWithError(req, resp, SnmpRequestErrorType_UNDO_FAILED, 0)
+		return
+	}
+	agent.respondWithError(req, resp, errorVal, errorIdx)
+}
+
+// undoApplied undoes the sets made by the given handlers, which are for the leading varbinds of the request, in reverse
+// order. It returns false if any of the handlers failed to undo its set.
+func (agent *Agent) undoApplied(req *communityRequest, applied []*oidTreeNode, txn interface{}) bool {
+	undone := true
+	for i := len(applied) - 1; i >= 0; i-- {
+		undoer, ok := applied[i].handler.(SetUndoer)
+		if !ok {
+			continue
+		}
+		if err := undoer.UndoSet(req.varbinds[i], txn); err != nil {
+			agent.Errorf("Agent %s: couldn't undo SET of %v - err: %s", agent.name, req.varbinds[i].GetOid(), err)
+			undone = false
+		}
+	}
+	return undone
+}
+
+// setErrorStatus returns the error-status for a varbind that couldn't be set. Once the varbinds that were set are undone,
+// the request will have had no effect, just as if the varbind had failed validation, so the handler's own reason for
+// refusing it can be reported. Otherwise the failure is reported as commitFailed.
+func setErrorStatus(err error) SnmpRequestErrorType {
+	if _, ok := err.(HandlerError); ok {
+		return errorStatus(err)
+	}
+	return SnmpRequestErrorType_COMMIT_FAILED
+}
+
+// errorStatus returns the error-status that a handler's error should be reported with.
+func errorStatus(err error) SnmpRequestErrorType {
+	if handlerErr, ok := err.(HandlerError); ok {
+		return handlerErr.ErrorStatus()
+	}
+	return SnmpRequestErrorType_GENERIC_ERROR
+}
@@ -0,0 +1,223 @@
+package gosnmp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TableHandler serves a conceptual table from an agent, dealing in column numbers and decoded row indexes rather than
+// instance oids. It's registered with RegisterTableHandler.
+type TableHandler interface {
+	// Index describes the objects in the table's INDEX clause.
+	Index() []IndexSpec
+	// Columns returns the numbers of the table's accessible columns.
+	Columns() []uint32
+	// NextRow returns the index of the first row that comes after the given index, or nil if there are no more rows. A nil
+	// index asks for the first row. Rows must be returned in the order of their encoded indexes, as produced by EncodeIndex:
+	// integers in numerical order, but variable length strings that aren't IMPLIED by length first.
+	NextRow(index TableIndex, txn interface{}) (TableIndex, error)
+	// GetCell returns the value of a column in a row, or nil if the table has no such row, or the row has no value for the
+	// column. The oid of the returned varbind is ignored; it may be nil.
+	GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error)
+	// SetCell sets the value of a column in a row. Errors implementing HandlerError are reported with their own
+	// error-status.
+	SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error
+}
+
+// TableSetValidator can be implemented by a TableHandler to check the cells of a SET before any of them are applied, as
+// described for SetValidator.
+type TableSetValidator interface {
+	ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error
+}
+
+// TableSetUndoer can be implemented by a TableHandler that applies SETs immediately, as described for SetUndoer. A
+// TableHandler can also implement SetRequestObserver.
+type TableSetUndoer interface {
+	UndoSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error
+}
+
+// RegisterTableHandler registers a handler for the table whose entry is at entryOid (e.g. ifEntry, 1.3.6.1.2.1.2.2.1).
+// The table is walked a column at a time, in ascending column order, and down each column in row order.
+func (agent *Agent) RegisterTableHandler(entryOid ObjectIdentifier, handler TableHandler) error {
+	columns := append([]uint32{}, handler.Columns()...)
+	if len(columns) == 0 {
+		return fmt.Errorf("Table %v has no columns", entryOid)
+	}
+	sort.Sort(subIdentifiers(columns))
+	table := &tableOidHandler{entryOid, columns, handler.Index(), handler}
+	if rowStatus, ok := handler.(RowStatusHandler); ok {
+		return agent.RegisterMultiVarOidHandler(entryOid, &rowStatusOidHandler{table, rowStatus, rowStatus.RowStatusColumn()})
+	}
+	return agent.RegisterMultiVarOidHandler(entryOid, table)
+}
+
+type subIdentifiers []uint32
+
+func (ids subIdentifiers) Len() int           { return len(ids) }
+func (ids subIdentifiers) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+func (ids subIdentifiers) Less(i, j int) bool { return ids[i] < ids[j] }
+
+// tableOidHandler adapts a TableHandler to the oid handler interfaces, translating between instance oids and the
+// table's columns and indexes.
+type tableOidHandler struct {
+	entryOid ObjectIdentifier
+	columns  []uint32
+	specs    []IndexSpec
+	handler  TableHandler
+}
+
+type cellNotFoundError struct {
+	oid ObjectIdentifier
+}
+
+func (e cellNotFoundError) Error() string {
+	return fmt.Sprintf("No such table cell: %v", e.oid)
+}
+
+func (e cellNotFoundError) ErrorStatus() SnmpRequestErrorType {
+	return SnmpRequestErrorType_NO_CREATION
+}
+
+// cell splits an instance oid into its column and index. It returns false if the oid isn't a cell of the table.
+func (t *tableOidHandler) cell(oid ObjectIdentifier) (uint32, TableIndex, bool) {
+	if len(oid) <= len(t.entryOid)+1 || !t.hasColumn(oid[len(t.entryOid)]) {
+		return 0, nil, false
+	}
+	indexOid := oid[len(t.entryOid)+1:]
+	index, indexLen, err := DecodeIndex(indexOid, t.specs)
+	if err != nil || indexLen != len(indexOid) {
+		return 0, nil, false
+	}
+	return oid[len(t.entryOid)], index, true
+}
+
+func (t *tableOidHandler) hasColumn(column uint32) bool {
+	i := sort.Search(len(t.columns), func(i int) bool { return t.columns[i] >= column })
+	return i < len(t.columns) && t.columns[i] == column
+}
+
+func (t *tableOidHandler) cellOid(column uint32, index TableIndex) (ObjectIdentifier, error) {
+	indexOid, err := EncodeIndex(index, t.specs)
+	if err != nil {
+		return nil, err
+	}
+	oid := make(ObjectIdentifier, 0, len(t.entryOid)+1+len(indexOid))
+	oid = append(append(append(oid, t.entryOid...), column), indexOid...)
+	return oid, nil
+}
+
+func (t *tableOidHandler) Get(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	column, index, ok := t.cell(oid)
+	if !ok {
+		return NewNoSuchInstanceVarbindVarbind(oid), nil
+	}
+	vb, err := t.handler.GetCell(column, index, txn)
+	if err != nil {
+		return nil, err
+	}
+	if vb == nil {
+		return NewNoSuchInstanceVarbindVarbind(oid), nil
+	}
+	vb.setOid(oid)
+	return vb, nil
+}
+
+// GetNext finds the first cell after oid, working along the columns from the one named in oid, and down each column
+// from the row after the index in oid.
+func (t *tableOidHandler) GetNext(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	var after ObjectIdentifier // the part of oid that's compared with the encoded indexes in the first column searched
+	firstColumn := 0
+	switch {
+	case oid.Compare(t.entryOid) < 0:
+	case len(oid) == len(t.entryOid):
+	default:
+		firstColumn = sort.Search(len(t.columns), func(i int) bool { return t.columns[i] >= oid[len(t.entryOid)] })
+		if firstColumn < len(t.columns) && t.columns[firstColumn] == oid[len(t.entryOid)] {
+			after = oid[len(t.entryOid)+1:]
+		}
+	}
+	for _, column := range t.columns[firstColumn:] {
+		vb, err := t.nextInColumn(column, after, txn)
+		if err != nil || vb != nil {
+			return vb, err
+		}
+		after = nil
+	}
+	return nil, nil
+}
+
+// nextInColumn returns the first cell in the column with an encoded index greater than after, or the column's first
+// cell if after is nil.
+func (t *tableOidHandler) nextInColumn(column uint32, after ObjectIdentifier, txn interface{}) (Varbind, error) {
+	var index TableIndex
+	if after != nil {
+		// Start from the row named by after if it can be decoded. If it decodes with sub-identifiers left over, after
+		// comes after that row's cells, so the search still starts with the next row.
+		if decoded, _, err := DecodeIndex(after, t.specs); err == nil {
+			index = decoded
+		}
+	}
+	for {
+		var err error
+		if index, err = t.handler.NextRow(index, txn); err != nil || index == nil {
+			return nil, err
+		}
+		oid, err := t.cellOid(column, index)
+		if err != nil {
+			return nil, err
+		}
+		// the handler always gets indexes in their decoded form, whatever types it used for them itself
+		if index, _, err = DecodeIndex(oid[len(t.entryOid)+1:], t.specs); err != nil {
+			return nil, err
+		}
+		if after != nil && oid[len(t.entryOid)+1:].Compare(after) <= 0 {
+			// only happens when after couldn't be decoded, so the rows are scanned from the start
+			continue
+		}
+		vb, err := t.handler.GetCell(column, index, txn)
+		if err != nil {
+			return nil, err
+		}
+		if vb != nil {
+			vb.setOid(oid)
+			return vb, nil
+		}
+	}
+}
+
+func (t *tableOidHandler) ValidateSet(vb Varbind, txn interface{}) error {
+	column, index, ok := t.cell(vb.GetOid())
+	if !ok {
+		return cellNotFoundError{vb.GetOid()}
+	}
+	if validator, ok := t.handler.(TableSetValidator); ok {
+		return validator.ValidateSetCell(column, index, vb, txn)
+	}
+	return nil
+}
+
+func (t *tableOidHandler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	column, index, ok := t.cell(vb.GetOid())
+	if !ok {
+		return nil, cellNotFoundError{vb.GetOid()}
+	}
+	if err := t.handler.SetCell(column, index, vb, txn); err != nil {
+		return nil, err
+	}
+	return vb, nil
+}
+
+func (t *tableOidHandler) SetRequestEnded(txn interface{}, committed bool) {
+	if observer, ok := t.handler.(SetRequestObserver); ok {
+		observer.SetRequestEnded(txn, committed)
+	}
+}
+
+func (t *tableOidHandler) UndoSet(vb Varbind, txn interface{}) error {
+	undoer, ok := t.handler.(TableSetUndoer)
+	if !ok {
+		return nil
+	}
+	column, index, _ := t.cell(vb.GetOid())
+	return undoer.UndoSetCell(column, index, vb, txn)
+}
@@ -0,0 +1,219 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+)
+
+type testTableRow struct {
+	id    uint32
+	name  string
+	descr string
+	mtu   int32 // zero if the row has no mtu
+}
+
+// testTableHandler serves a table indexed by an INTEGER and a variable length OCTET STRING, with a writable descr column
+// (2) and a sparse mtu column (3). Its rows must be in index order.
+type testTableHandler struct {
+	rows []*testTableRow
+}
+
+var testTableIndex = []IndexSpec{{Type: IndexType_INTEGER}, {Type: IndexType_OCTET_STRING}}
+
+func (handler *testTableHandler) Index() []IndexSpec {
+	return testTableIndex
+}
+
+func (handler *testTableHandler) Columns() []uint32 {
+	return []uint32{3, 2}
+}
+
+func (handler *testTableHandler) NextRow(index TableIndex, txn interface{}) (TableIndex, error) {
+	var after ObjectIdentifier
+	if index != nil {
+		var err error
+		if after, err = EncodeIndex(index, testTableIndex); err != nil {
+			return nil, err
+		}
+	}
+	for _, row := range handler.rows {
+		rowIndex := TableIndex{row.id, row.name}
+		encoded, _ := EncodeIndex(rowIndex, testTableIndex)
+		if after == nil || encoded.Compare(after) > 0 {
+			return rowIndex, nil
+		}
+	}
+	return nil, nil
+}
+
+func (handler *testTableHandler) row(index TableIndex) *testTableRow {
+	for _, row := range handler.rows {
+		if row.id == index[0].(uint32) && row.name == string(index[1].([]byte)) {
+			return row
+		}
+	}
+	return nil
+}
+
+func (handler *testTableHandler) GetCell(column uint32, index TableIndex, txn interface{}) (Varbind, error) {
+	row := handler.row(index)
+	switch {
+	case row == nil:
+		return nil, nil
+	case column == 2:
+		return NewStringVarbind(nil, row.descr), nil
+	case column == 3 && row.mtu != 0:
+		return NewIntegerVarbind(nil, row.mtu), nil
+	}
+	return nil, nil
+}
+
+func (handler *testTableHandler) ValidateSetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	if handler.row(index) == nil {
+		return testHandlerError{SnmpRequestErrorType_NO_CREATION}
+	}
+	if column != 2 {
+		return testHandlerError{SnmpRequestErrorType_NOT_WRITABLE}
+	}
+	if _, ok := vb.(*OctetStringVarbind); !ok {
+		return testHandlerError{SnmpRequestErrorType_WRONG_TYPE}
+	}
+	return nil
+}
+
+func (handler *testTableHandler) SetCell(column uint32, index TableIndex, vb Varbind, txn interface{}) error {
+	handler.row(index).descr = string(vb.(*OctetStringVarbind).Value)
+	return nil
+}
+
+func SetupAgentTableTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Table indexes", func() {
+		It("should round trip every type of index", func() {
+			specs := []IndexSpec{
+				{Type: IndexType_INTEGER},
+				{Type: IndexType_FIXED_LENGTH_OCTET_STRING, Length: 3},
+				{Type: IndexType_OCTET_STRING},
+				{Type: IndexType_IP_ADDRESS},
+				{Type: IndexType_OBJECT_IDENTIFIER},
+			}
+			index := TableIndex{uint32(7), []byte("abc"), []byte("de"), net.IP{10, 0, 0, 1}, ObjectIdentifier{1, 3, 6}}
+			oid, err := EncodeIndex(index, specs)
+			Ω(err).Should(BeNil())
+			Ω(oid).Should(Equal(ObjectIdentifier{7, 97, 98, 99, 2, 100, 101, 10, 0, 0, 1, 3, 1, 3, 6}))
+			decoded, length, err := DecodeIndex(oid, specs)
+			Ω(err).Should(BeNil())
+			Ω(length).Should(Equal(len(oid)))
+			Ω(decoded).Should(Equal(index))
+		})
+		It("should leave the length out of an IMPLIED index", func() {
+			specs := []IndexSpec{{Type: IndexType_INTEGER}, {Type: IndexType_OCTET_STRING, Implied: true}}
+			oid, err := EncodeIndex(TableIndex{1, "ab"}, specs)
+			Ω(err).Should(BeNil())
+			Ω(oid).Should(Equal(ObjectIdentifier{1, 97, 98}))
+			decoded, length, err := DecodeIndex(oid, specs)
+			Ω(err).Should(BeNil())
+			Ω(length).Should(Equal(3))
+			Ω(decoded).Should(Equal(TableIndex{uint32(1), []byte("ab")}))
+		})
+		It("should report the length of an index followed by other sub-identifiers", func() {
+			decoded, length, err := DecodeIndex(ObjectIdentifier{1, 2, 3}, []IndexSpec{{Type: IndexType_INTEGER}})
+			Ω(err).Should(BeNil())
+			Ω(length).Should(Equal(1))
+			Ω(decoded).Should(Equal(TableIndex{uint32(1)}))
+		})
+		It("should reject values that don't fit their index", func() {
+			_, err := EncodeIndex(TableIndex{"abcd"}, []IndexSpec{{Type: IndexType_FIXED_LENGTH_OCTET_STRING, Length: 3}})
+			Ω(err).Should(BeAssignableToTypeOf(IndexError{}))
+			_, err = EncodeIndex(TableIndex{-1}, []IndexSpec{{Type: IndexType_INTEGER}})
+			Ω(err).Should(BeAssignableToTypeOf(IndexError{}))
+			_, _, err = DecodeIndex(ObjectIdentifier{3, 97, 98}, []IndexSpec{{Type: IndexType_OCTET_STRING}})
+			Ω(err).Should(BeAssignableToTypeOf(IndexError{}))
+			_, _, err = DecodeIndex(ObjectIdentifier{10, 0, 256, 1}, []IndexSpec{{Type: IndexType_IP_ADDRESS}})
+			Ω(err).Should(BeAssignableToTypeOf(IndexError{}))
+		})
+	})
+
+	Describe("Agent table handlers", func() {
+		var (
+			agent      *Agent
+			clientCtxt *ClientContext
+			client     *V2cClient
+		)
+		entryOid := ObjectIdentifier{1, 3, 6, 1, 4, 1, 424242, 2, 1}
+		cellOid := func(column uint32, id uint32, name string) ObjectIdentifier {
+			index, _ := EncodeIndex(TableIndex{id, name}, testTableIndex)
+			return append(childOid(entryOid, column), index...)
+		}
+		BeforeEach(func() {
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2171, logger, new(testTxnProvider))
+			Ω(agent.RegisterTableHandler(entryOid, &testTableHandler{[]*testTableRow{
+				{1, "eth", "first ethernet", 1500},
+				{1, "wlan", "wireless", 0},
+				{2, "a", "second", 9000},
+			}})).Should(BeNil())
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2171)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			agent.Shutdown()
+		})
+		getNext := func(oid ObjectIdentifier) Varbind {
+			req := clientCtxt.AllocateV2cGetNextRequest()
+			req.AddOid(oid)
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response().Varbinds()[0]
+		}
+
+		It("should answer a Get for a cell", func() {
+			req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{cellOid(2, 1, "wlan"), cellOid(3, 1, "wlan")})
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			varbinds := req.Response().Varbinds()
+			Ω(string(varbinds[0].(*OctetStringVarbind).Value)).Should(Equal("wireless"))
+			Ω(varbinds[0].GetOid()).Should(Equal(cellOid(2, 1, "wlan")))
+			Ω(varbinds[1]).Should(BeAssignableToTypeOf(new(NoSuchInstanceVarbind)))
+		})
+		It("should walk down each column, skipping missing cells, and on to the next", func() {
+			Ω(getNext(entryOid).GetOid()).Should(Equal(cellOid(2, 1, "eth")))
+			Ω(getNext(cellOid(2, 2, "a")).GetOid()).Should(Equal(cellOid(3, 1, "eth")))
+			Ω(getNext(cellOid(3, 1, "eth")).GetOid()).Should(Equal(cellOid(3, 2, "a")))
+		})
+		It("should find the next row for an oid that isn't a complete index", func() {
+			Ω(getNext(childOid(entryOid, 2, 1)).GetOid()).Should(Equal(cellOid(2, 1, "eth")))
+			Ω(getNext(childOid(entryOid, 2, 1, 4)).GetOid()).Should(Equal(cellOid(2, 1, "wlan")))
+			Ω(getNext(append(cellOid(2, 1, "eth"), 0)).GetOid()).Should(Equal(cellOid(2, 1, "wlan")))
+			Ω(getNext(childOid(entryOid, 1, 99)).GetOid()).Should(Equal(cellOid(2, 1, "eth")))
+		})
+		It("should be retrieved by GetTable", func() {
+			table, err := client.GetTable(entryOid)
+			Ω(err).Should(BeNil())
+			Ω(table.Rows).Should(HaveLen(3))
+			eth := table.Row(cellOid(0, 1, "eth")[len(entryOid)+1:])
+			Ω(eth.Columns).Should(HaveLen(2))
+			Ω(eth.Columns[3].(*IntegerVarbind).Value).Should(Equal(int32(1500)))
+			wlan := table.Row(cellOid(0, 1, "wlan")[len(entryOid)+1:])
+			Ω(wlan.Columns).Should(HaveLen(1))
+			Ω(string(wlan.Columns[2].(*OctetStringVarbind).Value)).Should(Equal("wireless"))
+		})
+		It("should set writable cells, and refuse the others", func() {
+			set := func(vb Varbind) SnmpResponse {
+				req := clientCtxt.AllocateV2cSetRequest()
+				req.(*communityRequest).AddVarbind(vb)
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				return req.Response()
+			}
+			Ω(set(NewStringVarbind(cellOid(2, 2, "a"), "renamed")).ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ERROR)))
+			Ω(set(NewIntegerVarbind(cellOid(3, 2, "a"), 1)).ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NOT_WRITABLE)))
+			Ω(set(NewStringVarbind(cellOid(2, 3, "b"), "new")).ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_CREATION)))
+			Ω(set(NewStringVarbind(childOid(entryOid, 2, 2), "bad index")).ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_CREATION)))
+			Ω(string(getNext(cellOid(2, 1, "wlan")).(*OctetStringVarbind).Value)).Should(Equal("renamed"))
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package gosnmp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// localEngine is the SNMPv3 engine of an agent, which is authoritative for the requests the agent receives.
+type localEngine struct {
+	engineId    []byte
+	engineBoots int32
+	bootTime    time.Time
+}
+
+// current returns the engine's snmpEngineBoots and snmpEngineTime
+func (engine *localEngine) current() (engineBoots int32, engineTime int32) {
+	return engine.engineBoots, int32(time.Since(engine.bootTime).Seconds())
+}
+
+// isInTimeWindow checks the engineBoots and engineTime of an authenticated message against the engine, as described in
+// RFC 3414 section 3.2.7a.
+func (engine *localEngine) isInTimeWindow(engineBoots int32, engineTime int32) bool {
+	currentBoots, currentTime := engine.current()
+	if currentBoots == math.MaxInt32 || engineBoots != currentBoots {
+		return false
+	}
+	return engineTime >= currentTime-engineTimeWindow && engineTime <= currentTime+engineTimeWindow
+}
+
+// EngineBootsStore persists an agent's snmpEngineBoots, which must increase every time the agent's engine restarts.
+type EngineBootsStore interface {
+	// LoadEngineBoots returns the last value saved, or 0 if nothing has been saved yet.
+	LoadEngineBoots() (int32, error)
+	SaveEngineBoots(engineBoots int32) error
+}
+
+// FileEngineBootsStore keeps snmpEngineBoots in a text file at Path.
+type FileEngineBootsStore struct {
+	Path string
+}
+
+func (store *FileEngineBootsStore) LoadEngineBoots() (int32, error) {
+	contents, err := ioutil.ReadFile(store.Path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	engineBoots, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid engine boots in %s - err: %s", store.Path, err)
+	}
+	return int32(engineBoots), nil
+}
+
+func (store *FileEngineBootsStore) SaveEngineBoots(engineBoots int32) error {
+	return ioutil.WriteFile(store.Path, []byte(strconv.Itoa(int(engineBoots))+"\n"), 0644)
+}
+
+// EnableV3 makes the agent an authoritative SNMPv3 engine with the given snmpEngineID, which must be between 5 and 32 bytes
+// long. snmpEngineBoots is loaded from bootsStore, incremented, and saved again before the engine starts. Requests are
+// only accepted from users added with AddUsmUser. The snmpEngine objects and the usmStats counters are served by the agent
+// from then on. Calling EnableV3 again restarts the engine.
+func (agent *Agent) EnableV3(engineId []byte, bootsStore EngineBootsStore) error {
+	if len(engineId) < 5 || len(engineId) > 32 {
+		return fmt.Errorf("Invalid engine id length: %d", len(engineId))
+	}
+	engineBoots, err := bootsStore.LoadEngineBoots()
+	if err != nil {
+		return err
+	}
+	// RFC 3414 section 2.2.2: once snmpEngineBoots reaches its maximum, it stays there until the engine is reconfigured
+	if engineBoots < math.MaxInt32 {
+		engineBoots++
+	}
+	if err := bootsStore.SaveEngineBoots(engineBoots); err != nil {
+		return err
+	}
+	engine := &localEngine{engineId: engineId, engineBoots: engineBoots, bootTime: time.Now()}
+	agent.localEngineLock.Lock()
+	agent.localEngine = engine
+	agent.localEngineLock.Unlock()
+
+	agent.RegisterSingleVarOidHandler(SNMP_ENGINE_ID_OID, &snmpEngineHandler{engine})
+	agent.RegisterSingleVarOidHandler(SNMP_ENGINE_BOOTS_OID, &snmpEngineHandler{engine})
+	agent.RegisterSingleVarOidHandler(SNMP_ENGINE_TIME_OID, &snmpEngineHandler{engine})
+	agent.RegisterSingleVarOidHandler(SNMP_ENGINE_MAX_MESSAGE_SIZE_OID, &snmpEngineHandler{engine})
+	for errorType := UsmErrorType_UNSUPPORTED_SEC_LEVEL; errorType <= UsmErrorType_DECRYPTION_ERROR; errorType++ {
+		agent.RegisterSingleVarOidHandler(errorType.oid(), &counter32Handler{errorType.oid(), &agent.usmStats[errorType]})
+	}
+	return nil
+}
+
+// AddUsmUser adds a user that SNMPv3 requests will be accepted from. Its keys are localized to the agent's engine.
+func (agent *Agent) AddUsmUser(user *UsmUser) {
+	agent.usmUsers.addUser(user)
+}
+
+// processUsmError counts a request that failed USM processing, and sends the report required by RFC 3414 if the request
+// was reportable.
+func (agent *Agent) processUsmError(usmErr UsmError, addr *net.UDPAddr) {
+	count := atomic.AddUint32(&agent.usmStats[usmErr.Type], 1)
+	engine := agent.getLocalEngine()
+	params := usmErr.params
+	if engine == nil || params == nil || params.flags&v3MsgFlags_REPORTABLE == 0 {
+		return
+	}
+	report := newReport(Version3, usmErr.requestId)
+	report.v3 = &v3Params{msgId: params.msgId, msgMaxSize: v3MsgMaxSize, engineId: engine.engineId, userName: params.userName,
+		contextEngineId: engine.engineId, contextName: params.contextName}
+	report.v3.engineBoots, report.v3.engineTime = engine.current()
+	if usmErr.Type == UsmErrorType_NOT_IN_TIME_WINDOW {
+		// section 3.2.7a: this report is authenticated, so that the sender can trust the boots and time it carries
+		report.v3.flags = v3MsgFlags_AUTH
+		report.v3.user = params.user
+	}
+	report.AddVarbind(NewCounter32Varbind(usmErr.Type.oid(), count))
+	report.setAddress(addr)
+	agent.incrementStat(StatType_REPORTS_SENT)
+	agent.sendMessage(report)
+}
+
+type readOnlyObjectError struct {
+	oid ObjectIdentifier
+}
+
+func (e readOnlyObjectError) Error() string {
+	return fmt.Sprintf("Object is read-only: %v", e.oid)
+}
+
+func (e readOnlyObjectError) ErrorStatus() SnmpRequestErrorType {
+	return SnmpRequestErrorType_NOT_WRITABLE
+}
+
+// snmpEngineHandler serves the objects in the snmpEngine group
+type snmpEngineHandler struct {
+	engine *localEngine
+}
+
+func (handler *snmpEngineHandler) Get(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	engineBoots, engineTime := handler.engine.current()
+	switch {
+	case oid.Compare(SNMP_ENGINE_ID_OID) == 0:
+		return NewOctetStringVarbind(oid, handler.engine.engineId), nil
+	case oid.Compare(SNMP_ENGINE_BOOTS_OID) == 0:
+		return NewIntegerVarbind(oid, engineBoots), nil
+	case oid.Compare(SNMP_ENGINE_TIME_OID) == 0:
+		return NewIntegerVarbind(oid, engineTime), nil
+	case oid.Compare(SNMP_ENGINE_MAX_MESSAGE_SIZE_OID) == 0:
+		return NewIntegerVarbind(oid, v3MsgMaxSize), nil
+	}
+	return NewNoSuchInstanceVarbindVarbind(oid), nil
+}
+
+func (handler *snmpEngineHandler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	return nil, readOnlyObjectError{vb.GetOid()}
+}
@@ -0,0 +1,122 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+func SetupAgentUsmTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Agent USM", func() {
+		var (
+			agent      *Agent
+			clientCtxt *ClientContext
+			user       *UsmUser
+			bootsStore *FileEngineBootsStore
+			tempDir    string
+		)
+		engineId := mustDecodeHex("80001f8880e9630000d61ff449")
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "gosnmp")
+			Ω(err).Should(BeNil())
+			bootsStore = &FileEngineBootsStore{filepath.Join(tempDir, "engineBoots")}
+			user, _ = NewUsmUserWithPrivacy("operator", AuthProtocol_SHA256, "correct horse", PrivProtocol_AES, "battery staple")
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2166, logger, new(testTxnProvider))
+			agent.RegisterSingleVarOidHandler(SYS_DESCR_OID, &testStringHandler{val: "v3 agent"})
+			agent.RegisterSingleVarOidHandler(SYS_CONTACT_OID, &testStringHandler{val: "admin", writable: true})
+			Ω(agent.EnableV3(engineId, bootsStore)).Should(BeNil())
+			agent.AddUsmUser(user)
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			agent.Shutdown()
+			os.RemoveAll(tempDir)
+		})
+		get := func(client *V3Client, oid ObjectIdentifier) V2cGetRequest {
+			req := clientCtxt.AllocateV3GetRequest()
+			req.AddOid(oid)
+			client.SendRequest(req)
+			return req
+		}
+		newClient := func(user *UsmUser) *V3Client {
+			client, err := clientCtxt.NewV3ClientWithPort(user, "localhost", 2166)
+			Ω(err).Should(BeNil())
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+			return client
+		}
+
+		It("should reject invalid engine ids", func() {
+			Ω(agent.EnableV3([]byte{1, 2, 3, 4}, bootsStore)).ShouldNot(BeNil())
+		})
+		It("should increment the persisted engine boots every time the engine starts", func() {
+			Ω(bootsStore.LoadEngineBoots()).Should(Equal(int32(1)))
+			Ω(agent.EnableV3(engineId, bootsStore)).Should(BeNil())
+			Ω(bootsStore.LoadEngineBoots()).Should(Equal(int32(2)))
+			Ω(agent.getLocalEngine().engineBoots).Should(Equal(int32(2)))
+		})
+		It("should serve encrypted requests once the client has discovered the engine", func() {
+			req := get(newClient(user), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(string(req.Response().Varbinds()[0].(*OctetStringVarbind).Value)).Should(Equal("v3 agent"))
+			resp := req.Response().(*communityResponse)
+			Ω(resp.v3.isEncrypted()).Should(BeTrue())
+			Ω(resp.v3.engineId).Should(Equal(engineId))
+			Ω(resp.v3.engineBoots).Should(Equal(int32(1)))
+		})
+		It("should serve the snmpEngine objects and usmStats counters", func() {
+			client := newClient(user)
+			req := get(client, SNMP_ENGINE_ID_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().Varbinds()[0].(*OctetStringVarbind).Value).Should(Equal(engineId))
+			// the client's discovery probe was counted as an unknown engine id
+			req = get(client, UsmErrorType(UsmErrorType_UNKNOWN_ENGINE_ID).oid())
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().Varbinds()[0].(*Counter32Varbind).Value).Should(Equal(uint32(1)))
+		})
+		It("should report requests from unknown users", func() {
+			stranger, _ := NewUsmUser("stranger", AuthProtocol_SHA, "maplesyrup")
+			req := get(newClient(stranger), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeAssignableToTypeOf(UsmError{}))
+			Ω(req.TransportError().(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_UNKNOWN_USER_NAME)))
+			Eventually(func() (int, error) { return agent.GetStat(StatType_REPORTS_SENT, 0) }).Should(Equal(2))
+		})
+		It("should report requests with the wrong digest", func() {
+			impostor, _ := NewUsmUser("operator", AuthProtocol_SHA256, "wrong horse")
+			req := get(newClient(impostor), SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeAssignableToTypeOf(UsmError{}))
+			Ω(req.TransportError().(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_WRONG_DIGEST)))
+		})
+		It("should refuse requests below the security level of their user when there's no access control", func() {
+			unauthenticated, _ := NewUsmUser("operator", AuthProtocol_NONE, "")
+			client := newClient(unauthenticated)
+			req := get(client, SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_AUTHORIZATION_ERROR)))
+
+			setReq := clientCtxt.AllocateV3SetRequest()
+			setReq.AddVarbind(NewStringVarbind(SYS_CONTACT_OID, "intruder"))
+			client.SendRequest(setReq)
+			Ω(setReq.TransportError()).Should(BeNil())
+			Ω(setReq.Response().ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_AUTHORIZATION_ERROR)))
+			Ω(get(newClient(user), SYS_CONTACT_OID).Response().Varbinds()[0].(*OctetStringVarbind).Value).Should(Equal([]byte("admin")))
+		})
+		It("should report requests outside the time window, so that the client can resynchronize", func() {
+			client := newClient(user)
+			Ω(get(client, SYS_DESCR_OID).TransportError()).Should(BeNil())
+			// restart the engine, so that the client's notion of engine boots is out of date
+			Ω(agent.EnableV3(engineId, bootsStore)).Should(BeNil())
+			req := get(client, SYS_DESCR_OID)
+			Ω(req.TransportError()).Should(BeNil())
+			Ω(req.Response().(*communityResponse).v3.engineBoots).Should(Equal(int32(2)))
+			Eventually(func() (int, error) { return clientCtxt.GetStat(StatType_REQUESTS_RESENT_AFTER_REPORT, 0) }).Should(Equal(1))
+			Ω(atomic.LoadUint32(&agent.usmStats[UsmErrorType_NOT_IN_TIME_WINDOW])).Should(Equal(uint32(1)))
+		})
+	})
+}
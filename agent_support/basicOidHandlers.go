@@ -13,6 +13,10 @@ func (e objectNotWriteableError) Error() string {
 	return fmt.Sprintf("Object Not Writeable: %v", e.oid)
 }
 
+func (e objectNotWriteableError) ErrorStatus() SnmpRequestErrorType {
+	return SnmpRequestErrorType_NOT_WRITABLE
+}
+
 type incorrectVarbindTypeError struct {
 	vb       Varbind
 	expected Varbind
@@ -22,6 +26,10 @@ func (e incorrectVarbindTypeError) Error() string {
 	return fmt.Sprintf("Incorrect varbind type for: %v, got: %T, expecting: %d", e.vb.GetOid(), e.vb, e.expected)
 }
 
+func (e incorrectVarbindTypeError) ErrorStatus() SnmpRequestErrorType {
+	return SnmpRequestErrorType_WRONG_TYPE
+}
+
 type basicOidHandler struct {
 	writable bool
 }
@@ -54,7 +62,7 @@ func (handler *IntOidHandler) Get(oid ObjectIdentifier) (Varbind, error) {
 
 func (handler *IntOidHandler) Set(vb_base Varbind) error {
 	if !handler.writable {
-		return objectNotWriteableError{}
+		return objectNotWriteableError{vb_base.GetOid()}
 	}
 	vb, ok := vb_base.(*IntegerVarbind)
 	if !ok {
@@ -68,7 +76,8 @@ func (handler *IntOidHandler) Set(vb_base Varbind) error {
 // transaction based updates of that value. This is also the correct simple handler for a string value
 type OctetStringOidHandler struct {
 	basicOidHandler
-	val []byte
+	val     []byte
+	prevVal []byte
 }
 
 func NewStringOidHandler(val string, writable bool) *OctetStringOidHandler {
@@ -89,26 +98,41 @@ func (handler *OctetStringOidHandler) Get(oid ObjectIdentifier, txn interface{})
 	return NewOctetStringVarbind(oid, handler.val), nil
 }
 
-func (handler *OctetStringOidHandler) Set(vb_base Varbind, txn interface{}) (Varbind, error) {
+func (handler *OctetStringOidHandler) ValidateSet(vb_base Varbind, txn interface{}) error {
 	if !handler.writable {
-		return nil, objectNotWriteableError{}
+		return objectNotWriteableError{vb_base.GetOid()}
 	}
-	vb, ok := vb_base.(*OctetStringVarbind)
-	if !ok {
-		return nil, incorrectVarbindTypeError{vb_base, new(OctetStringVarbind)}
+	if _, ok := vb_base.(*OctetStringVarbind); !ok {
+		return incorrectVarbindTypeError{vb_base, new(OctetStringVarbind)}
 	}
+	return nil
+}
+
+func (handler *OctetStringOidHandler) Set(vb_base Varbind, txn interface{}) (Varbind, error) {
+	if err := handler.ValidateSet(vb_base, txn); err != nil {
+		return nil, err
+	}
+	vb := vb_base.(*OctetStringVarbind)
 	if vb.Value == nil {
 		panic(fmt.Sprintf("value must be specified: GetOid(): %v", vb.GetOid()))
 	}
+	handler.prevVal = handler.val
 	handler.val = vb.Value
 	return vb, nil
 }
 
+// UndoSet restores the value replaced by the last Set
+func (handler *OctetStringOidHandler) UndoSet(vb_base Varbind, txn interface{}) error {
+	handler.val = handler.prevVal
+	return nil
+}
+
 // ObjectIdentifierOidHandler implements a very simple handler serving up a single ObjectIdentifer variable, and allowing non-
 // transaction based updates of that value.
 type ObjectIdentifierOidHandler struct {
 	basicOidHandler
-	val ObjectIdentifier
+	val     ObjectIdentifier
+	prevVal ObjectIdentifier
 }
 
 func NewObjectIdentifierOidHandler(val ObjectIdentifier, writable bool) *ObjectIdentifierOidHandler {
@@ -125,17 +149,31 @@ func (handler *ObjectIdentifierOidHandler) Get(oid ObjectIdentifier, txn interfa
 	return NewObjectIdentifierVarbind(oid, handler.val), nil
 }
 
-func (handler *ObjectIdentifierOidHandler) Set(vb_base Varbind, txn interface{}) (Varbind, error) {
+func (handler *ObjectIdentifierOidHandler) ValidateSet(vb_base Varbind, txn interface{}) error {
 	if !handler.writable {
-		return nil, objectNotWriteableError{}
+		return objectNotWriteableError{vb_base.GetOid()}
 	}
-	vb, ok := vb_base.(*ObjectIdentifierVarbind)
-	if !ok {
-		return nil, incorrectVarbindTypeError{vb_base, new(ObjectIdentifierVarbind)}
+	if _, ok := vb_base.(*ObjectIdentifierVarbind); !ok {
+		return incorrectVarbindTypeError{vb_base, new(ObjectIdentifierVarbind)}
+	}
+	return nil
+}
+
+func (handler *ObjectIdentifierOidHandler) Set(vb_base Varbind, txn interface{}) (Varbind, error) {
+	if err := handler.ValidateSet(vb_base, txn); err != nil {
+		return nil, err
 	}
+	vb := vb_base.(*ObjectIdentifierVarbind)
 	if vb.Value == nil {
 		panic(fmt.Sprintf("value must be specified: GetOid(): %v", vb.GetOid()))
 	}
+	handler.prevVal = handler.val
 	handler.val = vb.Value
 	return vb, nil
 }
+
+// UndoSet restores the value replaced by the last Set
+func (handler *ObjectIdentifierOidHandler) UndoSet(vb_base Varbind, txn interface{}) error {
+	handler.val = handler.prevVal
+	return nil
+}
@@ -0,0 +1,315 @@
+package gosnmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sync/atomic"
+)
+
+// testTxnProvider counts the transactions it's asked to commit and abort, and fails commits when failCommit is set. The
+// agent uses it from its own goroutine, so everything is accessed atomically.
+type testTxnProvider struct {
+	failCommit int32
+	commits    int32
+	aborts     int32
+}
+
+func (provider *testTxnProvider) StartTxn() interface{} {
+	return 0
+}
+
+func (provider *testTxnProvider) CommitTxn(interface{}) bool {
+	if atomic.LoadInt32(&provider.failCommit) != 0 {
+		atomic.AddInt32(&provider.aborts, 1)
+		return false
+	}
+	atomic.AddInt32(&provider.commits, 1)
+	return true
+}
+
+func (provider *testTxnProvider) AbortTxn(interface{}) {
+	atomic.AddInt32(&provider.aborts, 1)
+}
+
+type testHandlerError struct {
+	errorVal SnmpRequestErrorType
+}
+
+func (e testHandlerError) Error() string {
+	return fmt.Sprintf("test handler error %d", e.errorVal)
+}
+
+func (e testHandlerError) ErrorStatus() SnmpRequestErrorType {
+	return e.errorVal
+}
+
+// testStringHandler serves a single string, which can be set if the handler is writable. Sets are applied immediately,
+// and can be undone unless failUndo is set.
+type testStringHandler struct {
+	val      string
+	prevVal  string
+	writable bool
+	failUndo bool
+}
+
+func (handler *testStringHandler) Get(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	return NewStringVarbind(oid, handler.val), nil
+}
+
+func (handler *testStringHandler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	if !handler.writable {
+		return nil, readOnlyObjectError{vb.GetOid()}
+	}
+	stringVb, ok := vb.(*OctetStringVarbind)
+	if !ok {
+		return nil, testHandlerError{SnmpRequestErrorType_WRONG_TYPE}
+	}
+	handler.prevVal = handler.val
+	handler.val = string(stringVb.Value)
+	return vb, nil
+}
+
+func (handler *testStringHandler) UndoSet(vb Varbind, txn interface{}) error {
+	if handler.failUndo {
+		return errors.New("undo failed")
+	}
+	handler.val = handler.prevVal
+	return nil
+}
+
+// testColumnHandler serves a column of numRows string instances, indexed from 1
+type testColumnHandler struct {
+	oid     ObjectIdentifier
+	numRows uint32
+}
+
+func (handler *testColumnHandler) Get(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	if len(oid) != len(handler.oid)+1 || oid[len(oid)-1] < 1 || oid[len(oid)-1] > handler.numRows {
+		return NewNoSuchInstanceVarbindVarbind(oid), nil
+	}
+	return NewStringVarbind(oid, fmt.Sprintf("row %d", oid[len(oid)-1])), nil
+}
+
+func (handler *testColumnHandler) Set(vb Varbind, txn interface{}) (Varbind, error) {
+	return nil, readOnlyObjectError{vb.GetOid()}
+}
+
+func (handler *testColumnHandler) GetNext(oid ObjectIdentifier, txn interface{}) (Varbind, error) {
+	var row uint32 = 1
+	if oid.MatchLength(handler.oid) == len(handler.oid) && len(oid) > len(handler.oid) {
+		row = oid[len(handler.oid)] + 1
+	} else if oid.Compare(handler.oid) > 0 {
+		return nil, nil
+	}
+	if row > handler.numRows {
+		return nil, nil
+	}
+	return handler.Get(childOid(handler.oid, row), txn)
+}
+
+// childOid returns a new oid made of oid followed by subIds
+func childOid(oid ObjectIdentifier, subIds ...uint32) ObjectIdentifier {
+	return append(append(ObjectIdentifier{}, oid...), subIds...)
+}
+
+func SetupAgentTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Agent", func() {
+		var (
+			agent       *Agent
+			txnProvider *testTxnProvider
+			clientCtxt  *ClientContext
+			client      *V2cClient
+		)
+		ifDescrOid := ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 2}
+		BeforeEach(func() {
+			txnProvider = new(testTxnProvider)
+			agent = NewAgentWithPort(<-testIdGenerator, 10, 2170, logger, txnProvider)
+			agent.RegisterSingleVarOidHandler(SYS_DESCR_OID, &testStringHandler{val: "descr"})
+			agent.RegisterSingleVarOidHandler(SYS_NAME_OID, &testStringHandler{val: "name"})
+			agent.RegisterMultiVarOidHandler(ifDescrOid, &testColumnHandler{ifDescrOid, 3})
+			agent.RegisterMultiVarOidHandler(testIfInOctetsOid, &testColumnHandler{testIfInOctetsOid, 0})
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2170)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			agent.Shutdown()
+		})
+		getNext := func(oids ...ObjectIdentifier) SnmpResponse {
+			req := clientCtxt.AllocateV2cGetNextRequest()
+			for _, oid := range oids {
+				req.AddOid(oid)
+			}
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response()
+		}
+
+		getBulk := func(nonRepeaters int, maxRepetitions int, oids ...ObjectIdentifier) SnmpResponse {
+			req := clientCtxt.AllocateV2cGetBulkRequest(nonRepeaters, maxRepetitions)
+			req.AddOids(oids)
+			client.SendRequest(req)
+			Ω(req.TransportError()).Should(BeNil())
+			return req.Response()
+		}
+		oidsOf := func(varbinds []Varbind) []ObjectIdentifier {
+			oids := make([]ObjectIdentifier, len(varbinds))
+			for i, vb := range varbinds {
+				oids[i] = vb.GetOid()
+			}
+			return oids
+		}
+
+		Describe("answering a GetNext", func() {
+			It("should return the next instance in the same handler", func() {
+				vb := getNext(childOid(ifDescrOid, 1)).Varbinds()[0]
+				Ω(vb.GetOid()).Should(Equal(childOid(ifDescrOid, 2)))
+			})
+			It("should move across handler boundaries", func() {
+				varbinds := getNext(testSystemOid, SYS_DESCR_OID, childOid(ifDescrOid, 3)).Varbinds()
+				Ω(varbinds[0].GetOid()).Should(Equal(SYS_DESCR_OID))
+				Ω(varbinds[1].GetOid()).Should(Equal(SYS_NAME_OID))
+				Ω(string(varbinds[1].(*OctetStringVarbind).Value)).Should(Equal("name"))
+				// the ifInOctets column is empty, so the search runs off the end of the tree
+				Ω(varbinds[2]).Should(BeAssignableToTypeOf(new(EndOfMibViewVarbind)))
+			})
+			It("should return the first instance of a subtree for an oid that comes before it", func() {
+				vb := getNext(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2}).Varbinds()[0]
+				Ω(vb.GetOid()).Should(Equal(childOid(ifDescrOid, 1)))
+				Ω(string(vb.(*OctetStringVarbind).Value)).Should(Equal("row 1"))
+			})
+			It("should skip instances outside the view", func() {
+				vacm := NewVacm()
+				vacm.AddView("public").Include(ObjectIdentifier{1}, nil).Exclude(SYS_NAME_OID, nil).Exclude(childOid(ifDescrOid, 1), nil)
+				vacm.AddGroupMember(SecurityModel_V2C, "public", "readers")
+				vacm.AddAccess(VacmAccess{Group: "readers", SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV, ReadView: "public"})
+				agent.SetVacm(vacm)
+				varbinds := getNext(SYS_DESCR_OID).Varbinds()
+				Ω(varbinds[0].GetOid()).Should(Equal(childOid(ifDescrOid, 2)))
+			})
+			It("should support walking the whole tree", func() {
+				var oids []ObjectIdentifier
+				Ω(client.Walk(ObjectIdentifier{1, 3}, func(vb Varbind) error {
+					oids = append(oids, vb.GetOid())
+					return nil
+				})).Should(BeNil())
+				Ω(oids).Should(Equal([]ObjectIdentifier{SYS_DESCR_OID, SYS_NAME_OID,
+					childOid(ifDescrOid, 1), childOid(ifDescrOid, 2), childOid(ifDescrOid, 3)}))
+			})
+		})
+
+		Describe("answering a GetBulk", func() {
+			It("should answer the non-repeaters once, and repeat the rest", func() {
+				varbinds := getBulk(1, 2, testSystemOid, ifDescrOid, childOid(ifDescrOid, 2)).Varbinds()
+				Ω(oidsOf(varbinds)).Should(Equal([]ObjectIdentifier{SYS_DESCR_OID,
+					childOid(ifDescrOid, 1), childOid(ifDescrOid, 3),
+					childOid(ifDescrOid, 2), childOid(ifDescrOid, 3)}))
+				Ω(varbinds[4]).Should(BeAssignableToTypeOf(new(EndOfMibViewVarbind)))
+			})
+			It("should stop repeating once every oid has reached the end of the mib view", func() {
+				varbinds := getBulk(0, 10, childOid(ifDescrOid, 2)).Varbinds()
+				Ω(oidsOf(varbinds)).Should(Equal([]ObjectIdentifier{childOid(ifDescrOid, 3), childOid(ifDescrOid, 3)}))
+				Ω(varbinds[1]).Should(BeAssignableToTypeOf(new(EndOfMibViewVarbind)))
+			})
+			It("should truncate the response to fit in the requester's buffer", func() {
+				ifNameOid := ObjectIdentifier{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 1}
+				agent.RegisterMultiVarOidHandler(ifNameOid, &testColumnHandler{ifNameOid, 500})
+				resp := getBulk(0, 500, ifNameOid)
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ERROR)))
+				varbinds := resp.Varbinds()
+				Ω(len(varbinds)).Should(BeNumerically(">", 50))
+				Ω(len(varbinds)).Should(BeNumerically("<", 500))
+				for i, vb := range varbinds {
+					Ω(vb.GetOid()).Should(Equal(childOid(ifNameOid, uint32(i+1))))
+				}
+				encoded, err := resp.(*communityResponse).encode(agent.berEncoderFactory)
+				Ω(err).Should(BeNil())
+				Ω(len(encoded)).Should(BeNumerically("<=", v3MsgMaxSize))
+			})
+			It("should support bulk walking the whole tree", func() {
+				var oids []ObjectIdentifier
+				Ω(client.BulkWalk(ObjectIdentifier{1, 3}, 2, func(vb Varbind) error {
+					oids = append(oids, vb.GetOid())
+					return nil
+				})).Should(BeNil())
+				Ω(oids).Should(Equal([]ObjectIdentifier{SYS_DESCR_OID, SYS_NAME_OID,
+					childOid(ifDescrOid, 1), childOid(ifDescrOid, 2), childOid(ifDescrOid, 3)}))
+			})
+		})
+
+		Describe("applying a SET", func() {
+			BeforeEach(func() {
+				agent.RegisterSingleVarOidHandler(SYS_CONTACT_OID, &testStringHandler{val: "contact", writable: true})
+				agent.RegisterSingleVarOidHandler(SYS_LOCATION_OID, &testStringHandler{val: "location", writable: true})
+			})
+			set := func(varbinds ...Varbind) SnmpResponse {
+				req := clientCtxt.AllocateV2cSetRequest()
+				for _, vb := range varbinds {
+					req.(*communityRequest).AddVarbind(vb)
+				}
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				return req.Response()
+			}
+			get := func(oid ObjectIdentifier) string {
+				req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{oid})
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				return string(req.Response().Varbinds()[0].(*OctetStringVarbind).Value)
+			}
+			It("should apply every varbind and commit the transaction", func() {
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewStringVarbind(SYS_LOCATION_OID, "new location"))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ERROR)))
+				Ω(resp.Varbinds()).Should(HaveLen(2))
+				Ω(get(SYS_CONTACT_OID)).Should(Equal("new contact"))
+				Ω(get(SYS_LOCATION_OID)).Should(Equal("new location"))
+				Ω(atomic.LoadInt32(&txnProvider.commits)).Should(Equal(int32(1)))
+			})
+			It("should report the handler's error, and undo the varbinds already set", func() {
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewIntegerVarbind(SYS_LOCATION_OID, 42))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_WRONG_TYPE)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(2)))
+				Ω(get(SYS_CONTACT_OID)).Should(Equal("contact"))
+				Ω(atomic.LoadInt32(&txnProvider.commits)).Should(Equal(int32(0)))
+			})
+			It("should refuse to set read-only objects", func() {
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewStringVarbind(SYS_NAME_OID, "new name"))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NOT_WRITABLE)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(2)))
+				Ω(get(SYS_CONTACT_OID)).Should(Equal("contact"))
+			})
+			It("should refuse to create objects that no handler serves", func() {
+				resp := set(NewStringVarbind(ObjectIdentifier{1, 3, 6, 1, 4, 1, 424242, 1}, "new"))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_CREATION)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(1)))
+			})
+			It("should refuse to set the same object twice", func() {
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewStringVarbind(SYS_LOCATION_OID, "new location"),
+					NewStringVarbind(SYS_CONTACT_OID, "newer contact"))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_INCONSISTENT_VALUE)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(3)))
+				Ω(get(SYS_CONTACT_OID)).Should(Equal("contact"))
+				Ω(get(SYS_LOCATION_OID)).Should(Equal("location"))
+				Ω(atomic.LoadInt32(&txnProvider.commits)).Should(Equal(int32(0)))
+			})
+			It("should undo every varbind when the transaction can't be committed", func() {
+				atomic.StoreInt32(&txnProvider.failCommit, 1)
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewStringVarbind(SYS_LOCATION_OID, "new location"))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_COMMIT_FAILED)))
+				Ω(get(SYS_CONTACT_OID)).Should(Equal("contact"))
+				Ω(get(SYS_LOCATION_OID)).Should(Equal("location"))
+			})
+			It("should report a failed undo", func() {
+				agent.RegisterSingleVarOidHandler(SYS_CONTACT_OID, &testStringHandler{val: "contact", writable: true, failUndo: true})
+				resp := set(NewStringVarbind(SYS_CONTACT_OID, "new contact"), NewIntegerVarbind(SYS_LOCATION_OID, 42))
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_UNDO_FAILED)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(0)))
+			})
+		})
+	})
+}
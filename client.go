@@ -1,11 +1,115 @@
 package gosnmp
 
+import (
+	"net"
+	"sync"
+)
+
 type ClientContext struct {
 	snmpContext
 }
 
 func NewClientContext(name string, maxTargets int, logger Logger) *ClientContext {
 	client := new(ClientContext)
+	client.usmUsers = newUsmUserTable()
 	client.snmpContext.initContext(name, maxTargets, true, 0, logger)
 	return client
 }
+
+// NewV1Trap creates a v1 trap with the given Trap-PDU fields. Varbinds can be added with AddVarbind before the trap is sent
+// using SendV1Trap.
+func (ctxt *ClientContext) NewV1Trap(enterprise ObjectIdentifier, agentAddr net.IP, genericTrap GenericTrapType, specificTrap int32, timeStamp uint32) *V1Trap {
+	trap := new(V1Trap)
+	trap.version = Version1
+	trap.pduType = pduType_V1_TRAP
+	trap.enterprise = enterprise
+	trap.agentAddr = agentAddr
+	trap.genericTrap = genericTrap
+	trap.specificTrap = specificTrap
+	trap.timeStamp = timeStamp
+	return trap
+}
+
+// SendV1Trap sends a trap to the given address using the given community. Traps aren't acknowledged, so SendV1Trap returns
+// as soon as the trap has been queued for transmission.
+func (ctxt *ClientContext) SendV1Trap(trap *V1Trap, community string, address *net.UDPAddr) {
+	trap.setCommunity(community)
+	trap.setAddress(address)
+	ctxt.sendTrap(trap)
+}
+
+// NewV2Trap creates an SNMPv2-Trap with its sysUpTime.0 and snmpTrapOID.0 varbinds filled in. Further varbinds can be added
+// with AddVarbind before the trap is sent using SendV2Trap.
+func (ctxt *ClientContext) NewV2Trap(sysUpTime uint32, trapOid ObjectIdentifier) *V2Trap {
+	return newV2Trap(Version2c, sysUpTime, trapOid)
+}
+
+// SendV2Trap sends a trap to the given address using the given community. Traps aren't acknowledged, so SendV2Trap returns
+// as soon as the trap has been queued for transmission.
+func (ctxt *ClientContext) SendV2Trap(trap *V2Trap, community string, address *net.UDPAddr) {
+	trap.setCommunity(community)
+	trap.setAddress(address)
+	ctxt.sendTrap(trap)
+}
+
+// NewV2cInformRequest creates an InformRequest with its sysUpTime.0 and snmpTrapOID.0 varbinds filled in. Further varbinds
+// can be added with AddVarbind. Informs are sent using V2cClient.SendRequest, and on return the request will either have
+// the receiver's acknowledgement attached as its response, or its error field will be filled in.
+func (ctxt *ClientContext) NewV2cInformRequest(sysUpTime uint32, trapOid ObjectIdentifier) *InformRequest {
+	return newInformRequest(Version2c, sysUpTime, trapOid)
+}
+
+// NewV2cReport creates an empty Report PDU. Varbinds can be added with AddVarbind before the report is sent using SendReport.
+func (ctxt *ClientContext) NewV2cReport(requestId uint32) *Report {
+	return newReport(Version2c, requestId)
+}
+
+// SendReport sends a report to the given address using the given community.
+func (ctxt *ClientContext) SendReport(report *Report, community string, address *net.UDPAddr) {
+	report.setCommunity(community)
+	report.setAddress(address)
+	ctxt.sendMessage(report)
+}
+
+// requestWindow limits the number of asynchronous requests a client has in flight at once. The zero value is an empty
+// window.
+type requestWindow struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	inFlight int
+}
+
+// acquire waits until fewer than size requests are in flight, and then counts one more. A size of less than 1 is treated
+// as 1.
+func (window *requestWindow) acquire(size int) {
+	window.lock.Lock()
+	defer window.lock.Unlock()
+	if window.cond == nil {
+		window.cond = sync.NewCond(&window.lock)
+	}
+	for window.inFlight >= size && window.inFlight > 0 {
+		window.cond.Wait()
+	}
+	window.inFlight++
+}
+
+// release counts a request that is no longer in flight.
+func (window *requestWindow) release() {
+	window.lock.Lock()
+	defer window.lock.Unlock()
+	window.inFlight--
+	window.cond.Broadcast()
+}
+
+// sendAsync sends a request that has been set up by a client, and calls callback from a new goroutine once the request
+// completes. The request is tracked by the context just like one sent with SendRequest.
+func (ctxt *snmpContext) sendAsync(req CommunityRequest, window *requestWindow, callback func(CommunityRequest)) {
+	ctxt.sendRequest(req)
+	go func() {
+		req.wait()
+		window.release()
+		if callback != nil {
+			callback(req)
+		}
+	}()
+}
@@ -5,6 +5,7 @@ type SnmpVersion int
 const (
 	Version1  SnmpVersion = 0x00
 	Version2c             = 0x01
+	Version3              = 0x03
 )
 
 func (version SnmpVersion) String() string {
@@ -13,6 +14,8 @@ func (version SnmpVersion) String() string {
 		return "SNMPv1"
 	case Version2c:
 		return "SNMPv2c"
+	case Version3:
+		return "SNMPv3"
 	default:
 		return "Unknown"
 	}
@@ -64,3 +67,37 @@ const (
 	SnmpRequestErrorType_INCONSISTENT_NAME                         = 18
 	SnmpRequestErrorType_MAX                                       = 18
 )
+
+type GenericTrapType int32
+
+const (
+	GenericTrapType_COLD_START             GenericTrapType = 0
+	GenericTrapType_WARM_START                             = 1
+	GenericTrapType_LINK_DOWN                              = 2
+	GenericTrapType_LINK_UP                                = 3
+	GenericTrapType_AUTHENTICATION_FAILURE                 = 4
+	GenericTrapType_EGP_NEIGHBOR_LOSS                      = 5
+	GenericTrapType_ENTERPRISE_SPECIFIC                    = 6
+	GenericTrapType_MAX                                    = 6
+)
+
+func (trapType GenericTrapType) String() string {
+	switch trapType {
+	case GenericTrapType_COLD_START:
+		return "coldStart"
+	case GenericTrapType_WARM_START:
+		return "warmStart"
+	case GenericTrapType_LINK_DOWN:
+		return "linkDown"
+	case GenericTrapType_LINK_UP:
+		return "linkUp"
+	case GenericTrapType_AUTHENTICATION_FAILURE:
+		return "authenticationFailure"
+	case GenericTrapType_EGP_NEIGHBOR_LOSS:
+		return "egpNeighborLoss"
+	case GenericTrapType_ENTERPRISE_SPECIFIC:
+		return "enterpriseSpecific"
+	default:
+		return "Unknown"
+	}
+}
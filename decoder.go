@@ -40,11 +40,16 @@ func (decoder *berDecoder) decodeLength() (int, error) {
 		return 0, fmt.Errorf("Couldn't read byte at pos %d, err: %s", decoder.pos, err)
 	}
 	decoder.pos++
-	if firstByte < 127 {
+	if firstByte < 128 {
 		length = int(firstByte)
 		return length, nil
 	}
-	for numBytes := firstByte; numBytes > 0; numBytes-- {
+	// long form: the low 7 bits hold the number of length bytes that follow
+	numLengthBytes := firstByte & 0x7f
+	if numLengthBytes == 0 || numLengthBytes > 4 {
+		return 0, fmt.Errorf("Unsupported number of length bytes %d at pos %d", numLengthBytes, decoder.pos)
+	}
+	for numBytes := numLengthBytes; numBytes > 0; numBytes-- {
 		temp, err := decoder.ReadByte()
 		if err != nil {
 			return 0, fmt.Errorf("Couldn't read byte at pos %d, err: %s", decoder.pos, err)
@@ -68,7 +73,7 @@ func (decoder *berDecoder) decodeValue() (snmpBlockType, interface{}, error) {
 	var value interface{}
 	switch valueType {
 	case snmpBlockType_INTEGER:
-		value, err = decoder.decodeInteger(valueLength)
+		value, err = decoder.decodeInt32(valueLength)
 	case snmpBlockType_BIT_STRING:
 		value, err = decoder.decodeBitString(valueLength)
 	case snmpBlockType_OCTET_STRING:
@@ -81,20 +86,17 @@ func (decoder *berDecoder) decodeValue() (snmpBlockType, interface{}, error) {
 		return 0, nil, fmt.Errorf("Unexpected value type snmpBlockType_SEQUENCE 0x%x at pos %d", valueType, decoder.pos)
 	case snmpBlockType_IP_ADDRESS:
 		value, err = decoder.decodeIPv4Address(valueLength)
-	case snmpBlockType_COUNTER_32:
-		// value, err = decoder.decodeCounter32(valueLength)
-	case snmpBlockType_GAUGE_32:
-		// value, err = decoder.decodeGauge32(valueLength)
-	case snmpBlockType_TIME_TICKS:
-		// value, err = decoder.decodeTimeTicks(valueLength)
+	case snmpBlockType_COUNTER_32, snmpBlockType_GAUGE_32, snmpBlockType_TIME_TICKS, snmpBlockType_UINT_32:
+		value, err = decoder.decodeUnsigned32(valueLength)
 	case snmpBlockType_OPAQUE:
-		// value, err = decoder.decodeOpaque(valueLength)
+		value, err = decoder.decodeOpaque(valueLength)
 	case snmpBlockType_COUNTER_64:
-		// value, err = decoder.decodeCounter64(valueLength)
-	case snmpBlockType_UINT_32:
-		// value, err = decoder.decodeUint32(valueLength)
+		value, err = decoder.decodeUnsigned64(valueLength)
 	default:
 		return 0, nil, fmt.Errorf("Unknown value type 0x%x", valueType)
 	}
+	if err != nil {
+		return 0, nil, err
+	}
 	return valueType, value, nil
 }
@@ -56,6 +56,16 @@ func (encoder *berEncoder) destroy() {
 	encoder.bufChain.Init() // make sure to release references
 }
 
+// offsetOf returns the position that the contents of buf will have in the serialized message. It must be called before
+// serialize, which empties the buffers.
+func (encoder *berEncoder) offsetOf(buf *bytes.Buffer) int {
+	offset := 0
+	for e := encoder.bufChain.Front(); e != nil && e.Value.(*bytes.Buffer) != buf; e = e.Next() {
+		offset += e.Value.(*bytes.Buffer).Len()
+	}
+	return offset
+}
+
 func (e *berEncoder) append() *bytes.Buffer {
 	buf := e.bufPool.getBuffer()
 	e.bufChain.PushBack(buf)
@@ -0,0 +1,290 @@
+package gosnmp
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// engineTimeWindow is the number of seconds by which a message's engineTime may lag behind our notion of the
+// authoritative engine's time, as described in RFC 3414 section 3.2.7.
+const engineTimeWindow = 150
+
+// ReportError is set as the transport error of an SNMPv3 request that was answered with a Report PDU that the request
+// tracker couldn't recover from, and that didn't come from USM. Reports from USM are turned into a UsmError instead.
+type ReportError struct {
+	Oid ObjectIdentifier
+}
+
+func (e ReportError) Error() string {
+	return fmt.Sprintf("Agent sent a report for %v", e.Oid)
+}
+
+// remoteEngine is what a client context knows about the authoritative SNMPv3 engine at one target address. Its id, boots
+// and time are read when requests are encoded, so they are protected by the lock. The rest is only used by the request
+// tracker.
+type remoteEngine struct {
+	lock                     sync.Mutex
+	engineId                 []byte
+	engineBoots              int32
+	engineTime               int32
+	timeBaseline             time.Time
+	latestReceivedEngineTime int32
+
+	discoveryProbe  *communityRequest
+	waitingRequests []SnmpRequest
+}
+
+// current returns the engine's id, and its boots and time as they should be sent in a new request.
+func (engine *remoteEngine) current() (engineId []byte, engineBoots int32, engineTime int32) {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	return engine.engineId, engine.engineBoots, engine.currentTime()
+}
+
+// currentTime advances the last engineTime we were sent by the time that has passed since. The lock must be held.
+func (engine *remoteEngine) currentTime() int32 {
+	return engine.engineTime + int32(time.Since(engine.timeBaseline).Seconds())
+}
+
+func (engine *remoteEngine) isKnown() bool {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	return len(engine.engineId) > 0
+}
+
+// reset replaces everything known about the engine with the values from a report
+func (engine *remoteEngine) reset(engineId []byte, engineBoots int32, engineTime int32) {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	engine.engineId = engineId
+	engine.engineBoots = engineBoots
+	engine.engineTime = engineTime
+	engine.latestReceivedEngineTime = engineTime
+	engine.timeBaseline = time.Now()
+}
+
+// synchronize processes the engineBoots and engineTime of an authenticated message from the engine, as described in RFC
+// 3414 section 3.2.7b. Our notion of the engine's time is moved forward if the message shows that it's fallen behind, and
+// then the message is checked against the time window. It returns false if the message is outside the window.
+func (engine *remoteEngine) synchronize(engineBoots int32, engineTime int32) bool {
+	engine.lock.Lock()
+	defer engine.lock.Unlock()
+	if engineBoots > engine.engineBoots || (engineBoots == engine.engineBoots && engineTime > engine.latestReceivedEngineTime) {
+		engine.engineBoots = engineBoots
+		engine.engineTime = engineTime
+		engine.latestReceivedEngineTime = engineTime
+		engine.timeBaseline = time.Now()
+	}
+	if engine.engineBoots == math.MaxInt32 || engineBoots < engine.engineBoots {
+		return false
+	}
+	return engineTime >= engine.currentTime()-engineTimeWindow
+}
+
+// newDiscoveryProbe creates the request used to discover the engine for a v3 request: an unauthenticated, reportable
+// GetRequest with no varbinds, sent from an unknown engine and user. The agent answers it with a usmStatsUnknownEngineIDs
+// report carrying its engine's id, boots and time. It's timed out and retried like the request that triggered the
+// discovery.
+func newDiscoveryProbe(req SnmpRequest) *communityRequest {
+	probe := newCommunityRequest()
+	probe.version = Version3
+	probe.pduType = pduType_GET_REQUEST
+	probe.address = req.Address()
+	probe.v3 = &v3Params{msgMaxSize: v3MsgMaxSize, flags: v3MsgFlags_REPORTABLE}
+	probe.timeout = 10 * time.Second
+	probe.retriesRemaining = 2
+	if triggeringReq, ok := req.(*communityRequest); ok {
+		probe.timeout = triggeringReq.timeout
+		probe.retriesRemaining = triggeringReq.retriesRemaining
+		probe.retryPolicy = triggeringReq.retryPolicy
+	}
+	return probe
+}
+
+//
+//
+//
+//
+// ******************************************************************
+// ----------------------- Request tracker support -------------------
+//
+// Everything below runs on the request tracker's goroutine.
+
+// trackV3Request attaches the target's engine to a v3 request. It returns false if the engine still has to be discovered,
+// in which case the request is held back until discovery completes.
+func (ctxt *snmpContext) trackV3Request(req SnmpRequest) bool {
+	params := req.(snmpCommunityMessage).getV3Params()
+	key := req.Address().String()
+	engine := ctxt.remoteEngines[key]
+	if engine == nil {
+		engine = new(remoteEngine)
+		ctxt.remoteEngines[key] = engine
+	}
+	params.engine = engine
+	params.resentAfterReport = false
+	if engine.isKnown() {
+		return true
+	}
+	engine.waitingRequests = append(engine.waitingRequests, req)
+	if engine.discoveryProbe == nil {
+		engine.discoveryProbe = newDiscoveryProbe(req)
+		ctxt.lastRequestId += 1
+		engine.discoveryProbe.setRequestId(ctxt.lastRequestId)
+		ctxt.discoveryProbes[ctxt.lastRequestId] = engine
+		ctxt.incrementStat(StatType_ENGINE_DISCOVERY_PROBES_SENT)
+		ctxt.forwardRequest(engine.discoveryProbe)
+	}
+	return false
+}
+
+// releaseWaitingV3Request removes a v3 request from the requests waiting for their engine to be discovered. It returns
+// false if the request wasn't waiting, which means that it has been sent and its timer is running.
+func (ctxt *snmpContext) releaseWaitingV3Request(req SnmpRequest) bool {
+	if req.getVersion() != Version3 {
+		return false
+	}
+	engine := req.(snmpCommunityMessage).getV3Params().engine
+	if engine == nil {
+		return false
+	}
+	for i, waitingReq := range engine.waitingRequests {
+		if waitingReq == req {
+			engine.waitingRequests = append(engine.waitingRequests[:i:i], engine.waitingRequests[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// forwardRequest starts the request's timer and queues it for transmission
+func (ctxt *snmpContext) forwardRequest(req SnmpRequest) {
+	req.startTimer(ctxt.handleRequestTimeout)
+	ctxt.incrementStat(StatType_REQUESTS_FORWARDED_TO_FLOW_CONTROL)
+	ctxt.outboundFlowControlQueue <- req
+}
+
+// processReport handles a report sent in answer to one of our v3 requests. The report carries the msgID of the message it
+// answers, which is always the request-id of one of our requests.
+func (ctxt *snmpContext) processReport(report *Report) {
+	params := report.getV3Params()
+	if params == nil {
+		ctxt.incrementStat(StatType_REPORTS_DROPPED_BY_REQUEST_TRACKER)
+		return
+	}
+	requestId := uint32(params.msgId)
+	if engine := ctxt.discoveryProbes[requestId]; engine != nil {
+		ctxt.completeEngineDiscovery(engine, report)
+		return
+	}
+	req := ctxt.outstandingRequests[requestId]
+	if req == nil || req.getVersion() != Version3 {
+		ctxt.incrementStat(StatType_REPORTS_DROPPED_BY_REQUEST_TRACKER)
+		return
+	}
+	reqParams := req.(snmpCommunityMessage).getV3Params()
+	usmErrorType, isUsmError := report.UsmError()
+	if isUsmError && !reqParams.resentAfterReport && len(params.engineId) > 0 {
+		switch {
+		case usmErrorType == UsmErrorType_UNKNOWN_ENGINE_ID:
+			// the agent's engine has changed since we discovered it
+			reqParams.engine.reset(params.engineId, params.engineBoots, params.engineTime)
+			ctxt.resendAfterReport(req)
+			return
+		case usmErrorType == UsmErrorType_NOT_IN_TIME_WINDOW && params.isAuthenticated():
+			// RFC 3414 section 4: the report is authenticated, so its boots and time can be trusted
+			reqParams.engine.reset(params.engineId, params.engineBoots, params.engineTime)
+			ctxt.resendAfterReport(req)
+			return
+		}
+	}
+	delete(ctxt.outstandingRequests, requestId)
+	req.stopTimer()
+	if isUsmError {
+		req.setTransportError(UsmError{Type: usmErrorType, details: "reported by " + report.Address().String()})
+	} else if len(report.varbinds) > 0 {
+		req.setTransportError(ReportError{report.varbinds[0].GetOid()})
+	} else {
+		req.setTransportError(ReportError{})
+	}
+	ctxt.incrementStat(StatType_REQUESTS_FAILED_BY_REPORT)
+	req.notify()
+}
+
+// resendAfterReport sends a request again once the report it caused has been dealt with. Only one resend is allowed, so
+// that a misbehaving agent can't keep a request alive forever. Resends don't use up the request's retries.
+func (ctxt *snmpContext) resendAfterReport(req SnmpRequest) {
+	req.(snmpCommunityMessage).getV3Params().resentAfterReport = true
+	req.stopTimer()
+	ctxt.incrementStat(StatType_REQUESTS_RESENT_AFTER_REPORT)
+	ctxt.forwardRequest(req)
+}
+
+// completeEngineDiscovery records the engine described by the report answering a discovery probe, and releases the
+// requests that were waiting for it.
+func (ctxt *snmpContext) completeEngineDiscovery(engine *remoteEngine, report *Report) {
+	params := report.getV3Params()
+	if len(params.engineId) == 0 {
+		ctxt.incrementStat(StatType_REPORTS_DROPPED_BY_REQUEST_TRACKER)
+		return
+	}
+	probe := engine.discoveryProbe
+	delete(ctxt.discoveryProbes, probe.getRequestId())
+	probe.stopTimer()
+	engine.discoveryProbe = nil
+	engine.reset(params.engineId, params.engineBoots, params.engineTime)
+	ctxt.incrementStat(StatType_ENGINES_DISCOVERED)
+	waitingRequests := engine.waitingRequests
+	engine.waitingRequests = nil
+	for _, req := range waitingRequests {
+		ctxt.forwardRequest(req)
+	}
+}
+
+// handleDiscoveryProbeTimeout retries a discovery probe, or fails all of the requests waiting for the engine once the
+// probe's retries are used up.
+func (ctxt *snmpContext) handleDiscoveryProbeTimeout(engine *remoteEngine) {
+	probe := engine.discoveryProbe
+	if probe.isRetryRequired() {
+		ctxt.incrementStat(StatType_REQUESTS_TIMED_OUT)
+		ctxt.forwardRequest(probe)
+		return
+	}
+	delete(ctxt.discoveryProbes, probe.getRequestId())
+	probe.stopTimer()
+	engine.discoveryProbe = nil
+	ctxt.incrementStat(StatType_ENGINE_DISCOVERY_FAILURES)
+	ctxt.Debugf("Ctxt %s: engine discovery for %s timed out", ctxt.name, probe.Address())
+	waitingRequests := engine.waitingRequests
+	engine.waitingRequests = nil
+	for _, req := range waitingRequests {
+		delete(ctxt.outstandingRequests, req.getRequestId())
+		req.setTransportError(TimeoutError{})
+		ctxt.incrementStat(StatType_REQUEST_RETRIES_EXHAUSTED)
+		req.notify()
+	}
+}
+
+// checkResponseTimeliness checks an authenticated v3 response against the engine its request was sent to, keeping our
+// notion of the engine's time synchronized. It returns false if the response should be dropped.
+func (ctxt *snmpContext) checkResponseTimeliness(req SnmpRequest, resp SnmpResponse) bool {
+	if req.getVersion() != Version3 {
+		return true
+	}
+	reqParams := req.(snmpCommunityMessage).getV3Params()
+	respParams := resp.(snmpCommunityMessage).getV3Params()
+	if !respParams.isAuthenticated() || reqParams.engine == nil {
+		return true
+	}
+	engineId, _, _ := reqParams.engine.current()
+	if string(engineId) != string(respParams.engineId) {
+		ctxt.incrementStat(StatType_USM_UNKNOWN_ENGINE_IDS)
+		return false
+	}
+	if !reqParams.engine.synchronize(respParams.engineBoots, respParams.engineTime) {
+		ctxt.incrementStat(StatType_USM_NOT_IN_TIME_WINDOWS)
+		return false
+	}
+	return true
+}
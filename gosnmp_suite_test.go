@@ -37,5 +37,20 @@ func TestGosnmp(t *testing.T) {
 	}()
 	setupV2cClientTest(logger, testIdGenerator)
 	SetupLowLevelContextTest(logger, testIdGenerator)
+	setupTrapReceiverTest(logger, testIdGenerator)
+	setupNotificationOriginatorTest(logger, testIdGenerator)
+	setupRetryPolicyTest()
+	SetupVarbindCodecTest(logger)
+	SetupMsgCodecTest(logger)
+	SetupWalkTest(logger, testIdGenerator)
+	SetupTableTest(logger, testIdGenerator)
+	SetupUsmTest(logger, testIdGenerator)
+	SetupAgentUsmTest(logger, testIdGenerator)
+	SetupVacmTest(logger, testIdGenerator)
+	SetupAgentCommunityTest(logger, testIdGenerator)
+	SetupAgentTest(logger, testIdGenerator)
+	SetupAgentTableTest(logger, testIdGenerator)
+	SetupAgentRowStatusTest(logger, testIdGenerator)
+	SetupAgentNotificationTest(logger, testIdGenerator)
 	RunSpecs(t, "gosnmp Suite")
 }
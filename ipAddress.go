@@ -13,7 +13,7 @@ func (encoder *berEncoder) encodeIPv4Address(addr net.IP) (int, error) {
 	}
 	h := encoder.newHeader(snmpBlockType_IP_ADDRESS)
 	buf := encoder.append()
-	buf.Write(addr)
+	buf.Write(ipv4Addr)
 	_, encodedLength := h.setContentLength(buf.Len())
 	return encodedLength, nil
 }
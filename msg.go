@@ -31,6 +31,16 @@ func (pduType *pduType) String() string {
 		return "RESPONSE"
 	case pduType_SET_REQUEST:
 		return "SET REQUEST"
+	case pduType_V1_TRAP:
+		return "V1 TRAP"
+	case pduType_GET_BULK_REQUEST:
+		return "GET BULK REQUEST"
+	case pduType_INFORM_REQUEST:
+		return "INFORM REQUEST"
+	case pduType_V2_TRAP:
+		return "V2 TRAP"
+	case pduType_REPORT:
+		return "REPORT"
 	default:
 		return "UNKNOWN PDU TYPE"
 	}
@@ -51,17 +61,21 @@ type SnmpMessage interface {
 type SnmpRequest interface {
 	SnmpMessage
 	FlightTime() time.Duration
+	AttemptFlightTimes() []time.Duration
 	TransportError() error
 	Response() SnmpResponse
 	AddVarbind(Varbind)
 	setTransportError(err error)
 	wait()
+	done() <-chan bool
 	notify()
 	getRequestId() uint32
 	setRequestId(requestId uint32)
 	isRetryRequired() bool
-	startTimer(func(SnmpRequest))
+	startTimer(func(SnmpRequest, uint32))
 	stopTimer()
+	isCurrentAttempt(attempt uint32) bool
+	resetFlightTimes()
 	setResponse(resp SnmpResponse)
 }
 
@@ -69,8 +83,13 @@ type CommunityRequest interface {
 	SnmpRequest
 	getCommunity() string
 	setCommunity(string)
-	setTimeoutSeconds(int)
+	setV3Params(*v3Params)
+	SetTimeout(time.Duration)
+	SetRetries(int)
+	SetRetryPolicy(RetryPolicy)
+	setTimeout(time.Duration)
 	setRetriesRemaining(int)
+	setRetryPolicy(RetryPolicy)
 }
 
 type V2cGetRequest interface {
@@ -83,10 +102,17 @@ type V2cSetRequest interface {
 	CommunityRequest
 }
 
+type V2cGetBulkRequest interface {
+	V2cGetRequest
+	NonRepeaters() int32
+	MaxRepetitions() int32
+}
+
 type SnmpResponse interface {
 	SnmpMessage
 	Varbinds() []Varbind
 	ErrorVal() SnmpRequestErrorType
+	ErrorIdx() int32
 	getRequestId() uint32
 }
 
@@ -145,29 +171,30 @@ func (msg *baseMsg) decodeVarbinds(decoder *berDecoder) (err error) {
 	if varbindsListLength != decoder.Len() {
 		return fmt.Errorf("Encoded varbinds list length %d doesn't match remaining msg length %d", varbindsListLength, decoder.Len())
 	}
-	varbindCount := 1
-	for ; ; varbindCount++ {
+	for varbindCount := 1; decoder.Len() > 0; varbindCount++ {
 		varbind, err := decodeVarbind(decoder)
 		if err != nil {
 			return fmt.Errorf("Decoding of varbind %d failed - err: %s", varbindCount, err)
 		}
 		msg.varbinds = append(msg.varbinds, varbind)
-		if decoder.Len() == 0 {
-			return nil
-		}
 	}
+	return nil
 }
 
-// base type for all v1/v2c messages
+// base type for all v1/v2c messages. SNMPv3 messages carry the same PDUs, so they share these types too. Their community is
+// unused, and everything they carry outside of the PDU is held in v3 instead.
 type communityMessage struct {
 	baseMsg
 	community string
+	v3        *v3Params
 }
 
 type snmpCommunityMessage interface {
 	SnmpMessage
 	getCommunity() string
 	setCommunity(community string)
+	getV3Params() *v3Params
+	setV3Params(params *v3Params)
 }
 
 func (msg *communityMessage) getCommunity() string {
@@ -178,12 +205,29 @@ func (msg *communityMessage) setCommunity(community string) {
 	msg.community = community
 }
 
+func (msg *communityMessage) getV3Params() *v3Params {
+	return msg.v3
+}
+
+func (msg *communityMessage) setV3Params(params *v3Params) {
+	msg.v3 = params
+}
+
 func decodeCommunityMessage(decoder *berDecoder, version SnmpVersion) (snmpCommunityMessage, error) {
 	communityBytes, err := decoder.decodeOctetStringWithHeader()
 	if err != nil {
 		return nil, err
 	}
-	community := string(communityBytes)
+	msg, err := decodePdu(decoder, version)
+	if err != nil {
+		return nil, err
+	}
+	msg.setCommunity(string(communityBytes))
+	return msg, nil
+}
+
+// decodePdu decodes the PDU that makes up the rest of the message, creating the message type that matches the PDU type.
+func decodePdu(decoder *berDecoder, version SnmpVersion) (snmpCommunityMessage, error) {
 	rawpduType, pduLength, err := decoder.decodeHeader()
 	if err != nil {
 		return nil, fmt.Errorf("Unabled to decode pdu header - err: %s", err)
@@ -200,13 +244,17 @@ func decodeCommunityMessage(decoder *berDecoder, version SnmpVersion) (snmpCommu
 		msg = new(communityResponse)
 	case pduType_GET_BULK_REQUEST, pduType_INFORM_REQUEST, pduType_V2_TRAP, pduType_REPORT:
 		if version == Version1 {
-			return nil, fmt.Errorf("Invalid PDU type for SNMP version 1 message: %s", pduType)
+			return nil, fmt.Errorf("Invalid PDU type for SNMP version 1 message: %s", pduType.String())
 		}
 		switch pduType {
 		case pduType_GET_BULK_REQUEST:
 			msg = new(communityRequest)
-		case pduType_INFORM_REQUEST, pduType_V2_TRAP, pduType_REPORT:
-			return nil, fmt.Errorf("PDU type %d not supported yet", pduType)
+		case pduType_INFORM_REQUEST:
+			msg = new(InformRequest)
+		case pduType_V2_TRAP:
+			msg = new(V2Trap)
+		case pduType_REPORT:
+			msg = new(Report)
 		}
 	case pduType_V1_TRAP:
 		if version != Version1 {
@@ -217,7 +265,6 @@ func decodeCommunityMessage(decoder *berDecoder, version SnmpVersion) (snmpCommu
 		return nil, fmt.Errorf("Unsupported PDU type: 0x%x", rawpduType)
 	}
 	msg.setVersion(version)
-	msg.setCommunity(community)
 	msg.setPduType(pduType)
 	if err := msg.decode(decoder); err != nil {
 		return nil, err
@@ -231,6 +278,9 @@ type communityRequestResponse struct {
 	requestId uint32
 	errorVal  SnmpRequestErrorType
 	errorIdx  int32
+	// GetBulk requests carry these in place of errorVal and errorIdx
+	nonRepeaters   int32
+	maxRepetitions int32
 }
 
 func (msg *communityRequestResponse) LoggingId() string {
@@ -246,28 +296,45 @@ func (msg *communityRequestResponse) setRequestId(requestId uint32) {
 }
 
 func (msg *communityRequestResponse) encode(encoderFactory *berEncoderFactory) ([]byte, error) {
+	if msg.version == Version3 {
+		return msg.encodeV3(encoderFactory)
+	}
 	encoder := encoderFactory.newberEncoder()
 	defer encoder.destroy()
 	msgHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
 	headerFieldsLen := encoder.encodeInteger(int64(msg.version))
 	headerFieldsLen += encoder.encodeOctetString([]byte(msg.community))
+	pduLen, err := msg.encodePdu(encoder)
+	if err != nil {
+		return nil, err
+	}
+	msgHeader.setContentLength(headerFieldsLen + pduLen)
+	return encoder.serialize(), nil
+}
+
+// encodePdu writes the PDU to the encoder. It returns the number of bytes written to the encoder
+func (msg *communityRequestResponse) encodePdu(encoder *berEncoder) (int, error) {
 	pduHeader := encoder.newHeader(snmpBlockType(msg.pduType))
 	pduControlFieldsLen := encoder.encodeInteger(int64(msg.requestId))
-	pduControlFieldsLen += encoder.encodeInteger(int64(msg.errorVal))
-	pduControlFieldsLen += encoder.encodeInteger(int64(msg.errorIdx))
+	if msg.pduType == pduType_GET_BULK_REQUEST {
+		pduControlFieldsLen += encoder.encodeInteger(int64(msg.nonRepeaters))
+		pduControlFieldsLen += encoder.encodeInteger(int64(msg.maxRepetitions))
+	} else {
+		pduControlFieldsLen += encoder.encodeInteger(int64(msg.errorVal))
+		pduControlFieldsLen += encoder.encodeInteger(int64(msg.errorIdx))
+	}
 	varbindsListHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
 	varbindsLen := 0
 	for _, varbind := range msg.varbinds {
 		encodedLen, err := encoder.encodeVarbind(varbind)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 		varbindsLen += encodedLen
 	}
 	_, varbindsListLen := varbindsListHeader.setContentLength(varbindsLen)
 	_, pduLen := pduHeader.setContentLength(pduControlFieldsLen + varbindsListLen)
-	msgHeader.setContentLength(headerFieldsLen + pduLen)
-	return encoder.serialize(), nil
+	return pduLen, nil
 }
 
 func (msg *communityRequestResponse) decode(decoder *berDecoder) error {
@@ -275,6 +342,15 @@ func (msg *communityRequestResponse) decode(decoder *berDecoder) error {
 	if msg.requestId, err = decoder.decodeUint32WithHeader(); err != nil {
 		return err
 	}
+	if msg.pduType == pduType_GET_BULK_REQUEST {
+		if msg.nonRepeaters, err = decoder.decodeInt32WithHeader(); err != nil {
+			return err
+		}
+		if msg.maxRepetitions, err = decoder.decodeInt32WithHeader(); err != nil {
+			return err
+		}
+		return msg.decodeVarbinds(decoder)
+	}
 	i32Val, err := decoder.decodeInt32WithHeader()
 	if err != nil {
 		return err
@@ -289,17 +365,30 @@ func (msg *communityRequestResponse) decode(decoder *berDecoder) error {
 	return msg.decodeVarbinds(decoder)
 }
 
+// requestOverrides records which of a client's settings a request has overridden.
+type requestOverrides int
+
+const (
+	requestOverride_TIMEOUT      requestOverrides = 1 << 0
+	requestOverride_RETRIES                       = 1 << 1
+	requestOverride_RETRY_POLICY                  = 1 << 2
+)
+
 type communityRequest struct {
 	communityRequestResponse
-	response         SnmpResponse
-	timeoutSeconds   int
-	retriesRemaining int
-	timer            *time.Timer
-	timeoutFunc      func(SnmpRequest)
-	requestDoneChan  chan bool
-	flightStartTime  time.Time
-	flightTime       time.Duration
-	transportError   error
+	response           SnmpResponse
+	timeout            time.Duration
+	retries            int
+	retriesRemaining   int
+	retryPolicy        RetryPolicy
+	overrides          requestOverrides
+	timer              *time.Timer
+	attempt            uint32
+	requestDoneChan    chan bool
+	attemptStartTime   time.Time
+	attemptFlightTimes []time.Duration
+	flightTime         time.Duration
+	transportError     error
 }
 
 func newCommunityRequest() *communityRequest {
@@ -308,28 +397,88 @@ func newCommunityRequest() *communityRequest {
 	return req
 }
 
-func (req *communityRequest) setTimeoutSeconds(timeoutSeconds int) {
-	req.timeoutSeconds = timeoutSeconds
+// SetTimeout overrides the timeout of the client the request is sent with.
+func (req *communityRequest) SetTimeout(timeout time.Duration) {
+	req.timeout = timeout
+	req.overrides |= requestOverride_TIMEOUT
+}
+
+// SetRetries overrides the retries of the client the request is sent with.
+func (req *communityRequest) SetRetries(retries int) {
+	req.retries = retries
+	req.overrides |= requestOverride_RETRIES
+}
+
+// SetRetryPolicy overrides the retry policy of the client the request is sent with.
+func (req *communityRequest) SetRetryPolicy(policy RetryPolicy) {
+	req.retryPolicy = policy
+	req.overrides |= requestOverride_RETRY_POLICY
+}
+
+func (req *communityRequest) setTimeout(timeout time.Duration) {
+	if req.overrides&requestOverride_TIMEOUT == 0 {
+		req.timeout = timeout
+	}
 }
 
 func (req *communityRequest) setRetriesRemaining(retriesRemaining int) {
+	if req.overrides&requestOverride_RETRIES != 0 {
+		retriesRemaining = req.retries
+	}
 	req.retriesRemaining = retriesRemaining
 }
 
-func (req *communityRequest) startTimer(timeoutFunc func(SnmpRequest)) {
-	req.timeoutFunc = timeoutFunc
-	req.flightStartTime = time.Now()
-	req.timer = time.AfterFunc(time.Duration(req.timeoutSeconds)*time.Second, req.handleTimeout)
+func (req *communityRequest) setRetryPolicy(policy RetryPolicy) {
+	if req.overrides&requestOverride_RETRY_POLICY == 0 {
+		req.retryPolicy = policy
+	}
 }
 
+// startTimer starts a new attempt at sending the request, stopping the timer of the one in flight, if any. The attempt's
+// timeout is chosen by the request's retry policy. timeoutFunc is called from the timer's goroutine with the attempt's
+// number, so that a timeout that fired just as its timer was stopped can be recognised as stale by the request tracker.
+func (req *communityRequest) startTimer(timeoutFunc func(SnmpRequest, uint32)) {
+	req.stopTimer()
+	policy := req.retryPolicy
+	if policy == nil {
+		policy = FixedRetryPolicy{}
+	}
+	req.attempt++
+	attempt := req.attempt
+	req.attemptStartTime = time.Now()
+	req.timer = time.AfterFunc(policy.AttemptTimeout(len(req.attemptFlightTimes), req.timeout), func() {
+		timeoutFunc(req, attempt)
+	})
+}
+
+// stopTimer ends the attempt in flight. It's also used once the final attempt has timed out, to record its flight time.
 func (req *communityRequest) stopTimer() {
-	req.timer.Stop()
-	req.flightTime = time.Since(req.flightStartTime)
+	if req.timer != nil {
+		req.timer.Stop()
+	}
+	req.endAttempt()
+}
+
+// isCurrentAttempt checks whether a timeout is for the attempt now in flight, rather than one that has been superseded.
+func (req *communityRequest) isCurrentAttempt(attempt uint32) bool {
+	return attempt == req.attempt
 }
 
-func (req *communityRequest) handleTimeout() {
-	req.flightTime = time.Since(req.flightStartTime)
-	req.timeoutFunc(req)
+func (req *communityRequest) endAttempt() {
+	if req.attemptStartTime.IsZero() {
+		return
+	}
+	attemptFlightTime := time.Since(req.attemptStartTime)
+	req.attemptFlightTimes = append(req.attemptFlightTimes, attemptFlightTime)
+	req.flightTime += attemptFlightTime
+	req.attemptStartTime = time.Time{}
+}
+
+// resetFlightTimes clears the flight times of any earlier send, before the request is sent again.
+func (req *communityRequest) resetFlightTimes() {
+	req.attemptStartTime = time.Time{}
+	req.attemptFlightTimes = nil
+	req.flightTime = 0
 }
 
 func (req *communityRequest) isRetryRequired() bool {
@@ -344,14 +493,26 @@ func (req *communityRequest) wait() {
 	<-req.requestDoneChan
 }
 
+// done returns the channel that wait reads, for waiting on the request alongside other events.
+func (req *communityRequest) done() <-chan bool {
+	return req.requestDoneChan
+}
+
 func (req *communityRequest) notify() {
 	req.requestDoneChan <- true
 }
 
+// FlightTime returns the total time the request spent in flight, over all of its attempts.
 func (req *communityRequest) FlightTime() time.Duration {
 	return req.flightTime
 }
 
+// AttemptFlightTimes returns the time each attempt at sending the request spent in flight, in the order they were sent.
+// An attempt that timed out was in flight for its whole timeout.
+func (req *communityRequest) AttemptFlightTimes() []time.Duration {
+	return req.attemptFlightTimes
+}
+
 func (req *communityRequest) TransportError() error {
 	return req.transportError
 }
@@ -382,10 +543,25 @@ func (req *communityRequest) AddOids(oids []ObjectIdentifier) {
 	}
 }
 
+// NonRepeaters returns the number of leading varbinds in a GetBulk request that are retrieved with a single GetNext.
+func (req *communityRequest) NonRepeaters() int32 {
+	return req.nonRepeaters
+}
+
+// MaxRepetitions returns the number of GetNext iterations a GetBulk request asks for on each of the varbinds that follow
+// the non-repeaters.
+func (req *communityRequest) MaxRepetitions() int32 {
+	return req.maxRepetitions
+}
+
 func (req *communityRequest) createResponse() *communityResponse {
 	resp := new(communityResponse)
 	resp.pduType = pduType_RESPONSE
 	resp.version = req.version
+	resp.community = req.community
+	if req.v3 != nil {
+		resp.v3 = req.v3.forResponse()
+	}
 	resp.address = req.address
 	resp.requestId = req.requestId
 	return resp
@@ -405,15 +581,41 @@ func (resp *communityResponse) ErrorVal() SnmpRequestErrorType {
 
 type V1Trap struct {
 	communityMessage
-	enterprise   []uint32
-	agentAddr    *net.IPAddr
-	genericTrap  uint32
-	specificTrap uint32
+	enterprise   ObjectIdentifier
+	agentAddr    net.IP
+	genericTrap  GenericTrapType
+	specificTrap int32
 	timeStamp    uint32
 }
 
 func (msg *V1Trap) LoggingId() string {
-	return fmt.Sprintf("%s:%d", msg.pduType, msg.timeStamp)
+	return fmt.Sprintf("%s:%s:%d", msg.pduType.String(), msg.genericTrap, msg.timeStamp)
+}
+
+// Enterprise returns the sysObjectID of the entity that generated the trap.
+func (msg *V1Trap) Enterprise() ObjectIdentifier {
+	return msg.enterprise
+}
+
+// AgentAddress returns the address of the agent that generated the trap, as reported in the trap itself. This may differ
+// from the source address of the message, which is available from Address().
+func (msg *V1Trap) AgentAddress() net.IP {
+	return msg.agentAddr
+}
+
+func (msg *V1Trap) GenericTrap() GenericTrapType {
+	return msg.genericTrap
+}
+
+// SpecificTrap returns the specific trap code. It is only meaningful when GenericTrap() is
+// GenericTrapType_ENTERPRISE_SPECIFIC.
+func (msg *V1Trap) SpecificTrap() int32 {
+	return msg.specificTrap
+}
+
+// TimeStamp returns the value of the generating agent's sysUpTime at the time the trap was generated.
+func (msg *V1Trap) TimeStamp() uint32 {
+	return msg.timeStamp
 }
 
 func (msg *V1Trap) encode(encoderFactory *berEncoderFactory) ([]byte, error) {
@@ -423,6 +625,18 @@ func (msg *V1Trap) encode(encoderFactory *berEncoderFactory) ([]byte, error) {
 	headerFieldsLen := encoder.encodeInteger(int64(msg.version))
 	headerFieldsLen += encoder.encodeOctetString([]byte(msg.community))
 	pduHeader := encoder.newHeader(snmpBlockType(msg.pduType))
+	pduControlFieldsLen, err := encoder.encodeObjectIdentifier(msg.enterprise)
+	if err != nil {
+		return nil, err
+	}
+	agentAddrLen, err := encoder.encodeIPv4Address(msg.agentAddr)
+	if err != nil {
+		return nil, err
+	}
+	pduControlFieldsLen += agentAddrLen
+	pduControlFieldsLen += encoder.encodeInteger(int64(msg.genericTrap))
+	pduControlFieldsLen += encoder.encodeInteger(int64(msg.specificTrap))
+	pduControlFieldsLen += encoder.encodeUnsignedInteger(snmpBlockType_TIME_TICKS, uint64(msg.timeStamp))
 	varbindsListHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
 	varbindsLen := 0
 	for _, varbind := range msg.varbinds {
@@ -432,18 +646,165 @@ func (msg *V1Trap) encode(encoderFactory *berEncoderFactory) ([]byte, error) {
 		}
 		varbindsLen += encodedLen
 	}
-	_, pduLen := varbindsListHeader.setContentLength(varbindsLen)
-	_, msgLen := pduHeader.setContentLength(pduLen)
-	msgLen += headerFieldsLen
-	msgHeader.setContentLength(msgLen)
+	_, varbindsListLen := varbindsListHeader.setContentLength(varbindsLen)
+	_, pduLen := pduHeader.setContentLength(pduControlFieldsLen + varbindsListLen)
+	msgHeader.setContentLength(headerFieldsLen + pduLen)
 	return encoder.serialize(), nil
 }
 
 func (msg *V1Trap) decode(decoder *berDecoder) (err error) {
-	return
+	if msg.enterprise, err = decoder.decodeObjectIdentifierWithHeader(); err != nil {
+		return err
+	}
+	if msg.agentAddr, err = decoder.decodeIPv4AddressWithHeader(); err != nil {
+		return err
+	}
+	i32Val, err := decoder.decodeInt32WithHeader()
+	if err != nil {
+		return err
+	}
+	if i32Val < 0 || i32Val > GenericTrapType_MAX {
+		return fmt.Errorf("Invalid generic trap value: %d", i32Val)
+	}
+	msg.genericTrap = GenericTrapType(i32Val)
+	if msg.specificTrap, err = decoder.decodeInt32WithHeader(); err != nil {
+		return err
+	}
+	if msg.timeStamp, err = decoder.decodeUnsigned32WithHeader(snmpBlockType_TIME_TICKS); err != nil {
+		return err
+	}
+	return msg.decodeVarbinds(decoder)
+}
+
+// notificationSysUpTime returns the value of the sysUpTime.0 varbind, which must be the first varbind in the list of an
+// SNMPv2-Trap or InformRequest.
+func (msg *baseMsg) notificationSysUpTime() (uint32, error) {
+	if len(msg.varbinds) < 1 {
+		return 0, fmt.Errorf("Notification has no sysUpTime.0 varbind")
+	}
+	vb, ok := msg.varbinds[0].(*TimeTicksVarbind)
+	if !ok || !vb.GetOid().Equal(SYS_UPTIME_OID) {
+		return 0, fmt.Errorf("First notification varbind isn't sysUpTime.0: %v (%T)", msg.varbinds[0].GetOid(), msg.varbinds[0])
+	}
+	return vb.Value, nil
+}
+
+// notificationTrapOid returns the value of the snmpTrapOID.0 varbind, which must be the second varbind in the list of an
+// SNMPv2-Trap or InformRequest.
+func (msg *baseMsg) notificationTrapOid() (ObjectIdentifier, error) {
+	if len(msg.varbinds) < 2 {
+		return nil, fmt.Errorf("Notification has no snmpTrapOID.0 varbind")
+	}
+	vb, ok := msg.varbinds[1].(*ObjectIdentifierVarbind)
+	if !ok || !vb.GetOid().Equal(SNMP_TRAP_OID_OID) {
+		return nil, fmt.Errorf("Second notification varbind isn't snmpTrapOID.0: %v (%T)", msg.varbinds[1].GetOid(), msg.varbinds[1])
+	}
+	return vb.Value, nil
+}
+
+// initNotificationVarbinds replaces any existing varbinds with the sysUpTime.0 and snmpTrapOID.0 varbinds that must lead
+// every v2 notification.
+func (msg *baseMsg) initNotificationVarbinds(sysUpTime uint32, trapOid ObjectIdentifier) {
+	msg.varbinds = []Varbind{NewTimeTicksVarbind(SYS_UPTIME_OID, sysUpTime), NewObjectIdentifierVarbind(SNMP_TRAP_OID_OID, trapOid)}
+}
+
+// V2Trap is an SNMPv2-Trap PDU. Its first two varbinds are always sysUpTime.0 and snmpTrapOID.0, followed by any varbinds
+// carried by the notification.
+type V2Trap struct {
+	communityRequestResponse
+}
+
+func newV2Trap(version SnmpVersion, sysUpTime uint32, trapOid ObjectIdentifier) *V2Trap {
+	trap := new(V2Trap)
+	trap.version = version
+	trap.pduType = pduType_V2_TRAP
+	trap.initNotificationVarbinds(sysUpTime, trapOid)
+	return trap
+}
+
+// SysUpTime returns the value of the trap's sysUpTime.0 varbind.
+func (msg *V2Trap) SysUpTime() (uint32, error) {
+	return msg.notificationSysUpTime()
+}
+
+// TrapOid returns the value of the trap's snmpTrapOID.0 varbind, which identifies the notification.
+func (msg *V2Trap) TrapOid() (ObjectIdentifier, error) {
+	return msg.notificationTrapOid()
+}
+
+// InformRequest is an acknowledged v2 notification. Its varbinds have the same layout as a V2Trap. Since the receiver
+// acknowledges it with a Response PDU, it can be sent using the same retry and timeout handling as any other request.
+type InformRequest struct {
+	communityRequest
+}
+
+func newInformRequest(version SnmpVersion, sysUpTime uint32, trapOid ObjectIdentifier) *InformRequest {
+	req := new(InformRequest)
+	req.requestDoneChan = make(chan bool)
+	req.version = version
+	req.pduType = pduType_INFORM_REQUEST
+	req.initNotificationVarbinds(sysUpTime, trapOid)
+	return req
+}
+
+// SysUpTime returns the value of the inform's sysUpTime.0 varbind.
+func (msg *InformRequest) SysUpTime() (uint32, error) {
+	return msg.notificationSysUpTime()
+}
+
+// TrapOid returns the value of the inform's snmpTrapOID.0 varbind, which identifies the notification.
+func (msg *InformRequest) TrapOid() (ObjectIdentifier, error) {
+	return msg.notificationTrapOid()
+}
+
+// Report is a Report PDU. Reports generated by SNMPv3 engines normally carry a usmStats counter rather than the
+// notification varbinds, in which case SysUpTime and TrapOid will return errors.
+type Report struct {
+	communityRequestResponse
+}
+
+func newReport(version SnmpVersion, requestId uint32) *Report {
+	report := new(Report)
+	report.version = version
+	report.pduType = pduType_REPORT
+	report.requestId = requestId
+	return report
+}
+
+// SysUpTime returns the value of the report's sysUpTime.0 varbind, if it has one.
+func (msg *Report) SysUpTime() (uint32, error) {
+	return msg.notificationSysUpTime()
+}
+
+// TrapOid returns the value of the report's snmpTrapOID.0 varbind, if it has one.
+func (msg *Report) TrapOid() (ObjectIdentifier, error) {
+	return msg.notificationTrapOid()
+}
+
+// UsmError returns the USM error the report was sent for, identified by the usmStats counter in its first varbind. It
+// returns false if the report wasn't sent by USM.
+func (msg *Report) UsmError() (UsmErrorType, bool) {
+	if len(msg.varbinds) < 1 {
+		return 0, false
+	}
+	oid := msg.varbinds[0].GetOid()
+	if len(oid) != len(USM_STATS_OID)+2 || USM_STATS_OID.MatchLength(oid) != len(USM_STATS_OID) || oid[len(oid)-1] != 0 {
+		return 0, false
+	}
+	errorType := UsmErrorType(oid[len(USM_STATS_OID)])
+	if errorType < UsmErrorType_UNSUPPORTED_SEC_LEVEL || errorType > UsmErrorType_DECRYPTION_ERROR {
+		return 0, false
+	}
+	return errorType, true
 }
 
 func decodeMsg(rawMsg []byte) (decodedMsg SnmpMessage, err error) {
+	return decodeMsgWithUsm(rawMsg, nil, nil)
+}
+
+// decodeMsgWithUsm decodes a message, authenticating SNMPv3 messages using the keys of the users in the given table. If an
+// engine is given, SNMPv3 messages are processed with that engine as their authoritative engine.
+func decodeMsgWithUsm(rawMsg []byte, users *usmUserTable, engine *localEngine) (decodedMsg SnmpMessage, err error) {
 	decoder := newberDecoder(rawMsg)
 	msgType, length, err := decoder.decodeHeader()
 	if err != nil {
@@ -463,6 +824,8 @@ func decodeMsg(rawMsg []byte) (decodedMsg SnmpMessage, err error) {
 	switch version {
 	case Version1, Version2c:
 		return decodeCommunityMessage(decoder, version)
+	case Version3:
+		return decodeV3Message(decoder, rawMsg, users, engine)
 	default:
 		return nil, fmt.Errorf("Unsupported snmp version code 0x%x", version)
 	}
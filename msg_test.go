@@ -0,0 +1,166 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+)
+
+func SetupMsgCodecTest(logger seelog.LoggerInterface) {
+	Describe("Message codec", func() {
+		var (
+			encoderFactory *berEncoderFactory
+		)
+		BeforeEach(func() {
+			encoderFactory = newberEncoderFactory(logger)
+		})
+
+		Describe("for a v1 trap", func() {
+			// snmptrap -v1 -c public <host> 1.3.6.1.4.1.8072 192.168.1.1 6 17 1234
+			encodedTrap := []byte{0x30, 0x28,
+				0x02, 0x01, 0x00,
+				0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+				0xa4, 0x1b,
+				0x06, 0x07, 0x2b, 0x06, 0x01, 0x04, 0x01, 0xbf, 0x08,
+				0x40, 0x04, 0xc0, 0xa8, 0x01, 0x01,
+				0x02, 0x01, 0x06,
+				0x02, 0x01, 0x11,
+				0x43, 0x02, 0x04, 0xd2,
+				0x30, 0x00}
+			It("should decode all of the Trap-PDU fields", func() {
+				msg, err := decodeMsg(encodedTrap)
+				Ω(err).Should(BeNil())
+				Ω(msg).Should(BeAssignableToTypeOf(new(V1Trap)))
+				trap := msg.(*V1Trap)
+				Ω(trap.getCommunity()).Should(Equal("public"))
+				Ω(trap.Enterprise()).Should(Equal(ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072}))
+				Ω(trap.AgentAddress().Equal(net.IPv4(192, 168, 1, 1))).Should(BeTrue())
+				Ω(trap.GenericTrap()).Should(Equal(GenericTrapType(GenericTrapType_ENTERPRISE_SPECIFIC)))
+				Ω(trap.SpecificTrap()).Should(Equal(int32(17)))
+				Ω(trap.TimeStamp()).Should(Equal(uint32(1234)))
+				Ω(trap.Varbinds()).Should(BeEmpty())
+			})
+			It("should re-encode to the original message", func() {
+				msg, err := decodeMsg(encodedTrap)
+				Ω(err).Should(BeNil())
+				encoded, err := msg.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				Ω(encoded).Should(Equal(encodedTrap))
+			})
+			It("should round trip varbinds", func() {
+				trap := new(V1Trap)
+				trap.version = Version1
+				trap.pduType = pduType_V1_TRAP
+				trap.community = "public"
+				trap.enterprise = ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072}
+				trap.agentAddr = net.IPv4(10, 0, 0, 1)
+				trap.genericTrap = GenericTrapType_LINK_DOWN
+				trap.timeStamp = 4294967295
+				trap.AddVarbind(NewIntegerVarbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 1, 3}, 3))
+				encoded, err := trap.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				msg, err := decodeMsg(encoded)
+				Ω(err).Should(BeNil())
+				decoded := msg.(*V1Trap)
+				Ω(decoded.GenericTrap()).Should(Equal(GenericTrapType(GenericTrapType_LINK_DOWN)))
+				Ω(decoded.TimeStamp()).Should(Equal(uint32(4294967295)))
+				Ω(decoded.Varbinds()).Should(HaveLen(1))
+				Ω(decoded.Varbinds()[0].(*IntegerVarbind).Value).Should(Equal(int32(3)))
+			})
+		})
+
+		Describe("for a GetBulk request", func() {
+			It("should carry non-repeaters and max-repetitions in place of the error fields", func() {
+				req := newCommunityRequest()
+				req.version = Version2c
+				req.pduType = pduType_GET_BULK_REQUEST
+				req.community = "public"
+				req.requestId = 1
+				req.nonRepeaters = 1
+				req.maxRepetitions = 50
+				req.AddOids([]ObjectIdentifier{SYS_UPTIME_OID, {1, 3, 6, 1, 2, 1, 2, 2, 1, 10}})
+				encoded, err := req.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				Ω(encoded[13:24]).Should(Equal([]byte{0xa5, 0x28, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01, 0x02, 0x01, 0x32}))
+				msg, err := decodeMsg(encoded)
+				Ω(err).Should(BeNil())
+				decoded := msg.(*communityRequest)
+				Ω(decoded.NonRepeaters()).Should(Equal(int32(1)))
+				Ω(decoded.MaxRepetitions()).Should(Equal(int32(50)))
+				Ω(decoded.Varbinds()).Should(HaveLen(2))
+			})
+		})
+
+		Describe("for a message longer than 127 bytes", func() {
+			It("should round trip using long form lengths", func() {
+				req := newCommunityRequest()
+				req.version = Version2c
+				req.pduType = pduType_GET_REQUEST
+				req.community = "public"
+				for i := 0; i < 10; i++ {
+					req.AddOid(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, uint32(i)})
+				}
+				encoded, err := req.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				Ω(encoded[1]).Should(Equal(byte(0x81)))
+				msg, err := decodeMsg(encoded)
+				Ω(err).Should(BeNil())
+				Ω(msg.(*communityRequest).Varbinds()).Should(HaveLen(10))
+			})
+		})
+
+		Describe("for v2 notifications", func() {
+			linkDownOid := ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 3}
+			ifIndexOid := ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 1, 3}
+			roundTrip := func(msg SnmpMessage) SnmpMessage {
+				encoded, err := msg.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				decoded, err := decodeMsg(encoded)
+				Ω(err).Should(BeNil())
+				return decoded
+			}
+			It("should round trip an SNMPv2-Trap", func() {
+				trap := newV2Trap(Version2c, 123456, linkDownOid)
+				trap.setCommunity("public")
+				trap.requestId = 42
+				trap.AddVarbind(NewIntegerVarbind(ifIndexOid, 3))
+				msg := roundTrip(trap)
+				Ω(msg).Should(BeAssignableToTypeOf(new(V2Trap)))
+				decoded := msg.(*V2Trap)
+				Ω(decoded.getRequestId()).Should(Equal(uint32(42)))
+				Ω(decoded.SysUpTime()).Should(Equal(uint32(123456)))
+				Ω(decoded.TrapOid()).Should(Equal(linkDownOid))
+				Ω(decoded.Varbinds()).Should(HaveLen(3))
+			})
+			It("should round trip an InformRequest", func() {
+				inform := newInformRequest(Version2c, 99, linkDownOid)
+				inform.setCommunity("public")
+				inform.requestId = 7
+				msg := roundTrip(inform)
+				Ω(msg).Should(BeAssignableToTypeOf(new(InformRequest)))
+				decoded := msg.(*InformRequest)
+				Ω(decoded.getRequestId()).Should(Equal(uint32(7)))
+				Ω(decoded.SysUpTime()).Should(Equal(uint32(99)))
+				Ω(decoded.TrapOid()).Should(Equal(linkDownOid))
+			})
+			It("should decode a Report", func() {
+				report := newReport(Version2c, 5)
+				report.setCommunity("public")
+				report.AddVarbind(NewCounter32Varbind(ObjectIdentifier{1, 3, 6, 1, 6, 3, 15, 1, 1, 4, 0}, 1))
+				msg := roundTrip(report)
+				Ω(msg).Should(BeAssignableToTypeOf(new(Report)))
+				_, err := msg.(*Report).TrapOid()
+				Ω(err).ShouldNot(BeNil())
+			})
+			It("should reject an SNMPv2-Trap in a v1 message", func() {
+				trap := newV2Trap(Version2c, 1, linkDownOid)
+				trap.setVersion(Version1)
+				encoded, err := trap.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				_, err = decodeMsg(encoded)
+				Ω(err).ShouldNot(BeNil())
+			})
+		})
+	})
+}
@@ -0,0 +1,373 @@
+package gosnmp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// NotificationTarget is a destination for the notifications sent by a NotificationOriginator. SNMPv1 targets are sent
+// v1 traps. SNMPv2c and SNMPv3 targets are sent SNMPv2-Traps, or InformRequests if Inform is set, in which case
+// TimeoutSeconds and Retries control how long the originator waits for the target to acknowledge each one. Timeout, if
+// set, is used instead of TimeoutSeconds, for timeouts that aren't a whole number of seconds.
+//
+// The version and security of the messages sent to a target come from the TargetParams named by Params, if it's set.
+// Otherwise they're given by Version, along with Community for SNMPv1 and SNMPv2c targets, or UserName and SecurityLevel
+// for SNMPv3 targets. SNMPv3 notifications are sent as a user of the originator's context, added with Agent.AddUsmUser.
+//
+// If Filter is set, only the notifications whose snmpTrapOID.0 is in the view are sent to the target.
+type NotificationTarget struct {
+	// Name identifies the target in snmpTargetAddrTable. It may be left empty for targets that are only managed through
+	// the originator.
+	Name           string
+	Address        *net.UDPAddr
+	Params         string
+	Version        SnmpVersion
+	Community      string
+	UserName       string
+	SecurityLevel  SecurityLevel
+	Inform         bool
+	TimeoutSeconds int
+	Timeout        time.Duration
+	Retries        int
+	Filter         *View
+
+	// status is the RowStatus of the target's row in snmpTargetAddrTable, or zero for a target added with AddTarget, which
+	// is always active.
+	status RowStatus
+}
+
+func (target *NotificationTarget) isActive() bool {
+	return target.status == 0 || target.status == RowStatus_ACTIVE
+}
+
+// Targets that are created in snmpTargetAddrTable, or added without a timeout, get the default timeout and retry count
+// from SNMP-TARGET-MIB.
+const (
+	defaultTargetTimeoutSeconds = 15
+	defaultTargetRetries        = 3
+)
+
+// TargetParams are the version and security used for the notifications sent to the targets that name them, like a row of
+// snmpTargetParamsTable. SecurityName is the community for SNMPv1 and SNMPv2c, or the user name for SNMPv3.
+type TargetParams struct {
+	Version       SnmpVersion
+	SecurityName  string
+	SecurityLevel SecurityLevel
+
+	// status is the RowStatus of the params' row in snmpTargetParamsTable, or zero for params set with SetTargetParams
+	status RowStatus
+}
+
+func (params *TargetParams) isActive() bool {
+	return params.status == 0 || params.status == RowStatus_ACTIVE
+}
+
+// NotificationResult reports the outcome of sending an inform to one of an originator's targets. Err is nil if the
+// target acknowledged the inform, a TimeoutError if it never did, or an error describing the error-status it responded
+// with or why the inform couldn't be sent.
+type NotificationResult struct {
+	Target NotificationTarget
+	Inform *InformRequest
+	Err    error
+}
+
+// NotificationOriginator sends notifications to a list of targets, filling in sysUpTime.0 and snmpTrapOID.0 for each one.
+// Originators are created with ClientContext.NewNotificationOriginator or Agent.NewNotificationOriginator, and report the
+// time since their context was created as sysUpTime. An originator can be used from any number of goroutines.
+type NotificationOriginator struct {
+	ctxt *snmpContext
+
+	lock      sync.Mutex
+	targets   []NotificationTarget
+	params    map[string]TargetParams
+	agentAddr net.IP
+}
+
+// NewNotificationOriginator creates a notification originator that sends from the context, with no targets.
+func (ctxt *ClientContext) NewNotificationOriginator() *NotificationOriginator {
+	return newNotificationOriginator(&ctxt.snmpContext)
+}
+
+// NewNotificationOriginator creates a notification originator that sends from the agent, with no targets.
+func (agent *Agent) NewNotificationOriginator() *NotificationOriginator {
+	return newNotificationOriginator(&agent.snmpContext)
+}
+
+func newNotificationOriginator(ctxt *snmpContext) *NotificationOriginator {
+	return &NotificationOriginator{ctxt: ctxt, params: make(map[string]TargetParams), agentAddr: net.IPv4zero}
+}
+
+// AddTarget adds a target that every subsequent notification will be sent to. A target with the same non-empty name as an
+// existing one replaces it. A target with no timeout is given SNMP-TARGET-MIB's default timeout of 15 seconds, and its
+// default of 3 retries if it has no retries either.
+func (originator *NotificationOriginator) AddTarget(target NotificationTarget) error {
+	if target.Address == nil {
+		return fmt.Errorf("Notification target has no address")
+	}
+	if target.Params == "" {
+		if err := checkTargetParams(TargetParams{target.Version, target.UserName, target.SecurityLevel, 0}, target.Inform); err != nil {
+			return err
+		}
+	}
+	if target.Timeout == 0 && target.TimeoutSeconds == 0 {
+		target.TimeoutSeconds = defaultTargetTimeoutSeconds
+		if target.Retries == 0 {
+			target.Retries = defaultTargetRetries
+		}
+	}
+	target.status = 0
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	if target.Name != "" {
+		for i := range originator.targets {
+			if originator.targets[i].Name == target.Name {
+				originator.targets[i] = target
+				return nil
+			}
+		}
+	}
+	originator.targets = append(originator.targets, target)
+	return nil
+}
+
+// checkTargetParams checks that notifications can be sent with the params. The security name is only checked for SNMPv3.
+func checkTargetParams(params TargetParams, inform bool) error {
+	switch {
+	case params.Version != Version1 && params.Version != Version2c && params.Version != Version3:
+		return fmt.Errorf("Notifications can't be sent to %s targets", params.Version)
+	case inform && params.Version == Version1:
+		return fmt.Errorf("Informs can't be sent to %s targets", params.Version)
+	case params.Version == Version3 && params.SecurityName == "":
+		return fmt.Errorf("SNMPv3 notification target has no user name")
+	}
+	return nil
+}
+
+// RemoveTarget removes every target with the given address.
+func (originator *NotificationOriginator) RemoveTarget(address *net.UDPAddr) {
+	originator.removeTargets(func(target *NotificationTarget) bool { return target.Address.String() == address.String() })
+}
+
+// RemoveNamedTarget removes the target with the given name.
+func (originator *NotificationOriginator) RemoveNamedTarget(name string) {
+	originator.removeTargets(func(target *NotificationTarget) bool { return target.Name == name })
+}
+
+func (originator *NotificationOriginator) removeTargets(matches func(target *NotificationTarget) bool) {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	targets := originator.targets[:0:0]
+	for _, target := range originator.targets {
+		if !matches(&target) {
+			targets = append(targets, target)
+		}
+	}
+	originator.targets = targets
+}
+
+// Targets returns the originator's targets, including any that have been created in snmpTargetAddrTable but aren't
+// active yet.
+func (originator *NotificationOriginator) Targets() []NotificationTarget {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	return append([]NotificationTarget{}, originator.targets...)
+}
+
+// SetTargetParams adds the params that targets can refer to by name, replacing any params already using the name.
+func (originator *NotificationOriginator) SetTargetParams(name string, params TargetParams) error {
+	if err := checkTargetParams(params, false); err != nil {
+		return err
+	}
+	params.status = 0
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	originator.params[name] = params
+	return nil
+}
+
+// RemoveTargetParams removes the params with the given name. Targets that refer to them aren't sent any more
+// notifications.
+func (originator *NotificationOriginator) RemoveTargetParams(name string) {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	delete(originator.params, name)
+}
+
+// SetAgentAddress sets the agent-addr sent in v1 traps. It defaults to 0.0.0.0.
+func (originator *NotificationOriginator) SetAgentAddress(agentAddr net.IP) {
+	originator.lock.Lock()
+	defer originator.lock.Unlock()
+	originator.agentAddr = agentAddr
+}
+
+// targetParams returns the version and security to use for a target.
+func (originator *NotificationOriginator) targetParams(target *NotificationTarget) (TargetParams, error) {
+	if target.Params == "" {
+		securityName := target.Community
+		if target.Version == Version3 {
+			securityName = target.UserName
+		}
+		return TargetParams{target.Version, securityName, target.SecurityLevel, 0}, nil
+	}
+	originator.lock.Lock()
+	params, ok := originator.params[target.Params]
+	originator.lock.Unlock()
+	if !ok || !params.isActive() {
+		return params, fmt.Errorf("Notification target params %q don't exist or aren't active", target.Params)
+	}
+	return params, checkTargetParams(params, target.Inform)
+}
+
+// Notify sends the notification identified by trapOid, carrying the given varbinds, to every active target whose filter
+// passes it. SNMPv1 targets are sent the equivalent v1 trap, as described in RFC 3584 section 3.2, without any Counter64
+// varbinds. Traps are queued for transmission before Notify returns. The result of each inform is delivered on the
+// returned channel once the target acknowledges it or its retries run out, and the channel is closed after the last one.
+// The channel is buffered, so it doesn't have to be read if the results aren't wanted. A notification with an empty
+// trapOid isn't sent to any target, and the channel carries a single result reporting the error.
+func (originator *NotificationOriginator) Notify(trapOid ObjectIdentifier, varbinds ...Varbind) <-chan NotificationResult {
+	if len(trapOid) == 0 {
+		results := make(chan NotificationResult, 1)
+		results <- NotificationResult{Err: fmt.Errorf("Notification has no snmpTrapOID.0 value")}
+		close(results)
+		return results
+	}
+	targets := originator.Targets()
+	originator.lock.Lock()
+	agentAddr := originator.agentAddr
+	originator.lock.Unlock()
+	sysUpTime := originator.ctxt.sysUpTime()
+
+	var informs sync.WaitGroup
+	results := make(chan NotificationResult, len(targets))
+	for _, target := range targets {
+		if !target.isActive() || (target.Filter != nil && !target.Filter.Contains(trapOid)) {
+			continue
+		}
+		params, err := originator.targetParams(&target)
+		var v3 *v3Params
+		if err == nil && params.Version == Version3 {
+			v3, err = originator.newV3Params(params, target.Inform)
+		}
+		switch {
+		case err != nil && target.Inform:
+			results <- NotificationResult{target, nil, err}
+		case err != nil:
+			originator.ctxt.Debugf("Ctxt %s: can't send trap to %s - err: %s", originator.ctxt.name, target.Address, err)
+		case params.Version == Version1:
+			trap := newV1TrapFromNotification(trapOid, agentAddr, sysUpTime, varbinds)
+			trap.setCommunity(params.SecurityName)
+			trap.setAddress(target.Address)
+			originator.ctxt.sendTrap(trap)
+		case target.Inform:
+			inform := newInformRequest(params.Version, sysUpTime, trapOid)
+			inform.varbinds = append(inform.varbinds, varbinds...)
+			inform.setCommunity(params.SecurityName)
+			inform.setV3Params(v3)
+			inform.setAddress(target.Address)
+			inform.setTimeout(configuredTimeout(target.Timeout, target.TimeoutSeconds))
+			inform.setRetriesRemaining(target.Retries)
+			informs.Add(1)
+			go func(target NotificationTarget) {
+				defer informs.Done()
+				originator.ctxt.sendRequest(inform)
+				inform.wait()
+				results <- NotificationResult{target, inform, informError(inform)}
+			}(target)
+		default:
+			trap := newV2Trap(params.Version, sysUpTime, trapOid)
+			trap.varbinds = append(trap.varbinds, varbinds...)
+			trap.setCommunity(params.SecurityName)
+			trap.setV3Params(v3)
+			trap.setAddress(target.Address)
+			originator.ctxt.sendTrap(trap)
+		}
+	}
+	go func() {
+		informs.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// newV3Params creates the security parameters for an SNMPv3 notification. Traps are sent with the originator's own engine
+// as the authoritative engine, so they can only be sent once SNMPv3 has been enabled. Informs are sent to the target's
+// engine, which the request tracker discovers, just as it does for a V3Client's requests.
+func (originator *NotificationOriginator) newV3Params(params TargetParams, inform bool) (*v3Params, error) {
+	user := originator.ctxt.usmUsers.lookupUser(params.SecurityName)
+	if user == nil {
+		return nil, fmt.Errorf("No USM user named %q", params.SecurityName)
+	}
+	v3 := &v3Params{msgMaxSize: v3MsgMaxSize, userName: user.Name, user: user}
+	switch params.SecurityLevel {
+	case SecurityLevel_AUTH_PRIV:
+		if user.PrivProtocol == PrivProtocol_NONE {
+			return nil, fmt.Errorf("User %s doesn't support privacy", user.Name)
+		}
+		v3.flags |= v3MsgFlags_PRIV
+		fallthrough
+	case SecurityLevel_AUTH_NO_PRIV:
+		if user.AuthProtocol == AuthProtocol_NONE {
+			return nil, fmt.Errorf("User %s doesn't support authentication", user.Name)
+		}
+		v3.flags |= v3MsgFlags_AUTH
+	}
+	if inform {
+		v3.flags |= v3MsgFlags_REPORTABLE
+		return v3, nil
+	}
+	engine := originator.ctxt.getLocalEngine()
+	if engine == nil {
+		return nil, fmt.Errorf("SNMPv3 traps can't be sent until SNMPv3 is enabled")
+	}
+	v3.engineId = engine.engineId
+	v3.engineBoots, v3.engineTime = engine.current()
+	return v3, nil
+}
+
+// informError returns the error to report for an inform once it has been acknowledged or timed out.
+func informError(inform *InformRequest) error {
+	if err := inform.TransportError(); err != nil {
+		return err
+	}
+	if errorVal := inform.Response().ErrorVal(); errorVal != SnmpRequestErrorType_NO_ERROR {
+		return fmt.Errorf("Inform refused with error-status %d", errorVal)
+	}
+	return nil
+}
+
+// newV1TrapFromNotification translates a notification into a v1 trap, following RFC 3584 section 3.2. The enterprise of a
+// standard trap comes from the snmpTrapEnterprise.0 varbind, if the notification has one. trapOid must not be empty.
+func newV1TrapFromNotification(trapOid ObjectIdentifier, agentAddr net.IP, sysUpTime uint32, varbinds []Varbind) *V1Trap {
+	trap := new(V1Trap)
+	trap.version = Version1
+	trap.pduType = pduType_V1_TRAP
+	trap.agentAddr = agentAddr
+	trap.timeStamp = sysUpTime
+	if len(trapOid) == len(SNMP_TRAPS_OID)+1 && trapOid.MatchLength(SNMP_TRAPS_OID) == len(SNMP_TRAPS_OID) &&
+		trapOid[len(trapOid)-1] >= 1 && trapOid[len(trapOid)-1] <= GenericTrapType_EGP_NEIGHBOR_LOSS+1 {
+		// one of the standard traps
+		trap.enterprise = SNMP_TRAPS_OID
+		for _, vb := range varbinds {
+			if oidVb, ok := vb.(*ObjectIdentifierVarbind); ok && vb.GetOid().Equal(SNMP_TRAP_ENTERPRISE_OID) {
+				trap.enterprise = oidVb.Value
+			}
+		}
+		trap.genericTrap = GenericTrapType(trapOid[len(trapOid)-1] - 1)
+	} else {
+		trap.genericTrap = GenericTrapType_ENTERPRISE_SPECIFIC
+		trap.specificTrap = int32(trapOid[len(trapOid)-1])
+		trap.enterprise = trapOid[:len(trapOid)-1]
+		if len(trap.enterprise) > 1 && trap.enterprise[len(trap.enterprise)-1] == 0 {
+			// the notification was itself translated from a v1 trap, as enterprise.0.specific-trap
+			trap.enterprise = trap.enterprise[:len(trap.enterprise)-1]
+		}
+	}
+	for _, vb := range varbinds {
+		if _, ok := vb.(*Counter64Varbind); !ok {
+			trap.varbinds = append(trap.varbinds, vb)
+		}
+	}
+	return trap
+}
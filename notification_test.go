@@ -0,0 +1,127 @@
+package gosnmp_test
+
+import (
+	"github.com/cihub/seelog"
+	snmp "github.com/idawes/gosnmp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+)
+
+func setupNotificationOriginatorTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("NotificationOriginator", func() {
+		var (
+			clientCtxt   *snmp.ClientContext
+			receiver     *snmp.TrapReceiver
+			receiverAddr *net.UDPAddr
+			originator   *snmp.NotificationOriginator
+			linkDownOid  = snmp.ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 3}
+			ifIndexOid   = snmp.ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 1, 7}
+		)
+		BeforeEach(func() {
+			testId := <-testIdGenerator
+			clientCtxt = snmp.NewClientContext(testId, 100, logger)
+			receiver = snmp.NewTrapReceiver(testId+" receiver", 10, 2173, logger)
+			receiverAddr, _ = net.ResolveUDPAddr("udp", "localhost:2173")
+			originator = clientCtxt.NewNotificationOriginator()
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			receiver.Shutdown()
+		})
+
+		It("should send v2c traps with sysUpTime.0 and snmpTrapOID.0 filled in", func(done Done) {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version2c})).Should(BeNil())
+			results := originator.Notify(linkDownOid, snmp.NewIntegerVarbind(ifIndexOid, 7))
+			Eventually(results).Should(BeClosed())
+			trap := (<-receiver.Traps()).(*snmp.V2Trap)
+			Ω(trap.TrapOid()).Should(Equal(linkDownOid))
+			Ω(trap.Varbinds()).Should(HaveLen(3))
+			Ω(trap.Varbinds()[2].GetOid()).Should(Equal(ifIndexOid))
+			close(done)
+		}, 2)
+
+		It("should translate notifications into v1 traps for v1 targets", func(done Done) {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version1})).Should(BeNil())
+			originator.SetAgentAddress(net.IP{10, 0, 0, 1})
+			originator.Notify(linkDownOid, snmp.NewIntegerVarbind(ifIndexOid, 7), snmp.NewCounter64Varbind(ifIndexOid, 1))
+			trap := (<-receiver.Traps()).(*snmp.V1Trap)
+			Ω(trap.GenericTrap()).Should(Equal(snmp.GenericTrapType(snmp.GenericTrapType_LINK_DOWN)))
+			Ω(trap.Enterprise()).Should(Equal(snmp.SNMP_TRAPS_OID))
+			Ω(trap.AgentAddress().Equal(net.IP{10, 0, 0, 1})).Should(BeTrue())
+			Ω(trap.Varbinds()).Should(HaveLen(1))
+
+			originator.Notify(snmp.ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072, 0, 17})
+			trap = (<-receiver.Traps()).(*snmp.V1Trap)
+			Ω(trap.GenericTrap()).Should(Equal(snmp.GenericTrapType(snmp.GenericTrapType_ENTERPRISE_SPECIFIC)))
+			Ω(trap.Enterprise()).Should(Equal(snmp.ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072}))
+			Ω(trap.SpecificTrap()).Should(Equal(int32(17)))
+			close(done)
+		}, 2)
+
+		It("should take the enterprise of a translated standard trap from snmpTrapEnterprise.0", func(done Done) {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version1})).Should(BeNil())
+			enterprise := snmp.ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072, 3, 2, 10}
+			originator.Notify(linkDownOid, snmp.NewObjectIdentifierVarbind(snmp.SNMP_TRAP_ENTERPRISE_OID, enterprise))
+			trap := (<-receiver.Traps()).(*snmp.V1Trap)
+			Ω(trap.GenericTrap()).Should(Equal(snmp.GenericTrapType(snmp.GenericTrapType_LINK_DOWN)))
+			Ω(trap.Enterprise()).Should(Equal(enterprise))
+			close(done)
+		}, 2)
+
+		It("should refuse to send a notification without a trap oid", func() {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version1})).Should(BeNil())
+			result, ok := <-originator.Notify(nil)
+			Ω(ok).Should(BeTrue())
+			Ω(result.Err).ShouldNot(BeNil())
+			Consistently(receiver.Traps(), 0.2).ShouldNot(Receive())
+		})
+
+		It("should report which informs were acknowledged", func(done Done) {
+			deadAddr, _ := net.ResolveUDPAddr("udp", "localhost:2174")
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version2c,
+				Inform: true, TimeoutSeconds: 1})).Should(BeNil())
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: deadAddr, Community: "public", Version: snmp.Version2c,
+				Inform: true, TimeoutSeconds: 1})).Should(BeNil())
+			results := make(map[int]error)
+			for result := range originator.Notify(linkDownOid) {
+				results[result.Target.Address.Port] = result.Err
+			}
+			Ω(results).Should(HaveLen(2))
+			Ω(results[2173]).Should(BeNil())
+			Ω(results[2174]).Should(BeAssignableToTypeOf(snmp.TimeoutError{}))
+			Ω((<-receiver.Traps()).(*snmp.InformRequest).TrapOid()).Should(Equal(linkDownOid))
+			close(done)
+		}, 3)
+
+		It("should give targets without a timeout the SNMP-TARGET-MIB defaults", func(done Done) {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version2c,
+				Inform: true})).Should(BeNil())
+			Ω(originator.Targets()[0].TimeoutSeconds).Should(Equal(15))
+			Ω(originator.Targets()[0].Retries).Should(Equal(3))
+			result := <-originator.Notify(linkDownOid)
+			Ω(result.Err).Should(BeNil())
+			Ω((<-receiver.Traps()).(*snmp.InformRequest).TrapOid()).Should(Equal(linkDownOid))
+			close(done)
+		}, 3)
+
+		It("should refuse targets it can't send to", func() {
+			Ω(originator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Version: snmp.Version1, Inform: true})).ShouldNot(BeNil())
+			Ω(originator.AddTarget(snmp.NotificationTarget{Version: snmp.Version2c})).ShouldNot(BeNil())
+			Ω(originator.Targets()).Should(BeEmpty())
+		})
+
+		It("should send from an agent, using the agent's sysUpTime", func(done Done) {
+			agent := snmp.NewAgentWithPort(<-testIdGenerator, 10, 2175, logger, nil)
+			defer agent.Shutdown()
+			agentOriginator := agent.NewNotificationOriginator()
+			Ω(agentOriginator.AddTarget(snmp.NotificationTarget{Address: receiverAddr, Community: "public", Version: snmp.Version2c,
+				Inform: true, TimeoutSeconds: 1})).Should(BeNil())
+			result := <-agentOriginator.Notify(linkDownOid)
+			Ω(result.Err).Should(BeNil())
+			inform := (<-receiver.Traps()).(*snmp.InformRequest)
+			Ω(inform.SysUpTime()).Should(BeNumerically("<", 100))
+			close(done)
+		}, 3)
+	})
+}
@@ -100,6 +100,9 @@ func (decoder *berDecoder) decodeObjectIdentifier(numBytes int) (ObjectIdentifie
 	decoder.pos++
 	oid[0] = uint32(firstByte) / 40
 	oid[1] = uint32(firstByte) % 40
+	if numBytes == 1 {
+		return oid[0:2], nil
+	}
 	numVals := 2
 	for ; ; numVals++ {
 		identifierPos := decoder.pos
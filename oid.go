@@ -17,6 +17,63 @@ var (
 	SYS_LOCATION_OID  = ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 6, 0}
 )
 
+// The oids of the varbinds that lead the varbind list of every SNMPv2-Trap and InformRequest.
+var (
+	SNMP_TRAP_OID_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+)
+
+// snmpTrapEnterprise.0 from SNMPv2-MIB, which carries the enterprise of a notification translated from a v1 trap.
+var (
+	SNMP_TRAP_ENTERPRISE_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 4, 3, 0}
+)
+
+// The snmp group counters from SNMPv2-MIB that count requests refused because of their community.
+var (
+	SNMP_IN_BAD_COMMUNITY_NAMES_OID = ObjectIdentifier{1, 3, 6, 1, 2, 1, 11, 4, 0}
+	SNMP_IN_BAD_COMMUNITY_USES_OID  = ObjectIdentifier{1, 3, 6, 1, 2, 1, 11, 5, 0}
+)
+
+// The snmpTrapOID.0 value of the standard authenticationFailure notification from SNMPv2-MIB.
+var (
+	AUTHENTICATION_FAILURE_TRAP_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 5}
+)
+
+// snmpTraps from SNMPv2-MIB, under which the standard notifications are defined. The notification for a v1 generic trap
+// is SNMP_TRAPS_OID followed by the generic trap number plus one.
+var (
+	SNMP_TRAPS_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5}
+)
+
+// The usmStats counters from SNMP-USER-BASED-SM-MIB. The oid of the counter for a UsmErrorType is USM_STATS_OID, followed
+// by the error type and 0.
+var (
+	USM_STATS_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 15, 1, 1}
+)
+
+// The snmpUnknownContexts counter from SNMP-TARGET-MIB, reported when an SNMPv3 request names a context the agent doesn't
+// have.
+var (
+	SNMP_UNKNOWN_CONTEXTS_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 12, 1, 5, 0}
+)
+
+// The entries of the SNMP-TARGET-MIB and SNMP-NOTIFICATION-MIB tables served by Agent.ServeNotificationTargets, and
+// snmpUDPDomain from SNMPv2-TM, the transport domain of every target in snmpTargetAddrTable.
+var (
+	SNMP_TARGET_ADDR_ENTRY_OID   = ObjectIdentifier{1, 3, 6, 1, 6, 3, 12, 1, 2, 1}
+	SNMP_TARGET_PARAMS_ENTRY_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 12, 1, 3, 1}
+	SNMP_NOTIFY_ENTRY_OID        = ObjectIdentifier{1, 3, 6, 1, 6, 3, 13, 1, 1, 1}
+	SNMP_NOTIFY_FILTER_ENTRY_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 13, 1, 3, 1}
+	SNMP_UDP_DOMAIN_OID          = ObjectIdentifier{1, 3, 6, 1, 6, 1, 1}
+)
+
+// The snmpEngine group from SNMP-FRAMEWORK-MIB, which describes an agent's SNMPv3 engine.
+var (
+	SNMP_ENGINE_ID_OID               = ObjectIdentifier{1, 3, 6, 1, 6, 3, 10, 2, 1, 1, 0}
+	SNMP_ENGINE_BOOTS_OID            = ObjectIdentifier{1, 3, 6, 1, 6, 3, 10, 2, 1, 2, 0}
+	SNMP_ENGINE_TIME_OID             = ObjectIdentifier{1, 3, 6, 1, 6, 3, 10, 2, 1, 3, 0}
+	SNMP_ENGINE_MAX_MESSAGE_SIZE_OID = ObjectIdentifier{1, 3, 6, 1, 6, 3, 10, 2, 1, 4, 0}
+)
+
 func parseOid(oidString string) (oid []int, err error) {
 	ids := strings.Split(oidString, ".")
 	if len(ids) < 2 {
@@ -0,0 +1,136 @@
+package gosnmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// net-snmp sends float, double and 64 bit integer values to v1/v2c managers by BER encoding them with an extended tag
+// (0x9f followed by one of the values below) and wrapping the result in an Opaque.
+const (
+	opaqueTag_EXTENSION  byte = 0x9f
+	opaqueTag_COUNTER_64      = 0x76
+	opaqueTag_FLOAT           = 0x78
+	opaqueTag_DOUBLE          = 0x79
+	opaqueTag_INT_64          = 0x7a
+	opaqueTag_UINT_64         = 0x7b
+)
+
+// OpaqueVarbind holds the raw contents of an Opaque value in Value. If those contents are one of the net-snmp
+// wrapped types, WrappedValue will also hold the decoded value as a float32, float64, int64 or uint64. Otherwise
+// WrappedValue is nil.
+type OpaqueVarbind struct { // type 0x44
+	baseVarbind
+	Value        []byte
+	WrappedValue interface{}
+}
+
+func NewOpaqueVarbind(oid ObjectIdentifier, val []byte) *OpaqueVarbind {
+	vb := new(OpaqueVarbind)
+	vb.oid = oid
+	vb.Value = val
+	vb.WrappedValue, _ = decodeOpaqueWrappedValue(val)
+	return vb
+}
+
+// NewOpaqueFloatVarbind creates an Opaque varbind wrapping a float, using the net-snmp encoding.
+func NewOpaqueFloatVarbind(oid ObjectIdentifier, val float32) *OpaqueVarbind {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, math.Float32bits(val))
+	return newOpaqueWrappedVarbind(oid, opaqueTag_FLOAT, buf.Bytes(), val)
+}
+
+// NewOpaqueDoubleVarbind creates an Opaque varbind wrapping a double, using the net-snmp encoding.
+func NewOpaqueDoubleVarbind(oid ObjectIdentifier, val float64) *OpaqueVarbind {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(val))
+	return newOpaqueWrappedVarbind(oid, opaqueTag_DOUBLE, buf.Bytes(), val)
+}
+
+// NewOpaqueInt64Varbind creates an Opaque varbind wrapping a signed 64 bit integer, using the net-snmp encoding.
+func NewOpaqueInt64Varbind(oid ObjectIdentifier, val int64) *OpaqueVarbind {
+	buf := new(bytes.Buffer)
+	encode2sComplementInt(buf, val)
+	return newOpaqueWrappedVarbind(oid, opaqueTag_INT_64, buf.Bytes(), val)
+}
+
+// NewOpaqueUint64Varbind creates an Opaque varbind wrapping an unsigned 64 bit integer, using the net-snmp encoding.
+func NewOpaqueUint64Varbind(oid ObjectIdentifier, val uint64) *OpaqueVarbind {
+	buf := new(bytes.Buffer)
+	encodeUnsignedInt(buf, val)
+	return newOpaqueWrappedVarbind(oid, opaqueTag_UINT_64, buf.Bytes(), val)
+}
+
+func newOpaqueWrappedVarbind(oid ObjectIdentifier, tag byte, content []byte, val interface{}) *OpaqueVarbind {
+	vb := new(OpaqueVarbind)
+	vb.oid = oid
+	vb.Value = append([]byte{opaqueTag_EXTENSION, tag, byte(len(content))}, content...)
+	vb.WrappedValue = val
+	return vb
+}
+
+func (vb *OpaqueVarbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeOpaque(vb.Value), nil
+}
+
+////////////////////////////////////////////////////////////////////////////
+// Opaque BER encode
+
+// encodeOpaque writes an opaque value to the encoder. It returns the number of bytes written to the encoder
+func (encoder *berEncoder) encodeOpaque(val []byte) int {
+	h := encoder.newHeader(snmpBlockType_OPAQUE)
+	buf := encoder.append()
+	buf.Write(val)
+	_, encodedLength := h.setContentLength(buf.Len())
+	return encodedLength
+}
+
+////////////////////////////////////////////////////////////////////////////
+// Opaque BER decode
+
+func (decoder *berDecoder) decodeOpaque(numBytes int) ([]byte, error) {
+	val, err := decoder.decodeOctetString(numBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode opaque value - err: %s", err)
+	}
+	return val, nil
+}
+
+// decodeOpaqueWrappedValue decodes the contents of an opaque value that uses one of the net-snmp extended types.
+func decodeOpaqueWrappedValue(val []byte) (interface{}, error) {
+	if len(val) < 3 || val[0] != opaqueTag_EXTENSION {
+		return nil, fmt.Errorf("Opaque value doesn't hold a wrapped type")
+	}
+	tag := val[1]
+	decoder := newberDecoder(val[2:])
+	contentLength, err := decoder.decodeLength()
+	if err != nil {
+		return nil, err
+	}
+	if contentLength != decoder.Len() {
+		return nil, fmt.Errorf("Wrapped value length %d doesn't match remaining opaque length %d", contentLength, decoder.Len())
+	}
+	switch tag {
+	case opaqueTag_FLOAT:
+		if contentLength != 4 {
+			return nil, fmt.Errorf("Invalid length %d for wrapped float", contentLength)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(decoder.Bytes())), nil
+	case opaqueTag_DOUBLE:
+		if contentLength != 8 {
+			return nil, fmt.Errorf("Invalid length %d for wrapped double", contentLength)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(decoder.Bytes())), nil
+	case opaqueTag_INT_64:
+		if contentLength < 1 || contentLength > 8 {
+			return nil, fmt.Errorf("Invalid length %d for wrapped int64", contentLength)
+		}
+		return decoder.decode2sComplementInt(contentLength)
+	case opaqueTag_UINT_64, opaqueTag_COUNTER_64:
+		return decoder.decodeUnsigned64(contentLength)
+	default:
+		return nil, fmt.Errorf("Unknown wrapped opaque type 0x%x", tag)
+	}
+}
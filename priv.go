@@ -0,0 +1,153 @@
+package gosnmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/binary"
+	"fmt"
+)
+
+// PrivProtocol identifies the cipher used to encrypt the scopedPDU of SNMPv3 messages. DES is defined in RFC 3414, and AES
+// in RFC 3826. The AES-192 and AES-256 variants aren't standardized. Their keys are longer than the localized keys produced
+// by some auth protocols, so they need one of two different key extension algorithms. Most agents use the one from the
+// Blumenthal draft, but Cisco devices use the one from the Reeder draft.
+type PrivProtocol int
+
+const (
+	PrivProtocol_NONE    PrivProtocol = 0
+	PrivProtocol_DES                  = 1 // usmDESPrivProtocol
+	PrivProtocol_AES                  = 2 // usmAesCfb128Protocol
+	PrivProtocol_AES192               = 3 // AES-192 with Blumenthal key extension
+	PrivProtocol_AES256               = 4 // AES-256 with Blumenthal key extension
+	PrivProtocol_AES192C              = 5 // AES-192 with Reeder key extension
+	PrivProtocol_AES256C              = 6 // AES-256 with Reeder key extension
+)
+
+func (protocol PrivProtocol) String() string {
+	switch protocol {
+	case PrivProtocol_NONE:
+		return "none"
+	case PrivProtocol_DES:
+		return "CBC-DES"
+	case PrivProtocol_AES:
+		return "CFB128-AES-128"
+	case PrivProtocol_AES192:
+		return "CFB128-AES-192"
+	case PrivProtocol_AES256:
+		return "CFB128-AES-256"
+	case PrivProtocol_AES192C:
+		return "CFB128-AES-192-C"
+	case PrivProtocol_AES256C:
+		return "CFB128-AES-256-C"
+	default:
+		return "Unknown"
+	}
+}
+
+// keyLength returns the length of the localized key the protocol needs. For DES, the second half of the key is the pre-IV.
+func (protocol PrivProtocol) keyLength() int {
+	switch protocol {
+	case PrivProtocol_DES, PrivProtocol_AES:
+		return 16
+	case PrivProtocol_AES192, PrivProtocol_AES192C:
+		return 24
+	case PrivProtocol_AES256, PrivProtocol_AES256C:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// localizePrivKey localizes a privacy key, extending it if it's shorter than the protocol needs
+func (protocol PrivProtocol) localizePrivKey(authProtocol AuthProtocol, key []byte, engineId []byte) []byte {
+	localizedKey := authProtocol.localizeKey(key, engineId)
+	switch protocol {
+	case PrivProtocol_AES192, PrivProtocol_AES256:
+		// Blumenthal: each extension is the hash of the whole key so far
+		for len(localizedKey) < protocol.keyLength() {
+			h := authProtocol.newHash()
+			h.Write(localizedKey)
+			localizedKey = h.Sum(localizedKey)
+		}
+	case PrivProtocol_AES192C, PrivProtocol_AES256C:
+		// Reeder: each extension is the previous extension, treated as a password and localized
+		extension := localizedKey
+		for len(localizedKey) < protocol.keyLength() {
+			extension = authProtocol.localizeKey(authProtocol.passwordToKey(string(extension)), engineId)
+			localizedKey = append(localizedKey, extension...)
+		}
+	}
+	return localizedKey[:protocol.keyLength()]
+}
+
+// encrypt encrypts a scopedPDU, returning the ciphertext and the msgPrivacyParameters the receiver will need to decrypt it.
+// salt must be different for every message sent with the same key.
+func (protocol PrivProtocol) encrypt(localizedKey []byte, engineBoots int32, engineTime int32, salt uint64, plaintext []byte) (ciphertext []byte, privParams []byte, err error) {
+	privParams = make([]byte, 8)
+	if protocol == PrivProtocol_DES {
+		// RFC 3414 section 8.1.1.1: the salt is engineBoots followed by a local counter
+		binary.BigEndian.PutUint32(privParams, uint32(engineBoots))
+		binary.BigEndian.PutUint32(privParams[4:], uint32(salt))
+		block, err := des.NewCipher(localizedKey[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		// the padding is ignored by the receiver, since the scopedPDU has its own length
+		ciphertext = make([]byte, (len(plaintext)+des.BlockSize-1)/des.BlockSize*des.BlockSize)
+		copy(ciphertext, plaintext)
+		cipher.NewCBCEncrypter(block, desIv(localizedKey, privParams)).CryptBlocks(ciphertext, ciphertext)
+		return ciphertext, privParams, nil
+	}
+	binary.BigEndian.PutUint64(privParams, salt)
+	block, err := aes.NewCipher(localizedKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext = make([]byte, len(plaintext))
+	cipher.NewCFBEncrypter(block, aesIv(engineBoots, engineTime, privParams)).XORKeyStream(ciphertext, plaintext)
+	return ciphertext, privParams, nil
+}
+
+// decrypt decrypts a scopedPDU. Any padding added by the sender is left in place.
+func (protocol PrivProtocol) decrypt(localizedKey []byte, engineBoots int32, engineTime int32, privParams []byte, ciphertext []byte) ([]byte, error) {
+	if len(privParams) != 8 {
+		return nil, fmt.Errorf("Invalid msgPrivacyParameters length: %d", len(privParams))
+	}
+	plaintext := make([]byte, len(ciphertext))
+	if protocol == PrivProtocol_DES {
+		if len(ciphertext)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("Encrypted scopedPDU length %d isn't a multiple of the DES block size", len(ciphertext))
+		}
+		block, err := des.NewCipher(localizedKey[:8])
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCBCDecrypter(block, desIv(localizedKey, privParams)).CryptBlocks(plaintext, ciphertext)
+		return plaintext, nil
+	}
+	block, err := aes.NewCipher(localizedKey)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCFBDecrypter(block, aesIv(engineBoots, engineTime, privParams)).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// desIv xors the pre-IV (the second half of the localized key) with the salt
+func desIv(localizedKey []byte, salt []byte) []byte {
+	iv := make([]byte, des.BlockSize)
+	for i := range iv {
+		iv[i] = localizedKey[8+i] ^ salt[i]
+	}
+	return iv
+}
+
+// aesIv concatenates engineBoots, engineTime and the salt, as described in RFC 3826 section 3.1.2.1
+func aesIv(engineBoots int32, engineTime int32, salt []byte) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv, uint32(engineBoots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
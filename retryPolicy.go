@@ -0,0 +1,78 @@
+package gosnmp
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides how long a request waits for a response to each of its attempts before it's retried, or times out
+// once its retries are used up. Attempts are numbered from 0, for the first transmission of the request.
+type RetryPolicy interface {
+	// AttemptTimeout returns the timeout for an attempt, given the timeout set on the client or request.
+	AttemptTimeout(attempt int, timeout time.Duration) time.Duration
+}
+
+// FixedRetryPolicy waits for the same timeout on every attempt. It's the policy used when none has been set.
+type FixedRetryPolicy struct{}
+
+func (policy FixedRetryPolicy) AttemptTimeout(attempt int, timeout time.Duration) time.Duration {
+	return timeout
+}
+
+// ExponentialRetryPolicy multiplies the timeout by Multiplier for each attempt after the first, so that a busy agent is
+// given longer to answer each retry. A Multiplier of 0 or less means 2. No attempt waits for less than the timeout, so
+// Multipliers below 1 act as 1. If MaxTimeout is set, no attempt waits longer than it.
+type ExponentialRetryPolicy struct {
+	Multiplier float64
+	MaxTimeout time.Duration
+}
+
+func (policy ExponentialRetryPolicy) AttemptTimeout(attempt int, timeout time.Duration) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	attemptTimeout := math.Max(float64(timeout)*math.Pow(multiplier, float64(attempt)), float64(timeout))
+	if policy.MaxTimeout > 0 && attemptTimeout > float64(policy.MaxTimeout) {
+		return policy.MaxTimeout
+	}
+	if attemptTimeout >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(attemptTimeout)
+}
+
+// JitteredRetryPolicy varies the timeouts chosen by Policy at random, by up to Fraction of each timeout in either
+// direction, so that requests sent together don't all retry together. A nil Policy means FixedRetryPolicy. Fraction is
+// limited to the range 0 to 1.
+type JitteredRetryPolicy struct {
+	Policy   RetryPolicy
+	Fraction float64
+}
+
+func (policy JitteredRetryPolicy) AttemptTimeout(attempt int, timeout time.Duration) time.Duration {
+	basePolicy := policy.Policy
+	if basePolicy == nil {
+		basePolicy = FixedRetryPolicy{}
+	}
+	fraction := math.Max(0, math.Min(policy.Fraction, 1))
+	attemptTimeout := float64(basePolicy.AttemptTimeout(attempt, timeout))
+	attemptTimeout += attemptTimeout * fraction * (2*rand.Float64() - 1)
+	switch {
+	case attemptTimeout < 0:
+		return 0
+	case attemptTimeout >= math.MaxInt64:
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(attemptTimeout)
+}
+
+// configuredTimeout returns the timeout configured on a client or notification target: its Timeout, or its TimeoutSeconds
+// if Timeout isn't set.
+func configuredTimeout(timeout time.Duration, timeoutSeconds int) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	return time.Duration(timeoutSeconds) * time.Second
+}
@@ -0,0 +1,58 @@
+package gosnmp_test
+
+import (
+	snmp "github.com/idawes/gosnmp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"math"
+	"time"
+)
+
+func setupRetryPolicyTest() {
+	Describe("RetryPolicy", func() {
+		It("should use the same timeout for every attempt with a fixed policy", func() {
+			policy := snmp.FixedRetryPolicy{}
+			for attempt := 0; attempt < 3; attempt++ {
+				Ω(policy.AttemptTimeout(attempt, 500*time.Millisecond)).Should(Equal(500 * time.Millisecond))
+			}
+		})
+		It("should multiply the timeout for each retry with an exponential policy", func() {
+			policy := snmp.ExponentialRetryPolicy{}
+			Ω(policy.AttemptTimeout(0, time.Second)).Should(Equal(time.Second))
+			Ω(policy.AttemptTimeout(1, time.Second)).Should(Equal(2 * time.Second))
+			Ω(policy.AttemptTimeout(3, time.Second)).Should(Equal(8 * time.Second))
+			policy = snmp.ExponentialRetryPolicy{Multiplier: 1.5, MaxTimeout: 2 * time.Second}
+			Ω(policy.AttemptTimeout(1, time.Second)).Should(Equal(1500 * time.Millisecond))
+			Ω(policy.AttemptTimeout(2, time.Second)).Should(Equal(2 * time.Second))
+			Ω(policy.AttemptTimeout(1000, time.Second)).Should(Equal(2 * time.Second))
+		})
+		It("should treat negative multipliers as 2, and never shrink the timeout", func() {
+			Ω(snmp.ExponentialRetryPolicy{Multiplier: -1}.AttemptTimeout(3, time.Second)).Should(Equal(8 * time.Second))
+			Ω(snmp.ExponentialRetryPolicy{Multiplier: 0.5}.AttemptTimeout(1, time.Second)).Should(Equal(time.Second))
+			Ω(snmp.ExponentialRetryPolicy{Multiplier: 0.5}.AttemptTimeout(1000, time.Second)).Should(Equal(time.Second))
+		})
+		It("should keep jittered timeouts within the given fraction of the underlying policy's", func() {
+			policy := snmp.JitteredRetryPolicy{Policy: snmp.ExponentialRetryPolicy{}, Fraction: 0.25}
+			varied := false
+			for i := 0; i < 100; i++ {
+				timeout := policy.AttemptTimeout(1, time.Second)
+				Ω(timeout).Should(BeNumerically(">=", 1500*time.Millisecond))
+				Ω(timeout).Should(BeNumerically("<=", 2500*time.Millisecond))
+				varied = varied || timeout != 2*time.Second
+			}
+			Ω(varied).Should(BeTrue())
+			Ω(snmp.JitteredRetryPolicy{}.AttemptTimeout(2, time.Second)).Should(Equal(time.Second))
+		})
+		It("should keep timeouts from overflowing or going negative", func() {
+			Ω(snmp.ExponentialRetryPolicy{}.AttemptTimeout(63, time.Nanosecond)).Should(Equal(time.Duration(math.MaxInt64)))
+			Ω(snmp.ExponentialRetryPolicy{}.AttemptTimeout(1000, time.Second)).Should(Equal(time.Duration(math.MaxInt64)))
+			policy := snmp.JitteredRetryPolicy{Fraction: 5}
+			hugePolicy := snmp.JitteredRetryPolicy{Policy: snmp.ExponentialRetryPolicy{}, Fraction: 0.5}
+			for i := 0; i < 100; i++ {
+				Ω(policy.AttemptTimeout(0, time.Second)).Should(BeNumerically("<=", 2*time.Second))
+				Ω(policy.AttemptTimeout(0, time.Second)).Should(BeNumerically(">=", 0))
+				Ω(hugePolicy.AttemptTimeout(1000, time.Second)).Should(BeNumerically(">=", math.MaxInt64/2))
+			}
+		})
+	})
+}
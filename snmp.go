@@ -1,6 +1,7 @@
 package gosnmp
 
 import (
+	"context"
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
 	"math"
@@ -45,6 +46,14 @@ type RequestProcessor interface {
 	processCommunityRequest(*communityRequest)
 }
 
+type TrapProcessor interface {
+	processTrap(SnmpMessage)
+}
+
+type UsmErrorProcessor interface {
+	processUsmError(UsmError, *net.UDPAddr)
+}
+
 type berEncodable interface {
 	encode(encoderFactory *berEncoderFactory) ([]byte, error)
 }
@@ -57,12 +66,22 @@ type snmpContext struct {
 	maxTargets int
 	port       int
 	conn       *net.UDPConn
+	startTime  time.Time
 
 	// support for client request tracking
 	requestsFromClients chan SnmpRequest
 	responsesFromAgents chan SnmpResponse
-	requestTimeouts     chan uint32
+	requestTimeouts     chan requestTimeout
 	outstandingRequests map[uint32]SnmpRequest
+	lastRequestId       uint32
+	// support for requests given up on by their senders, including any that haven't reached the request tracker yet
+	requestCancellations chan requestCancellation
+	cancelledRequests    map[SnmpRequest]error
+
+	// support for SNMPv3 engine discovery, keyed by target address and by probe request-id
+	reportsFromAgents chan *Report
+	remoteEngines     map[string]*remoteEngine
+	discoveryProbes   map[uint32]*remoteEngine
 
 	//
 	berEncoderFactory           *berEncoderFactory
@@ -81,7 +100,15 @@ type snmpContext struct {
 
 	communityRequestPool *requestPool
 
+	// users that received SNMPv3 messages can be authenticated for
+	usmUsers *usmUserTable
+	// the engine that is authoritative for received SNMPv3 messages, if this context has one
+	localEngineLock sync.Mutex
+	localEngine     *localEngine
+
 	incomingRequestProcessor RequestProcessor
+	incomingTrapProcessor    TrapProcessor
+	usmErrorProcessor        UsmErrorProcessor
 }
 
 func (ctxt *snmpContext) Shutdown() {
@@ -103,6 +130,7 @@ func (ctxt *snmpContext) initContext(name string, maxTargets int, startRequestTr
 	ctxt.Logger = logger
 	ctxt.maxTargets = maxTargets
 	ctxt.port = port
+	ctxt.startTime = time.Now()
 	ctxt.berEncoderFactory = newberEncoderFactory(logger)
 	ctxt.outboundFlowControlQueue = make(chan SnmpMessage, ctxt.maxTargets)
 	ctxt.outboundFlowControlShutdown = make(chan bool)
@@ -194,6 +222,31 @@ const (
 	StatType_V1_TRAPS_RECEIVED
 	StatType_V2_TRAPS_RECEIVED
 	StatType_COMMUNITY_REQUEST_RECEIVED_WITH_NO_REQUEST_PROCESSOR
+	StatType_TRAPS_SENT
+	StatType_INFORMS_RECEIVED
+	StatType_REPORTS_RECEIVED
+	StatType_TRAP_RECEIVED_WITH_NO_TRAP_PROCESSOR
+	StatType_TRAPS_DROPPED_ON_QUEUE_OVERFLOW
+	StatType_TRAP_HANDLER_ERRORS
+	StatType_RESPONSE_RECEIVED_WITH_NO_REQUEST_TRACKER
+	StatType_INFORMS_ACKNOWLEDGED
+	StatType_DUPLICATE_INFORMS_RECEIVED
+	StatType_USM_UNSUPPORTED_SEC_LEVELS
+	StatType_USM_NOT_IN_TIME_WINDOWS
+	StatType_USM_UNKNOWN_USER_NAMES
+	StatType_USM_UNKNOWN_ENGINE_IDS
+	StatType_USM_WRONG_DIGESTS
+	StatType_USM_DECRYPTION_ERRORS
+	StatType_RESPONSES_DROPPED_ON_SECURITY_LEVEL_MISMATCH
+	StatType_ENGINE_DISCOVERY_PROBES_SENT
+	StatType_ENGINES_DISCOVERED
+	StatType_ENGINE_DISCOVERY_FAILURES
+	StatType_REQUESTS_RESENT_AFTER_REPORT
+	StatType_REQUESTS_FAILED_BY_REPORT
+	StatType_REPORTS_DROPPED_BY_REQUEST_TRACKER
+	StatType_REPORTS_SENT
+	StatType_REQUESTS_CANCELLED
+	StatType_STALE_REQUEST_TIMEOUTS_DROPPED
 )
 
 func (statType StatType) String() string {
@@ -242,6 +295,56 @@ func (statType StatType) String() string {
 		return "V2 Traps Received"
 	case StatType_COMMUNITY_REQUEST_RECEIVED_WITH_NO_REQUEST_PROCESSOR:
 		return "Community Request Received With No Request Processor"
+	case StatType_TRAPS_SENT:
+		return "Traps Sent"
+	case StatType_INFORMS_RECEIVED:
+		return "Informs Received"
+	case StatType_REPORTS_RECEIVED:
+		return "Reports Received"
+	case StatType_TRAP_RECEIVED_WITH_NO_TRAP_PROCESSOR:
+		return "Trap Received With No Trap Processor"
+	case StatType_TRAPS_DROPPED_ON_QUEUE_OVERFLOW:
+		return "Traps Dropped On Queue Overflow"
+	case StatType_TRAP_HANDLER_ERRORS:
+		return "Trap Handler Errors"
+	case StatType_RESPONSE_RECEIVED_WITH_NO_REQUEST_TRACKER:
+		return "Response Received With No Request Tracker"
+	case StatType_INFORMS_ACKNOWLEDGED:
+		return "Informs Acknowledged"
+	case StatType_DUPLICATE_INFORMS_RECEIVED:
+		return "Duplicate Informs Received"
+	case StatType_USM_UNSUPPORTED_SEC_LEVELS:
+		return "USM Unsupported Security Levels"
+	case StatType_USM_NOT_IN_TIME_WINDOWS:
+		return "USM Not In Time Windows"
+	case StatType_USM_UNKNOWN_USER_NAMES:
+		return "USM Unknown User Names"
+	case StatType_USM_UNKNOWN_ENGINE_IDS:
+		return "USM Unknown Engine IDs"
+	case StatType_USM_WRONG_DIGESTS:
+		return "USM Wrong Digests"
+	case StatType_USM_DECRYPTION_ERRORS:
+		return "USM Decryption Errors"
+	case StatType_RESPONSES_DROPPED_ON_SECURITY_LEVEL_MISMATCH:
+		return "Responses Dropped On Security Level Mismatch"
+	case StatType_ENGINE_DISCOVERY_PROBES_SENT:
+		return "Engine Discovery Probes Sent"
+	case StatType_ENGINES_DISCOVERED:
+		return "Engines Discovered"
+	case StatType_ENGINE_DISCOVERY_FAILURES:
+		return "Engine Discovery Failures"
+	case StatType_REQUESTS_RESENT_AFTER_REPORT:
+		return "Requests Resent After Report"
+	case StatType_REQUESTS_FAILED_BY_REPORT:
+		return "Requests Failed By Report"
+	case StatType_REPORTS_DROPPED_BY_REQUEST_TRACKER:
+		return "Reports Dropped By Request Tracker"
+	case StatType_REPORTS_SENT:
+		return "Reports Sent"
+	case StatType_REQUESTS_CANCELLED:
+		return "Requests Cancelled"
+	case StatType_STALE_REQUEST_TIMEOUTS_DROPPED:
+		return "Stale Request Timeouts Dropped"
 	}
 	return "Unknown Stat Type"
 }
@@ -366,8 +469,13 @@ func (ctxt *snmpContext) GetStatsBin(bin uint8) (*StatsBin, error) {
 func (ctxt *snmpContext) startRequestTracker(maxTargets int) {
 	ctxt.requestsFromClients = make(chan SnmpRequest, maxTargets)
 	ctxt.responsesFromAgents = make(chan SnmpResponse, 100)
-	ctxt.requestTimeouts = make(chan uint32)
+	ctxt.requestTimeouts = make(chan requestTimeout)
 	ctxt.outstandingRequests = make(map[uint32]SnmpRequest)
+	ctxt.requestCancellations = make(chan requestCancellation)
+	ctxt.cancelledRequests = make(map[SnmpRequest]error)
+	ctxt.reportsFromAgents = make(chan *Report, 100)
+	ctxt.remoteEngines = make(map[string]*remoteEngine)
+	ctxt.discoveryProbes = make(map[uint32]*remoteEngine)
 	go ctxt.trackRequests()
 	return
 }
@@ -377,18 +485,73 @@ func (ctxt *snmpContext) sendRequest(req SnmpRequest) {
 	ctxt.requestsFromClients <- req
 }
 
+// requestTimeout tells the request tracker that an attempt at sending a request has timed out. A timer can fire just as
+// it's stopped, so timeouts for any attempt but the request's latest are stale, and are dropped.
+type requestTimeout struct {
+	requestId uint32
+	attempt   uint32
+}
+
+// requestCancellation asks the request tracker to give up on a request, failing it with err.
+type requestCancellation struct {
+	req SnmpRequest
+	err error
+}
+
+// sendRequestContext sends a request and waits for it to complete, unless ctx is done first. In that case the request is
+// withdrawn from the request tracker, and fails with the context's error.
+func (ctxt *snmpContext) sendRequestContext(ctx context.Context, req SnmpRequest) {
+	if ctx.Done() == nil {
+		// the context can never be cancelled
+		ctxt.sendRequest(req)
+		req.wait()
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		req.setTransportError(err)
+		return
+	}
+	select {
+	case ctxt.requestsFromClients <- req:
+		ctxt.incrementStat(StatType_REQUESTS_SENT)
+	case <-ctx.Done():
+		req.setTransportError(ctx.Err())
+		return
+	}
+	select {
+	case <-req.done():
+	case <-ctx.Done():
+		select {
+		case ctxt.requestCancellations <- requestCancellation{req, ctx.Err()}:
+			// the tracker completes the request once it's been withdrawn
+			req.wait()
+		case <-req.done():
+			// the request completed before the tracker could take the cancellation
+		}
+	}
+}
+
 func (ctxt *snmpContext) trackRequests() {
-	var nextRequestId uint32 = 0
 	ctxt.Debugf("Ctxt %s: request tracker initializing", ctxt.name)
 	for {
 		select {
 		case outboundReq := <-ctxt.requestsFromClients:
-			nextRequestId += 1
-			outboundReq.setRequestId(nextRequestId)
-			ctxt.outstandingRequests[nextRequestId] = outboundReq
-			outboundReq.startTimer(ctxt.handleRequestTimeout)
-			ctxt.incrementStat(StatType_REQUESTS_FORWARDED_TO_FLOW_CONTROL)
-			ctxt.outboundFlowControlQueue <- outboundReq
+			if err, ok := ctxt.cancelledRequests[outboundReq]; ok {
+				// cancelled while it was queued for the tracker
+				delete(ctxt.cancelledRequests, outboundReq)
+				outboundReq.setTransportError(err)
+				ctxt.incrementStat(StatType_REQUESTS_CANCELLED)
+				outboundReq.notify()
+				continue
+			}
+			ctxt.lastRequestId += 1
+			outboundReq.setRequestId(ctxt.lastRequestId)
+			outboundReq.resetFlightTimes()
+			ctxt.outstandingRequests[ctxt.lastRequestId] = outboundReq
+			if outboundReq.getVersion() == Version3 && !ctxt.trackV3Request(outboundReq) {
+				continue // held back until the target's engine has been discovered
+			}
+			ctxt.forwardRequest(outboundReq)
 
 		case responseFromRemoteAgent := <-ctxt.responsesFromAgents:
 			originatingRequest := ctxt.outstandingRequests[responseFromRemoteAgent.getRequestId()]
@@ -396,26 +559,51 @@ func (ctxt *snmpContext) trackRequests() {
 				ctxt.incrementStat(StatType_RESPONSES_DROPPED_BY_REQUEST_TRACKER)
 				continue // most likely we've already timed out the request.
 			}
+			if !securityLevelsMatch(originatingRequest, responseFromRemoteAgent) {
+				ctxt.incrementStat(StatType_RESPONSES_DROPPED_ON_SECURITY_LEVEL_MISMATCH)
+				continue
+			}
+			if !ctxt.checkResponseTimeliness(originatingRequest, responseFromRemoteAgent) {
+				continue
+			}
 			delete(ctxt.outstandingRequests, originatingRequest.getRequestId())
 			originatingRequest.stopTimer()
 			originatingRequest.setResponse(responseFromRemoteAgent)
 			ctxt.incrementStat(StatType_RESPONSES_RELEASED_TO_CLIENT)
 			originatingRequest.notify()
 
-		case requestId := <-ctxt.requestTimeouts:
-			timedoutRequest := ctxt.outstandingRequests[requestId]
+		case report := <-ctxt.reportsFromAgents:
+			ctxt.processReport(report)
+
+		case cancellation := <-ctxt.requestCancellations:
+			ctxt.cancelRequest(cancellation.req, cancellation.err)
+
+		case timeout := <-ctxt.requestTimeouts:
+			if engine := ctxt.discoveryProbes[timeout.requestId]; engine != nil {
+				if !engine.discoveryProbe.isCurrentAttempt(timeout.attempt) {
+					ctxt.incrementStat(StatType_STALE_REQUEST_TIMEOUTS_DROPPED)
+					continue
+				}
+				ctxt.handleDiscoveryProbeTimeout(engine)
+				continue
+			}
+			timedoutRequest := ctxt.outstandingRequests[timeout.requestId]
 			if timedoutRequest == nil {
-				ctxt.Errorf("Context %s: Got request timeout for unknown requestid: %d", ctxt.name, requestId)
+				ctxt.Errorf("Context %s: Got request timeout for unknown requestid: %d", ctxt.name, timeout.requestId)
 				ctxt.incrementStat(StatType_UNKNOWN_REQUESTS_TIMED_OUT)
 				continue
 			}
+			if !timedoutRequest.isCurrentAttempt(timeout.attempt) {
+				ctxt.Debugf("Ctxt %s: dropping stale timeout for %s", ctxt.name, timedoutRequest.LoggingId())
+				ctxt.incrementStat(StatType_STALE_REQUEST_TIMEOUTS_DROPPED)
+				continue
+			}
 			if timedoutRequest.isRetryRequired() {
-				timedoutRequest.startTimer(ctxt.handleRequestTimeout)
 				ctxt.incrementStat(StatType_REQUESTS_TIMED_OUT)
-				ctxt.incrementStat(StatType_REQUESTS_FORWARDED_TO_FLOW_CONTROL)
-				ctxt.outboundFlowControlQueue <- timedoutRequest
+				ctxt.forwardRequest(timedoutRequest)
 			} else {
 				delete(ctxt.outstandingRequests, timedoutRequest.getRequestId())
+				timedoutRequest.stopTimer()
 				timedoutRequest.setTransportError(TimeoutError{})
 				ctxt.incrementStat(StatType_REQUEST_RETRIES_EXHAUSTED)
 				ctxt.Debugf("Ctxt %s: final timeout for %s", ctxt.name, timedoutRequest.LoggingId())
@@ -429,14 +617,62 @@ func (ctxt *snmpContext) trackRequests() {
 	}
 }
 
-func (ctxt *snmpContext) handleRequestTimeout(req SnmpRequest) {
-	ctxt.requestTimeouts <- req.getRequestId()
+// cancelRequest removes a request from the tracker, stopping its timer, and fails it with err. The sender only asks for a
+// cancellation before the request has completed, so a request that isn't outstanding is still on its way to the tracker,
+// and is failed when it arrives.
+func (ctxt *snmpContext) cancelRequest(req SnmpRequest, err error) {
+	if ctxt.outstandingRequests[req.getRequestId()] != req {
+		ctxt.cancelledRequests[req] = err
+		return
+	}
+	delete(ctxt.outstandingRequests, req.getRequestId())
+	if !ctxt.releaseWaitingV3Request(req) {
+		req.stopTimer()
+	}
+	req.setTransportError(err)
+	ctxt.incrementStat(StatType_REQUESTS_CANCELLED)
+	ctxt.Debugf("Ctxt %s: cancelled %s - err: %s", ctxt.name, req.LoggingId(), err)
+	req.notify()
+}
+
+// securityLevelsMatch checks that a response was sent with the same version and, for SNMPv3, the same user and security
+// level as the request it answers. Anything else could have been forged.
+func securityLevelsMatch(req SnmpRequest, resp SnmpResponse) bool {
+	if req.getVersion() != resp.getVersion() {
+		return false
+	}
+	if req.getVersion() != Version3 {
+		return true
+	}
+	reqParams := req.(snmpCommunityMessage).getV3Params()
+	respParams := resp.(snmpCommunityMessage).getV3Params()
+	securityFlags := v3MsgFlags_AUTH | v3MsgFlags_PRIV
+	return reqParams.userName == respParams.userName && reqParams.flags&securityFlags == respParams.flags&securityFlags
+}
+
+func (ctxt *snmpContext) handleRequestTimeout(req SnmpRequest, attempt uint32) {
+	ctxt.requestTimeouts <- requestTimeout{req.getRequestId(), attempt}
 }
 
 func (ctxt *snmpContext) sendResponse(resp SnmpResponse) {
 	ctxt.outboundFlowControlQueue <- resp
 }
 
+// sysUpTime returns the time since the context was created, in hundredths of a second
+func (ctxt *snmpContext) sysUpTime() uint32 {
+	return uint32(time.Since(ctxt.startTime) / (10 * time.Millisecond))
+}
+
+func (ctxt *snmpContext) sendTrap(trap SnmpMessage) {
+	ctxt.incrementStat(StatType_TRAPS_SENT)
+	ctxt.sendMessage(trap)
+}
+
+// sendMessage queues a message that needs no request tracking for transmission
+func (ctxt *snmpContext) sendMessage(msg SnmpMessage) {
+	ctxt.outboundFlowControlQueue <- msg
+}
+
 func (ctxt *snmpContext) processOutboundQueue() {
 	defer func() {
 		ctxt.outboundDied <- true
@@ -517,8 +753,14 @@ func (ctxt *snmpContext) listen() {
 }
 
 func (ctxt *snmpContext) processIncomingMessage(msg []byte, addr *net.UDPAddr) {
-	decodedMsg, err := decodeMsg(msg)
+	decodedMsg, err := decodeMsgWithUsm(msg, ctxt.usmUsers, ctxt.getLocalEngine())
 	if err != nil {
+		if usmErr, ok := err.(UsmError); ok {
+			ctxt.incrementStat(usmErr.Type.statType())
+			if ctxt.usmErrorProcessor != nil {
+				ctxt.usmErrorProcessor.processUsmError(usmErr, addr)
+			}
+		}
 		ctxt.incrementStat(StatType_INBOUND_MESSAGES_UNDECODABLE)
 		if ctxt.logDecodeErrors {
 			ctxt.Debugf("Ctxt %s: Couldn't decode message % #x. Err: %s\n", ctxt.name, msg, err)
@@ -530,6 +772,12 @@ func (ctxt *snmpContext) processIncomingMessage(msg []byte, addr *net.UDPAddr) {
 	ctxt.routeIncomingMessage(decodedMsg)
 }
 
+func (ctxt *snmpContext) getLocalEngine() *localEngine {
+	ctxt.localEngineLock.Lock()
+	defer ctxt.localEngineLock.Unlock()
+	return ctxt.localEngine
+}
+
 func (ctxt *snmpContext) recordIncomingMessage(msg SnmpMessage) {
 	switch msg.getPduType() {
 	case pduType_GET_REQUEST:
@@ -546,6 +794,10 @@ func (ctxt *snmpContext) recordIncomingMessage(msg SnmpMessage) {
 		ctxt.incrementStat(StatType_V1_TRAPS_RECEIVED)
 	case pduType_V2_TRAP:
 		ctxt.incrementStat(StatType_V2_TRAPS_RECEIVED)
+	case pduType_INFORM_REQUEST:
+		ctxt.incrementStat(StatType_INFORMS_RECEIVED)
+	case pduType_REPORT:
+		ctxt.incrementStat(StatType_REPORTS_RECEIVED)
 	}
 }
 
@@ -557,7 +809,24 @@ func (ctxt *snmpContext) routeIncomingMessage(msg SnmpMessage) {
 			return
 		}
 		ctxt.incomingRequestProcessor.processCommunityRequest(msg.(*communityRequest))
+	case *Report:
+		if ctxt.reportsFromAgents == nil {
+			ctxt.incrementStat(StatType_RESPONSE_RECEIVED_WITH_NO_REQUEST_TRACKER)
+			return
+		}
+		ctxt.reportsFromAgents <- msg.(*Report)
+	case *V1Trap, *V2Trap, *InformRequest:
+		if ctxt.incomingTrapProcessor == nil {
+			ctxt.incrementStat(StatType_TRAP_RECEIVED_WITH_NO_TRAP_PROCESSOR)
+			return
+		}
+		ctxt.incomingTrapProcessor.processTrap(msg)
 	case SnmpResponse:
+		if ctxt.responsesFromAgents == nil {
+			// Trap receivers don't run a request tracker, so there's nobody waiting on this.
+			ctxt.incrementStat(StatType_RESPONSE_RECEIVED_WITH_NO_REQUEST_TRACKER)
+			return
+		}
 		ctxt.responsesFromAgents <- msg.(SnmpResponse)
 	}
 }
@@ -30,5 +30,49 @@ func SetupLowLevelContextTest(logger seelog.LoggerInterface, testIdGenerator cha
 				Ω(stats.Stats[StatType_INBOUND_CONNECTION_CLOSE]).Should(Equal(1))
 			})
 		})
+		Describe("timing out requests", func() {
+			AfterEach(func() {
+				clientCtxt.Shutdown()
+			})
+			stat := func(statType StatType) func() int {
+				return func() int {
+					value, _ := clientCtxt.GetStat(statType, 0)
+					return value
+				}
+			}
+			It("should stop the timer of a request's previous attempt when it starts a new one", func(done Done) {
+				req := newCommunityRequest()
+				req.timeout = 100 * time.Millisecond
+				attempts := make(chan uint32, 2)
+				timeoutFunc := func(req SnmpRequest, attempt uint32) { attempts <- attempt }
+				req.startTimer(timeoutFunc)
+				req.startTimer(timeoutFunc)
+				Ω(<-attempts).Should(Equal(uint32(2)))
+				Ω(req.isCurrentAttempt(2)).Should(BeTrue())
+				Consistently(attempts, 0.2).ShouldNot(Receive())
+				close(done)
+			}, 2)
+			It("should drop timeouts for attempts that are no longer in flight", func(done Done) {
+				client, _ := clientCtxt.NewV2cClientWithPort("public", "localhost", 2179)
+				client.Timeout = 500 * time.Millisecond
+				client.Retries = 0
+				req := clientCtxt.AllocateV2cGetRequest()
+				sent := make(chan bool)
+				go func() {
+					client.SendRequest(req)
+					sent <- true
+				}()
+				Eventually(stat(StatType_REQUESTS_FORWARDED_TO_FLOW_CONTROL)).Should(Equal(1))
+				// the context's first request has request-id 1, and its first attempt is attempt 1
+				clientCtxt.requestTimeouts <- requestTimeout{1, 0}
+				Eventually(stat(StatType_STALE_REQUEST_TIMEOUTS_DROPPED)).Should(Equal(1))
+				Consistently(sent, 0.2).ShouldNot(Receive())
+				<-sent
+				_, ok := req.TransportError().(TimeoutError)
+				Ω(ok).Should(BeTrue())
+				Ω(req.AttemptFlightTimes()).Should(HaveLen(1))
+				close(done)
+			}, 3)
+		})
 	})
 }
@@ -0,0 +1,176 @@
+package gosnmp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// tableVarbindsPerRequest is roughly how many varbinds GetTable asks for in each GetBulk. It's split evenly between the
+// columns still being retrieved.
+const tableVarbindsPerRequest = 40
+
+// TableRow is a single conceptual row of a table. Columns is keyed by column number (the sub-identifier following the entry
+// oid). In a sparse table, columns that the agent doesn't have for this row are simply missing from the map.
+type TableRow struct {
+	Index   ObjectIdentifier
+	Columns map[uint32]Varbind
+}
+
+// Table holds the rows retrieved by GetTable, in index order.
+type Table struct {
+	Rows    []*TableRow
+	byIndex map[string]*TableRow
+}
+
+// Row returns the row with the given index, or nil if the table has no such row.
+func (table *Table) Row(index ObjectIdentifier) *TableRow {
+	return table.byIndex[indexKey(index)]
+}
+
+func newTable() *Table {
+	return &Table{byIndex: make(map[string]*TableRow)}
+}
+
+func indexKey(index ObjectIdentifier) string {
+	return fmt.Sprint([]uint32(index))
+}
+
+func (table *Table) addVarbind(column uint32, index ObjectIdentifier, vb Varbind) {
+	key := indexKey(index)
+	row := table.byIndex[key]
+	if row == nil {
+		row = &TableRow{Index: index, Columns: make(map[uint32]Varbind)}
+		table.byIndex[key] = row
+		table.Rows = append(table.Rows, row)
+	}
+	row.Columns[column] = vb
+}
+
+type tableRowsByIndex []*TableRow
+
+func (rows tableRowsByIndex) Len() int           { return len(rows) }
+func (rows tableRowsByIndex) Swap(i, j int)      { rows[i], rows[j] = rows[j], rows[i] }
+func (rows tableRowsByIndex) Less(i, j int) bool { return rows[i].Index.Compare(rows[j].Index) < 0 }
+
+// tableColumn tracks the progress of the walk down a single column
+type tableColumn struct {
+	number  uint32
+	oid     ObjectIdentifier
+	lastOid ObjectIdentifier
+}
+
+// GetTable retrieves the table under entryOid (e.g. ifEntry, 1.3.6.1.2.1.2.2.1) and returns its rows grouped by index. The
+// given columns are walked side by side, with each GetBulk request carrying one varbind per column that isn't finished yet.
+// If no columns are given, the whole entry is walked one column after another instead.
+func (client *V2cClient) GetTable(entryOid ObjectIdentifier, columns ...uint32) (*Table, error) {
+	table := newTable()
+	var err error
+	if len(columns) == 0 {
+		err = client.getWholeTable(entryOid, table)
+	} else {
+		err = client.getTableColumns(entryOid, columns, table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(tableRowsByIndex(table.Rows))
+	return table, nil
+}
+
+func (client *V2cClient) getWholeTable(entryOid ObjectIdentifier, table *Table) error {
+	return client.BulkWalk(entryOid, tableVarbindsPerRequest, func(vb Varbind) error {
+		oid := vb.GetOid()
+		if len(oid) < len(entryOid)+2 {
+			// not a column instance, just ignore it
+			return nil
+		}
+		table.addVarbind(oid[len(entryOid)], oid[len(entryOid)+1:], vb)
+		return nil
+	})
+}
+
+func (client *V2cClient) getTableColumns(entryOid ObjectIdentifier, columns []uint32, table *Table) error {
+	active := make([]*tableColumn, 0, len(columns))
+	for _, column := range columns {
+		oid := make(ObjectIdentifier, len(entryOid), len(entryOid)+1)
+		copy(oid, entryOid)
+		oid = append(oid, column)
+		active = append(active, &tableColumn{number: column, oid: oid, lastOid: oid})
+	}
+	ctxt := client.snmpContext
+	for len(active) > 0 {
+		maxRepetitions := tableVarbindsPerRequest / len(active)
+		if maxRepetitions < 1 {
+			maxRepetitions = 1
+		}
+		req := ctxt.AllocateV2cGetBulkRequest(0, maxRepetitions)
+		for _, column := range active {
+			req.AddOid(column.lastOid)
+		}
+		client.SendRequest(req)
+		finished, err := processTableResponse(req, active, table)
+		ctxt.FreeV2cRequest(req)
+		if err != nil {
+			return err
+		}
+		remaining := active[:0]
+		for i, column := range active {
+			if !finished[i] {
+				remaining = append(remaining, column)
+			}
+		}
+		active = remaining
+	}
+	return nil
+}
+
+// processTableResponse sorts the varbinds in a GetBulk response into table rows. The response holds up to maxRepetitions
+// rounds of varbinds, with one varbind per requested column in each round. It returns which of the columns have been
+// completely retrieved.
+func processTableResponse(req V2cGetBulkRequest, columns []*tableColumn, table *Table) ([]bool, error) {
+	if err := req.TransportError(); err != nil {
+		return nil, err
+	}
+	resp := req.Response()
+	finished := make([]bool, len(columns))
+	if resp.ErrorVal() == SnmpRequestErrorType_NO_SUCH_NAME {
+		for i := range finished {
+			finished[i] = true
+		}
+		return finished, nil
+	}
+	if resp.ErrorVal() != SnmpRequestErrorType_NO_ERROR {
+		return nil, RequestError{resp.ErrorVal(), resp.ErrorIdx()}
+	}
+	varbinds := resp.Varbinds()
+	if len(varbinds) == 0 {
+		for i := range finished {
+			finished[i] = true
+		}
+		return finished, nil
+	}
+	for i, vb := range varbinds {
+		columnIdx := i % len(columns)
+		if finished[columnIdx] {
+			continue
+		}
+		column := columns[columnIdx]
+		switch vb.(type) {
+		case *EndOfMibViewVarbind, *NoSuchObjectVarbind, *NoSuchInstanceVarbind:
+			finished[columnIdx] = true
+			continue
+		}
+		oid := vb.GetOid()
+		if column.oid.MatchLength(oid) != len(column.oid) || len(oid) == len(column.oid) {
+			// walked off the end of this column
+			finished[columnIdx] = true
+			continue
+		}
+		if oid.Compare(column.lastOid) <= 0 {
+			return nil, OidNotIncreasingError{column.lastOid, oid}
+		}
+		column.lastOid = oid
+		table.addVarbind(column.number, oid[len(column.oid):], vb)
+	}
+	return finished, nil
+}
@@ -0,0 +1,208 @@
+package gosnmp
+
+import (
+	"fmt"
+	"net"
+)
+
+// IndexType is the syntax of one of the objects in a table's INDEX clause, which determines how its value is encoded in
+// the instance oids of the table's cells, as described in RFC 2578 section 7.7.
+type IndexType int
+
+const (
+	IndexType_INTEGER                   IndexType = 0 // INTEGER, Unsigned32 and the like: a single sub-identifier
+	IndexType_FIXED_LENGTH_OCTET_STRING           = 1 // an OCTET STRING of a fixed size, encoded without a length
+	IndexType_OCTET_STRING                        = 2 // a variable length OCTET STRING, preceded by its length
+	IndexType_IP_ADDRESS                          = 3 // an IpAddress: four sub-identifiers
+	IndexType_OBJECT_IDENTIFIER                   = 4 // an OBJECT IDENTIFIER, preceded by its length
+)
+
+func (indexType IndexType) String() string {
+	switch indexType {
+	case IndexType_INTEGER:
+		return "INTEGER"
+	case IndexType_FIXED_LENGTH_OCTET_STRING:
+		return "fixed length OCTET STRING"
+	case IndexType_OCTET_STRING:
+		return "OCTET STRING"
+	case IndexType_IP_ADDRESS:
+		return "IpAddress"
+	case IndexType_OBJECT_IDENTIFIER:
+		return "OBJECT IDENTIFIER"
+	default:
+		return "Unknown"
+	}
+}
+
+// IndexSpec describes one of the objects in a table's INDEX clause. Length is the size of a fixed length OCTET STRING.
+// Implied may only be set on the last object of the index, when it's a variable length OCTET STRING or an OBJECT
+// IDENTIFIER, and means that its length isn't encoded.
+type IndexSpec struct {
+	Type    IndexType
+	Length  int
+	Implied bool
+}
+
+// TableIndex is the decoded index of a table row, with one value for each object in the table's INDEX clause. The values
+// are uint32 for INTEGER, []byte for both kinds of OCTET STRING, net.IP for IpAddress, and ObjectIdentifier for OBJECT
+// IDENTIFIER. When encoding, int, int32 and string values are also accepted, but a TableHandler is always given
+// indexes in their decoded form.
+type TableIndex []interface{}
+
+// IndexError is returned when a table index can't be encoded or decoded
+type IndexError struct {
+	details string
+}
+
+func (e IndexError) Error() string {
+	return "Invalid table index: " + e.details
+}
+
+// EncodeIndex returns the sub-identifiers that represent the index in the instance oids of a table's cells.
+func EncodeIndex(index TableIndex, specs []IndexSpec) (ObjectIdentifier, error) {
+	if len(index) != len(specs) {
+		return nil, IndexError{fmt.Sprintf("index has %d values, expecting %d", len(index), len(specs))}
+	}
+	var oid ObjectIdentifier
+	for i, spec := range specs {
+		var err error
+		if oid, err = encodeIndexValue(oid, index[i], spec); err != nil {
+			return nil, err
+		}
+	}
+	return oid, nil
+}
+
+func encodeIndexValue(oid ObjectIdentifier, val interface{}, spec IndexSpec) (ObjectIdentifier, error) {
+	switch spec.Type {
+	case IndexType_INTEGER:
+		switch v := val.(type) {
+		case uint32:
+			return append(oid, v), nil
+		case int32:
+			if v >= 0 {
+				return append(oid, uint32(v)), nil
+			}
+		case int:
+			if v >= 0 && int64(v) <= int64(^uint32(0)) {
+				return append(oid, uint32(v)), nil
+			}
+		}
+	case IndexType_FIXED_LENGTH_OCTET_STRING, IndexType_OCTET_STRING:
+		var bytes []byte
+		switch v := val.(type) {
+		case []byte:
+			bytes = v
+		case string:
+			bytes = []byte(v)
+		default:
+			return nil, IndexError{fmt.Sprintf("%T value %v for %s", val, val, spec.Type)}
+		}
+		if spec.Type == IndexType_FIXED_LENGTH_OCTET_STRING && len(bytes) != spec.Length {
+			return nil, IndexError{fmt.Sprintf("%d byte value for a %d byte string", len(bytes), spec.Length)}
+		}
+		if spec.Type == IndexType_OCTET_STRING && !spec.Implied {
+			oid = append(oid, uint32(len(bytes)))
+		}
+		for _, b := range bytes {
+			oid = append(oid, uint32(b))
+		}
+		return oid, nil
+	case IndexType_IP_ADDRESS:
+		if ip, ok := val.(net.IP); ok && ip.To4() != nil {
+			for _, b := range ip.To4() {
+				oid = append(oid, uint32(b))
+			}
+			return oid, nil
+		}
+	case IndexType_OBJECT_IDENTIFIER:
+		if v, ok := val.(ObjectIdentifier); ok {
+			if !spec.Implied {
+				oid = append(oid, uint32(len(v)))
+			}
+			return append(oid, v...), nil
+		}
+	}
+	return nil, IndexError{fmt.Sprintf("%T value %v for %s", val, val, spec.Type)}
+}
+
+// DecodeIndex decodes the index at the start of oid, which is the part of a cell's instance oid that follows the column
+// number. It returns the index, and the number of sub-identifiers it was encoded in. Sub-identifiers left over after the
+// index are not an error, since GetNext requests can name oids that aren't instances.
+func DecodeIndex(oid ObjectIdentifier, specs []IndexSpec) (TableIndex, int, error) {
+	index := make(TableIndex, len(specs))
+	pos := 0
+	for i, spec := range specs {
+		remaining := oid[pos:]
+		length := 0
+		switch spec.Type {
+		case IndexType_INTEGER:
+			length = 1
+		case IndexType_FIXED_LENGTH_OCTET_STRING:
+			length = spec.Length
+		case IndexType_IP_ADDRESS:
+			length = 4
+		case IndexType_OCTET_STRING, IndexType_OBJECT_IDENTIFIER:
+			if spec.Implied {
+				length = len(remaining)
+			} else {
+				if len(remaining) == 0 {
+					return nil, 0, IndexError{fmt.Sprintf("missing length of index value %d", i+1)}
+				}
+				length = int(remaining[0])
+				remaining = remaining[1:]
+				pos++
+			}
+		default:
+			return nil, 0, IndexError{fmt.Sprintf("unknown index type %d", spec.Type)}
+		}
+		if length > len(remaining) {
+			return nil, 0, IndexError{fmt.Sprintf("index value %d is truncated", i+1)}
+		}
+		encoded := remaining[:length]
+		pos += length
+		switch spec.Type {
+		case IndexType_INTEGER:
+			index[i] = encoded[0]
+		case IndexType_FIXED_LENGTH_OCTET_STRING, IndexType_OCTET_STRING, IndexType_IP_ADDRESS:
+			bytes := make([]byte, length)
+			for j, subId := range encoded {
+				if subId > 255 {
+					return nil, 0, IndexError{fmt.Sprintf("sub-identifier %d in index value %d isn't an octet", subId, i+1)}
+				}
+				bytes[j] = byte(subId)
+			}
+			if spec.Type == IndexType_IP_ADDRESS {
+				index[i] = net.IP(bytes)
+			} else {
+				index[i] = bytes
+			}
+		case IndexType_OBJECT_IDENTIFIER:
+			index[i] = append(ObjectIdentifier{}, encoded...)
+		}
+	}
+	return index, pos, nil
+}
+
+// nextIndex returns the index that comes first after index, in the order of their encodings, from a set of indexes in no
+// particular order. A nil index asks for the first of them. It returns nil if none of them come after index.
+func nextIndex(index TableIndex, indexes []TableIndex, specs []IndexSpec) (TableIndex, error) {
+	var after, nextOid ObjectIdentifier
+	if index != nil {
+		var err error
+		if after, err = EncodeIndex(index, specs); err != nil {
+			return nil, err
+		}
+	}
+	var next TableIndex
+	for _, candidate := range indexes {
+		oid, err := EncodeIndex(candidate, specs)
+		if err != nil {
+			return nil, err
+		}
+		if (index == nil || oid.Compare(after) > 0) && (next == nil || oid.Compare(nextOid) < 0) {
+			next, nextOid = candidate, oid
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,84 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func SetupTableTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Retrieving a table", func() {
+		var (
+			clientCtxt *ClientContext
+			client     *V2cClient
+			agent      *fakeWalkAgent
+			ifEntry    = ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1}
+			column     = func(col uint32, index uint32) ObjectIdentifier {
+				return ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, col, index}
+			}
+			// row 2 has no ifDescr
+			varbinds = []Varbind{
+				NewIntegerVarbind(column(1, 1), 1),
+				NewIntegerVarbind(column(1, 2), 2),
+				NewIntegerVarbind(column(1, 3), 3),
+				NewStringVarbind(column(2, 1), "lo"),
+				NewStringVarbind(column(2, 3), "eth1"),
+				NewIntegerVarbind(column(3, 1), 24),
+				NewIntegerVarbind(column(3, 2), 6),
+				NewIntegerVarbind(column(3, 3), 6),
+				NewIntegerVarbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 4, 1, 0}, 2),
+			}
+		)
+		BeforeEach(func() {
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2163)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+			agent = newFakeWalkAgent(2163, varbinds, nil, clientCtxt)
+		})
+		AfterEach(func() {
+			agent.conn.Close()
+			clientCtxt.Shutdown()
+		})
+
+		validateTable := func(table *Table) {
+			Ω(table.Rows).Should(HaveLen(3))
+			for i, row := range table.Rows {
+				Ω(row.Index).Should(Equal(ObjectIdentifier{uint32(i + 1)}))
+				Ω(row.Columns).Should(HaveKey(uint32(1)))
+				Ω(row.Columns[1].(*IntegerVarbind).Value).Should(Equal(int32(i + 1)))
+			}
+			Ω(string(table.Row(ObjectIdentifier{1}).Columns[2].(*OctetStringVarbind).Value)).Should(Equal("lo"))
+			Ω(table.Row(ObjectIdentifier{2}).Columns).ShouldNot(HaveKey(uint32(2)))
+			Ω(table.Row(ObjectIdentifier{3}).Columns[3].(*IntegerVarbind).Value).Should(Equal(int32(6)))
+			Ω(table.Row(ObjectIdentifier{4})).Should(BeNil())
+		}
+
+		It("should retrieve the requested columns side by side", func() {
+			table, err := client.GetTable(ifEntry, 1, 2, 3)
+			Ω(err).Should(BeNil())
+			validateTable(table)
+			Ω(table.Rows[0].Columns).Should(HaveLen(3))
+			Ω(clientCtxt.GetStat(StatType_REQUESTS_SENT, 0)).Should(Equal(1))
+		})
+		It("should only retrieve the requested columns", func() {
+			table, err := client.GetTable(ifEntry, 1, 3)
+			Ω(err).Should(BeNil())
+			Ω(table.Rows).Should(HaveLen(3))
+			for _, row := range table.Rows {
+				Ω(row.Columns).Should(HaveLen(2))
+				Ω(row.Columns).ShouldNot(HaveKey(uint32(2)))
+			}
+		})
+		It("should retrieve every column if none are requested", func() {
+			table, err := client.GetTable(ifEntry)
+			Ω(err).Should(BeNil())
+			validateTable(table)
+		})
+		It("should return an empty table if there are no rows", func() {
+			table, err := client.GetTable(ObjectIdentifier{1, 3, 6, 1, 2, 1, 4, 20, 1}, 1, 2)
+			Ω(err).Should(BeNil())
+			Ω(table.Rows).Should(BeEmpty())
+		})
+	})
+}
@@ -1,11 +1,248 @@
 package gosnmp
 
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// TrapHandler is the interface to implement in order to have a TrapReceiver deliver notifications by callback rather
+// than through its Traps() channel.
+type TrapHandler interface {
+	// HandleTrap is called once for each notification received, in the order they were received. trap will be a *V1Trap,
+	// *V2Trap or *InformRequest. HandleTrap is called from a single goroutine, so a slow handler will cause notifications
+	// to back up in the receive queue, and eventually be dropped.
+	HandleTrap(trap SnmpMessage) error
+}
+
 type TrapReceiver struct {
 	snmpContext
+	trapQueue chan SnmpMessage
+	informs   *informCache
+
+	handlerLock     sync.Mutex
+	handlerSet      bool
+	deferInformAcks bool
 }
 
+// NewTrapReceiver creates a trap receiver listening on the given port. If port is 0, the standard trap port (162) is used.
+// Up to queueDepth notifications will be held while waiting for delivery. Any notifications received while the queue is
+// full will be dropped.
+//
+// InformRequests are acknowledged automatically, by default as soon as they've been queued for delivery. Informs dropped
+// because the queue is full aren't acknowledged, so that their senders retransmit them. Retransmissions of an inform that
+// has already been received are acknowledged again, but aren't delivered a second time.
 func NewTrapReceiver(name string, queueDepth int, port int, logger Logger) *TrapReceiver {
+	if port == 0 {
+		port = 162
+	}
 	trapReceiver := new(TrapReceiver)
-	trapReceiver.snmpContext.initContext(name, 0, true, 0, logger)
+	trapReceiver.trapQueue = make(chan SnmpMessage, queueDepth)
+	trapReceiver.informs = newInformCache(informDuplicateWindow)
+	trapReceiver.incomingTrapProcessor = trapReceiver
+	trapReceiver.snmpContext.initContext(name, queueDepth, false, port, logger)
 	return trapReceiver
 }
+
+// Traps returns the channel on which received notifications are delivered when no TrapHandler has been set. Each
+// notification will be a *V1Trap, *V2Trap or *InformRequest. The channel is never closed.
+func (receiver *TrapReceiver) Traps() <-chan SnmpMessage {
+	return receiver.trapQueue
+}
+
+// SetHandler causes all subsequent notifications to be delivered to the given handler rather than through the Traps()
+// channel. It should be called at most once, and the Traps() channel should not be read once a handler has been set.
+func (receiver *TrapReceiver) SetHandler(handler TrapHandler) {
+	receiver.handlerLock.Lock()
+	receiver.handlerSet = true
+	receiver.handlerLock.Unlock()
+	go receiver.dispatchTraps(handler)
+}
+
+// SetInformAckAfterHandling controls when InformRequests are acknowledged. When enabled, an inform is only acknowledged
+// once the TrapHandler has returned nil for it. If the handler returns an error, the inform isn't acknowledged, and the
+// handler will be called again when the sender retransmits it. This setting has no effect until a handler has been set.
+func (receiver *TrapReceiver) SetInformAckAfterHandling(enabled bool) {
+	receiver.handlerLock.Lock()
+	defer receiver.handlerLock.Unlock()
+	receiver.deferInformAcks = enabled
+}
+
+func (receiver *TrapReceiver) isInformAckDeferred() bool {
+	receiver.handlerLock.Lock()
+	defer receiver.handlerLock.Unlock()
+	return receiver.handlerSet && receiver.deferInformAcks
+}
+
+func (receiver *TrapReceiver) dispatchTraps(handler TrapHandler) {
+	receiver.Debugf("Ctxt %s: trap dispatcher initializing", receiver.name)
+	for {
+		select {
+		case trap := <-receiver.trapQueue:
+			err := handler.HandleTrap(trap)
+			if err != nil {
+				receiver.Debugf("Ctxt %s: trap handler failed for %s from %s, err: %s", receiver.name, trap.LoggingId(), trap.Address(), err)
+				receiver.incrementStat(StatType_TRAP_HANDLER_ERRORS)
+			}
+			if inform, ok := trap.(*InformRequest); ok {
+				receiver.completeInform(inform, err == nil)
+			}
+		case <-receiver.internalShutdownNotification:
+			receiver.Debugf("Ctxt %s: trap dispatcher shutting down due to snmpContext shutdown", receiver.name)
+			return
+		}
+	}
+}
+
+func (receiver *TrapReceiver) processTrap(trap SnmpMessage) {
+	inform, isInform := trap.(*InformRequest)
+	ackDeferred := false
+	if isInform {
+		ackDeferred = receiver.isInformAckDeferred()
+		if !receiver.processInform(inform, ackDeferred) {
+			return
+		}
+	}
+	select {
+	case receiver.trapQueue <- trap:
+		if isInform && !ackDeferred {
+			receiver.acknowledgeInform(informKeyFor(inform), inform)
+		}
+	default:
+		receiver.incrementStat(StatType_TRAPS_DROPPED_ON_QUEUE_OVERFLOW)
+		if isInform {
+			// Forget that we saw this one, and leave it unacknowledged, so that it's delivered when the sender retransmits it.
+			receiver.informs.remove(informKeyFor(inform))
+		}
+	}
+}
+
+// processInform handles duplicate detection for an incoming inform, acknowledging retransmissions of informs that have
+// already been acknowledged. It returns true if the inform should be delivered.
+func (receiver *TrapReceiver) processInform(inform *InformRequest, ackDeferred bool) bool {
+	ack, isDuplicate := receiver.informs.add(informKeyFor(inform), ackDeferred)
+	if isDuplicate {
+		receiver.incrementStat(StatType_DUPLICATE_INFORMS_RECEIVED)
+		if ack != nil {
+			// We've already acknowledged this inform, but the sender didn't get our ack.
+			receiver.sendResponse(ack)
+		}
+		return false
+	}
+	return true
+}
+
+// completeInform is called once the handler has processed an inform, and sends the acknowledgement if it was deferred.
+func (receiver *TrapReceiver) completeInform(inform *InformRequest, handled bool) {
+	key := informKeyFor(inform)
+	if !receiver.informs.isAwaitingHandler(key) {
+		return
+	}
+	if handled {
+		receiver.acknowledgeInform(key, inform)
+	} else {
+		receiver.informs.remove(key)
+	}
+}
+
+func (receiver *TrapReceiver) acknowledgeInform(key informKey, inform *InformRequest) {
+	ack := inform.createResponse()
+	ack.varbinds = inform.varbinds
+	receiver.informs.setAck(key, ack)
+	receiver.incrementStat(StatType_INFORMS_ACKNOWLEDGED)
+	receiver.sendResponse(ack)
+}
+
+//
+//
+//
+//
+// ******************************************************************
+// ------------------------ Inform duplicate detection --------------
+
+// Informs are remembered for this long after they're first received. This needs to cover the sender's full
+// retransmission period.
+const informDuplicateWindow = 5 * time.Minute
+
+type informKey struct {
+	address   string
+	requestId uint32
+}
+
+func informKeyFor(inform *InformRequest) informKey {
+	return informKey{inform.Address().String(), inform.getRequestId()}
+}
+
+type informRecord struct {
+	key         informKey
+	received    time.Time
+	ackDeferred bool               // the inform is acknowledged once the handler succeeds, rather than once it's queued
+	ack         *communityResponse // nil until the inform has been acknowledged
+}
+
+type informCache struct {
+	lock    sync.Mutex
+	window  time.Duration
+	records map[informKey]*list.Element
+	order   *list.List // oldest first
+}
+
+func newInformCache(window time.Duration) *informCache {
+	cache := new(informCache)
+	cache.window = window
+	cache.records = make(map[informKey]*list.Element)
+	cache.order = list.New()
+	return cache
+}
+
+// add records an inform as received. If it had already been received, add returns true, along with the acknowledgement
+// that was sent for it, if any.
+func (cache *informCache) add(key informKey, ackDeferred bool) (*communityResponse, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	now := time.Now()
+	cache.expire(now)
+	if e, ok := cache.records[key]; ok {
+		return e.Value.(*informRecord).ack, true
+	}
+	cache.records[key] = cache.order.PushBack(&informRecord{key: key, received: now, ackDeferred: ackDeferred})
+	return nil, false
+}
+
+func (cache *informCache) setAck(key informKey, ack *communityResponse) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if e, ok := cache.records[key]; ok {
+		e.Value.(*informRecord).ack = ack
+	}
+}
+
+// isAwaitingHandler checks whether an inform's acknowledgement has been deferred until the handler succeeds, and not yet
+// sent.
+func (cache *informCache) isAwaitingHandler(key informKey) bool {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	e, ok := cache.records[key]
+	return ok && e.Value.(*informRecord).ackDeferred && e.Value.(*informRecord).ack == nil
+}
+
+func (cache *informCache) remove(key informKey) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if e, ok := cache.records[key]; ok {
+		cache.order.Remove(e)
+		delete(cache.records, key)
+	}
+}
+
+// expire must be called with the lock held
+func (cache *informCache) expire(now time.Time) {
+	for e := cache.order.Front(); e != nil; e = cache.order.Front() {
+		record := e.Value.(*informRecord)
+		if now.Sub(record.received) < cache.window {
+			return
+		}
+		cache.order.Remove(e)
+		delete(cache.records, record.key)
+	}
+}
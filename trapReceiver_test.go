@@ -0,0 +1,220 @@
+package gosnmp_test
+
+import (
+	"errors"
+	"github.com/cihub/seelog"
+	snmp "github.com/idawes/gosnmp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+	"time"
+)
+
+// fakeTrapHandler passes on everything it receives, and fails the first numFailures calls.
+type fakeTrapHandler struct {
+	traps       chan snmp.SnmpMessage
+	numFailures int
+}
+
+func (handler *fakeTrapHandler) HandleTrap(trap snmp.SnmpMessage) error {
+	handler.traps <- trap
+	if handler.numFailures > 0 {
+		handler.numFailures--
+		return errors.New("handler failure")
+	}
+	return nil
+}
+
+func setupTrapReceiverTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("TrapReceiver", func() {
+		var (
+			clientCtxt   *snmp.ClientContext
+			receiver     *snmp.TrapReceiver
+			receiverAddr *net.UDPAddr
+			queueDepth   int
+			linkDownOid  = snmp.ObjectIdentifier{1, 3, 6, 1, 6, 3, 1, 1, 5, 3}
+		)
+		BeforeEach(func() {
+			queueDepth = 10
+		})
+		JustBeforeEach(func() {
+			testId := <-testIdGenerator
+			clientCtxt = snmp.NewClientContext(testId, 100, logger)
+			receiver = snmp.NewTrapReceiver(testId+" receiver", queueDepth, 2162, logger)
+			receiver.SetDecodeErrorLogging(true)
+			receiverAddr, _ = net.ResolveUDPAddr("udp", "localhost:2162")
+		})
+		AfterEach(func() {
+			clientCtxt.Shutdown()
+			receiver.Shutdown()
+		})
+		// encodeInform returns a v2c inform with the given request-id, sysUpTime.0 = 42 and snmpTrapOID.0 = linkDown
+		encodeInform := func(requestId byte) []byte {
+			return []byte{0x30, 0x40, 0x02, 0x01, 0x01, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+				0xa6, 0x33, 0x02, 0x01, requestId, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x28,
+				0x30, 0x0d, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00, 0x43, 0x01, 0x2a,
+				0x30, 0x17, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x06, 0x03, 0x01, 0x01, 0x04, 0x01, 0x00,
+				0x06, 0x09, 0x2b, 0x06, 0x01, 0x06, 0x03, 0x01, 0x01, 0x05, 0x03}
+		}
+
+		Describe("receiving a v1 trap", func() {
+			It("should deliver it on the traps channel", func(done Done) {
+				trap := clientCtxt.NewV1Trap(snmp.ObjectIdentifier{1, 3, 6, 1, 4, 1, 8072}, net.IPv4(127, 0, 0, 1), snmp.GenericTrapType_ENTERPRISE_SPECIFIC, 17, 1234)
+				clientCtxt.SendV1Trap(trap, "public", receiverAddr)
+				received := <-receiver.Traps()
+				Ω(received).Should(BeAssignableToTypeOf(new(snmp.V1Trap)))
+				Ω(received.(*snmp.V1Trap).SpecificTrap()).Should(Equal(int32(17)))
+				Ω(received.(*snmp.V1Trap).TimeStamp()).Should(Equal(uint32(1234)))
+				close(done)
+			}, 2)
+		})
+
+		Describe("receiving a v2 trap", func() {
+			It("should deliver it on the traps channel", func(done Done) {
+				clientCtxt.SendV2Trap(clientCtxt.NewV2Trap(5678, linkDownOid), "public", receiverAddr)
+				received := <-receiver.Traps()
+				Ω(received).Should(BeAssignableToTypeOf(new(snmp.V2Trap)))
+				Ω(received.(*snmp.V2Trap).SysUpTime()).Should(Equal(uint32(5678)))
+				Ω(received.(*snmp.V2Trap).TrapOid()).Should(Equal(linkDownOid))
+				close(done)
+			}, 2)
+		})
+
+		Describe("receiving traps with a handler set", func() {
+			It("should deliver them to the handler", func(done Done) {
+				handler := &fakeTrapHandler{traps: make(chan snmp.SnmpMessage, 10)}
+				receiver.SetHandler(handler)
+				clientCtxt.SendV2Trap(clientCtxt.NewV2Trap(1, linkDownOid), "public", receiverAddr)
+				received := <-handler.traps
+				Ω(received).Should(BeAssignableToTypeOf(new(snmp.V2Trap)))
+				close(done)
+			}, 2)
+		})
+
+		Describe("receiving more traps than the queue can hold", func() {
+			BeforeEach(func() {
+				queueDepth = 2
+			})
+			It("should drop the overflow and count it", func(done Done) {
+				for i := 0; i < 5; i++ {
+					clientCtxt.SendV2Trap(clientCtxt.NewV2Trap(uint32(i), linkDownOid), "public", receiverAddr)
+				}
+				Eventually(func() int {
+					stats, err := receiver.GetStatsBin(0)
+					Ω(err).Should(BeNil())
+					return stats.Stats[snmp.StatType_TRAPS_DROPPED_ON_QUEUE_OVERFLOW]
+				}).Should(Equal(3))
+				Ω(receiver.Traps()).Should(HaveLen(2))
+				close(done)
+			}, 3)
+		})
+
+		Describe("receiving an inform", func() {
+			It("should deliver it and acknowledge it", func(done Done) {
+				client, err := clientCtxt.NewV2cClientWithPort("public", "localhost", 2162)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 0
+				inform := clientCtxt.NewV2cInformRequest(42, linkDownOid)
+				client.SendRequest(inform)
+				Ω(inform.TransportError()).Should(BeNil())
+				Ω(inform.Response().Varbinds()).Should(HaveLen(2))
+				received := <-receiver.Traps()
+				Ω(received).Should(BeAssignableToTypeOf(new(snmp.InformRequest)))
+				Ω(received.(*snmp.InformRequest).SysUpTime()).Should(Equal(uint32(42)))
+				close(done)
+			}, 2)
+
+			It("should acknowledge retransmissions without delivering them again", func(done Done) {
+				encodedInform := encodeInform(7)
+				conn, err := net.DialUDP("udp", nil, receiverAddr)
+				Ω(err).Should(BeNil())
+				defer conn.Close()
+				ack := make([]byte, 2000)
+				for i := 0; i < 2; i++ {
+					_, err = conn.Write(encodedInform)
+					Ω(err).Should(BeNil())
+					conn.SetReadDeadline(time.Now().Add(time.Second))
+					n, err := conn.Read(ack)
+					Ω(err).Should(BeNil())
+					Ω(ack[13]).Should(Equal(byte(0xa2))) // Response PDU
+					Ω(n).Should(Equal(len(encodedInform)))
+				}
+				Ω(receiver.Traps()).Should(HaveLen(1))
+				validateStats(receiver, map[snmp.StatType]int{
+					snmp.StatType_INBOUND_MESSAGES_RECEIVED:  2,
+					snmp.StatType_INFORMS_RECEIVED:           2,
+					snmp.StatType_DUPLICATE_INFORMS_RECEIVED: 1,
+					snmp.StatType_INFORMS_ACKNOWLEDGED:       1,
+					snmp.StatType_OUTBOUND_MESSAGES_SENT:     2,
+				})
+				close(done)
+			}, 3)
+		})
+
+		Describe("receiving more informs than the queue can hold", func() {
+			BeforeEach(func() {
+				queueDepth = 1
+			})
+			It("should only acknowledge the ones it queues", func(done Done) {
+				conn, err := net.DialUDP("udp", nil, receiverAddr)
+				Ω(err).Should(BeNil())
+				defer conn.Close()
+				ack := make([]byte, 2000)
+				_, err = conn.Write(encodeInform(7))
+				Ω(err).Should(BeNil())
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				_, err = conn.Read(ack)
+				Ω(err).Should(BeNil())
+
+				// the queue is full, so the second inform is dropped and left for its sender to retransmit
+				_, err = conn.Write(encodeInform(8))
+				Ω(err).Should(BeNil())
+				conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+				_, err = conn.Read(ack)
+				Ω(err).ShouldNot(BeNil())
+				Ω(receiver.Traps()).Should(HaveLen(1))
+				<-receiver.Traps()
+
+				_, err = conn.Write(encodeInform(8))
+				Ω(err).Should(BeNil())
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				_, err = conn.Read(ack)
+				Ω(err).Should(BeNil())
+				Ω((<-receiver.Traps()).(*snmp.InformRequest).SysUpTime()).Should(Equal(uint32(42)))
+				validateStats(receiver, map[snmp.StatType]int{
+					snmp.StatType_INBOUND_MESSAGES_RECEIVED:       3,
+					snmp.StatType_INFORMS_RECEIVED:                3,
+					snmp.StatType_TRAPS_DROPPED_ON_QUEUE_OVERFLOW: 1,
+					snmp.StatType_INFORMS_ACKNOWLEDGED:            2,
+					snmp.StatType_OUTBOUND_MESSAGES_SENT:          2,
+				})
+				close(done)
+			}, 4)
+		})
+
+		Describe("receiving an inform with acknowledgement after handling", func() {
+			It("should only acknowledge it once the handler succeeds", func(done Done) {
+				handler := &fakeTrapHandler{traps: make(chan snmp.SnmpMessage, 10), numFailures: 1}
+				receiver.SetInformAckAfterHandling(true)
+				receiver.SetHandler(handler)
+				client, err := clientCtxt.NewV2cClientWithPort("public", "localhost", 2162)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 1
+				inform := clientCtxt.NewV2cInformRequest(42, linkDownOid)
+				client.SendRequest(inform)
+				Ω(inform.TransportError()).Should(BeNil())
+				Ω(handler.traps).Should(HaveLen(2))
+				validateStats(receiver, map[snmp.StatType]int{
+					snmp.StatType_INBOUND_MESSAGES_RECEIVED: 2,
+					snmp.StatType_INFORMS_RECEIVED:          2,
+					snmp.StatType_TRAP_HANDLER_ERRORS:       1,
+					snmp.StatType_INFORMS_ACKNOWLEDGED:      1,
+					snmp.StatType_OUTBOUND_MESSAGES_SENT:    1,
+				})
+				close(done)
+			}, 3)
+		})
+	})
+}
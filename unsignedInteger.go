@@ -0,0 +1,97 @@
+package gosnmp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////
+// Unsigned integer BER encode
+
+// encodeUnsignedInteger writes an unsigned integer value (Counter32, Gauge32, TimeTicks, UInteger32 or Counter64) to the
+// encoder using the given application type. It returns the number of bytes written to the encoder
+func (encoder *berEncoder) encodeUnsignedInteger(blockType snmpBlockType, val uint64) (encodedLength int) {
+	h := encoder.newHeader(blockType)
+	buf := encoder.append()
+	encodeUnsignedInt(buf, val)
+	_, encodedLength = h.setContentLength(buf.Len())
+	return
+}
+
+// encodeUnsignedInt writes the content octets for an unsigned value. The encoding is the same as for a 2's complement
+// INTEGER, so any value with the high bit of its most significant byte set gets an extra leading zero byte to keep it
+// from being read back as a negative number.
+func encodeUnsignedInt(buf *bytes.Buffer, val uint64) {
+	numBytesToWrite := calculateUnsignedIntLen(val)
+	for i := numBytesToWrite; i > 0; i-- {
+		buf.WriteByte(byte(val >> uint((i-1)*8)))
+	}
+	return
+}
+
+func calculateUnsignedIntLen(val uint64) int {
+	numBytes := 1
+	for ; val > 127; val >>= 8 {
+		numBytes++
+	}
+	return numBytes
+}
+
+////////////////////////////////////////////////////////////////////////////
+// Unsigned integer BER decode
+
+func (decoder *berDecoder) decodeUnsigned32WithHeader(expectedType snmpBlockType) (uint32, error) {
+	startingPos := decoder.pos
+	blockType, blockLength, err := decoder.decodeHeader()
+	if err != nil {
+		return 0, err
+	}
+	if blockType != expectedType {
+		return 0, fmt.Errorf("Expecting type 0x%x, found 0x%x at pos %d", expectedType, blockType, startingPos)
+	}
+	return decoder.decodeUnsigned32(blockLength)
+}
+
+// decodeUnsigned32 decodes the content octets of a 32 bit application type (Counter32, Gauge32, TimeTicks or UInteger32)
+func (decoder *berDecoder) decodeUnsigned32(numBytes int) (uint32, error) {
+	val, err := decoder.decodeUnsignedInt(numBytes, 4)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(val), nil
+}
+
+// decodeUnsigned64 decodes the content octets of a Counter64
+func (decoder *berDecoder) decodeUnsigned64(numBytes int) (uint64, error) {
+	return decoder.decodeUnsignedInt(numBytes, 8)
+}
+
+// decodeUnsignedInt decodes an unsigned value that can hold at most maxValueBytes bytes. A single extra leading zero byte is
+// permitted, since that's what's required to encode values with the high bit set. Some agents leave that byte off, so
+// values are never sign extended.
+func (decoder *berDecoder) decodeUnsignedInt(numBytes int, maxValueBytes int) (uint64, error) {
+	startingPos := decoder.pos
+	if numBytes < 1 {
+		return 0, fmt.Errorf("Invalid length %d for unsigned integer at pos %d", numBytes, startingPos)
+	}
+	if numBytes > maxValueBytes+1 {
+		return 0, fmt.Errorf("Length %d for unsigned integer exceeds maximum of %d at pos %d", numBytes, maxValueBytes+1, startingPos)
+	}
+	if numBytes > decoder.Len() {
+		return 0, fmt.Errorf("Length %d for unsigned integer exceeds available number of bytes %d at pos %d", numBytes, decoder.Len(), startingPos)
+	}
+	var val uint64
+	for i := 0; i < numBytes; i++ {
+		temp, err := decoder.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("Couldn't read byte at pos %d, err: %s", decoder.pos, err)
+		}
+		decoder.pos++
+		if i == 0 && numBytes == maxValueBytes+1 && temp != 0 {
+			return 0, fmt.Errorf("Value out of range for %d byte unsigned integer at pos %d", maxValueBytes, startingPos)
+		}
+		val <<= 8
+		val |= uint64(temp)
+	}
+	return val, nil
+}
@@ -0,0 +1,330 @@
+package gosnmp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// AuthProtocol identifies the HMAC used to authenticate SNMPv3 messages, as defined in RFC 3414 (MD5 and SHA-1) and RFC
+// 7860 (SHA-2).
+type AuthProtocol int
+
+const (
+	AuthProtocol_NONE   AuthProtocol = 0
+	AuthProtocol_MD5                 = 1 // usmHMACMD5AuthProtocol
+	AuthProtocol_SHA                 = 2 // usmHMACSHAAuthProtocol
+	AuthProtocol_SHA224              = 3 // usmHMAC128SHA224AuthProtocol
+	AuthProtocol_SHA256              = 4 // usmHMAC192SHA256AuthProtocol
+	AuthProtocol_SHA384              = 5 // usmHMAC256SHA384AuthProtocol
+	AuthProtocol_SHA512              = 6 // usmHMAC384SHA512AuthProtocol
+)
+
+func (protocol AuthProtocol) String() string {
+	switch protocol {
+	case AuthProtocol_NONE:
+		return "none"
+	case AuthProtocol_MD5:
+		return "HMAC-MD5-96"
+	case AuthProtocol_SHA:
+		return "HMAC-SHA-96"
+	case AuthProtocol_SHA224:
+		return "HMAC-128-SHA-224"
+	case AuthProtocol_SHA256:
+		return "HMAC-192-SHA-256"
+	case AuthProtocol_SHA384:
+		return "HMAC-256-SHA-384"
+	case AuthProtocol_SHA512:
+		return "HMAC-384-SHA-512"
+	default:
+		return "Unknown"
+	}
+}
+
+func (protocol AuthProtocol) newHash() hash.Hash {
+	switch protocol {
+	case AuthProtocol_MD5:
+		return md5.New()
+	case AuthProtocol_SHA:
+		return sha1.New()
+	case AuthProtocol_SHA224:
+		return sha256.New224()
+	case AuthProtocol_SHA256:
+		return sha256.New()
+	case AuthProtocol_SHA384:
+		return sha512.New384()
+	case AuthProtocol_SHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// macLength returns the number of bytes of the HMAC that are carried in msgAuthenticationParameters
+func (protocol AuthProtocol) macLength() int {
+	switch protocol {
+	case AuthProtocol_MD5, AuthProtocol_SHA:
+		return 12
+	case AuthProtocol_SHA224:
+		return 16
+	case AuthProtocol_SHA256:
+		return 24
+	case AuthProtocol_SHA384:
+		return 32
+	case AuthProtocol_SHA512:
+		return 48
+	default:
+		return 0
+	}
+}
+
+// passwordToKey turns a password into a key (Ku), as described in RFC 3414 section A.2. The password is repeated to fill
+// one megabyte, which is then hashed.
+func (protocol AuthProtocol) passwordToKey(password string) []byte {
+	h := protocol.newHash()
+	buf := make([]byte, 64)
+	pwLen := len(password)
+	for count := 0; count < 1048576; count += len(buf) {
+		for i := range buf {
+			buf[i] = password[(count+i)%pwLen]
+		}
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// localizeKey turns a key into one that is only valid for a single authoritative engine (Kul), as described in RFC 3414
+// section 2.6.
+func (protocol AuthProtocol) localizeKey(key []byte, engineId []byte) []byte {
+	h := protocol.newHash()
+	h.Write(key)
+	h.Write(engineId)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// mac calculates the truncated HMAC over a whole message, which must have its msgAuthenticationParameters zeroed out.
+func (protocol AuthProtocol) mac(localizedKey []byte, msg []byte) []byte {
+	h := hmac.New(protocol.newHash, localizedKey)
+	h.Write(msg)
+	return h.Sum(nil)[:protocol.macLength()]
+}
+
+// verifyMac checks the msgAuthenticationParameters found at authParamsPos in a received message.
+func (protocol AuthProtocol) verifyMac(localizedKey []byte, msg []byte, authParamsPos int, authParams []byte) bool {
+	if len(authParams) != protocol.macLength() {
+		return false
+	}
+	zeroedMsg := make([]byte, len(msg))
+	copy(zeroedMsg, msg)
+	for i := authParamsPos; i < authParamsPos+len(authParams); i++ {
+		zeroedMsg[i] = 0
+	}
+	return hmac.Equal(protocol.mac(localizedKey, zeroedMsg), authParams)
+}
+
+// UsmUser is an SNMPv3 user, along with the keys used to secure its messages. The keys are derived from the user's passwords
+// once, and are then localized for each authoritative engine the user talks to.
+type UsmUser struct {
+	Name         string
+	AuthProtocol AuthProtocol
+	PrivProtocol PrivProtocol
+
+	authKey           []byte
+	privKey           []byte
+	keyLock           sync.Mutex
+	localizedAuthKeys map[string][]byte
+	localizedPrivKeys map[string][]byte
+	salt              uint64
+}
+
+// NewUsmUser creates a user that authenticates its messages using the given protocol, with a key derived from authPassword.
+// Passwords must be at least 8 characters long. A user with AuthProtocol_NONE can only send noAuthNoPriv messages, and its
+// password is ignored.
+func NewUsmUser(name string, authProtocol AuthProtocol, authPassword string) (*UsmUser, error) {
+	return NewUsmUserWithPrivacy(name, authProtocol, authPassword, PrivProtocol_NONE, "")
+}
+
+// NewUsmUserWithPrivacy creates a user that authenticates its messages as NewUsmUser does, and also encrypts them using the
+// given privacy protocol, with a key derived from privPassword. Privacy requires authentication.
+func NewUsmUserWithPrivacy(name string, authProtocol AuthProtocol, authPassword string, privProtocol PrivProtocol, privPassword string) (*UsmUser, error) {
+	user := &UsmUser{Name: name, AuthProtocol: authProtocol, PrivProtocol: privProtocol,
+		localizedAuthKeys: make(map[string][]byte), localizedPrivKeys: make(map[string][]byte)}
+	if authProtocol == AuthProtocol_NONE {
+		if privProtocol != PrivProtocol_NONE {
+			return nil, fmt.Errorf("User %s can't use privacy without authentication", name)
+		}
+		return user, nil
+	}
+	if authProtocol.newHash() == nil {
+		return nil, fmt.Errorf("Unsupported auth protocol: %d", authProtocol)
+	}
+	if len(authPassword) < 8 {
+		return nil, fmt.Errorf("Auth password for user %s must be at least 8 characters long", name)
+	}
+	user.authKey = authProtocol.passwordToKey(authPassword)
+	if privProtocol == PrivProtocol_NONE {
+		return user, nil
+	}
+	if privProtocol.keyLength() == 0 {
+		return nil, fmt.Errorf("Unsupported priv protocol: %d", privProtocol)
+	}
+	if len(privPassword) < 8 {
+		return nil, fmt.Errorf("Priv password for user %s must be at least 8 characters long", name)
+	}
+	user.privKey = authProtocol.passwordToKey(privPassword)
+	// The salt only has to be unique, but starting from a random value makes reuse after a restart unlikely.
+	saltBytes := make([]byte, 8)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return nil, err
+	}
+	user.salt = binary.BigEndian.Uint64(saltBytes)
+	return user, nil
+}
+
+// localizedAuthKey returns the user's auth key localized for the given engine
+func (user *UsmUser) localizedAuthKey(engineId []byte) []byte {
+	user.keyLock.Lock()
+	defer user.keyLock.Unlock()
+	key, ok := user.localizedAuthKeys[string(engineId)]
+	if !ok {
+		key = user.AuthProtocol.localizeKey(user.authKey, engineId)
+		user.localizedAuthKeys[string(engineId)] = key
+	}
+	return key
+}
+
+// localizedPrivKey returns the user's priv key localized for the given engine
+func (user *UsmUser) localizedPrivKey(engineId []byte) []byte {
+	user.keyLock.Lock()
+	defer user.keyLock.Unlock()
+	key, ok := user.localizedPrivKeys[string(engineId)]
+	if !ok {
+		key = user.PrivProtocol.localizePrivKey(user.AuthProtocol, user.privKey, engineId)
+		user.localizedPrivKeys[string(engineId)] = key
+	}
+	return key
+}
+
+// securityLevel returns the highest security level that the user's keys support
+func (user *UsmUser) securityLevel() SecurityLevel {
+	switch {
+	case user.PrivProtocol != PrivProtocol_NONE:
+		return SecurityLevel_AUTH_PRIV
+	case user.AuthProtocol != AuthProtocol_NONE:
+		return SecurityLevel_AUTH_NO_PRIV
+	}
+	return SecurityLevel_NO_AUTH_NO_PRIV
+}
+
+func (user *UsmUser) nextSalt() uint64 {
+	user.keyLock.Lock()
+	defer user.keyLock.Unlock()
+	user.salt++
+	return user.salt
+}
+
+// usmUserTable holds the users that a context can authenticate received messages for.
+type usmUserTable struct {
+	lock  sync.RWMutex
+	users map[string]*UsmUser
+}
+
+func newUsmUserTable() *usmUserTable {
+	return &usmUserTable{users: make(map[string]*UsmUser)}
+}
+
+func (table *usmUserTable) addUser(user *UsmUser) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	table.users[user.Name] = user
+}
+
+// lookupUser returns the user with the given name, or nil if there isn't one. It's safe to call on a nil table, which has
+// no users.
+func (table *usmUserTable) lookupUser(name string) *UsmUser {
+	if table == nil {
+		return nil
+	}
+	table.lock.RLock()
+	defer table.lock.RUnlock()
+	return table.users[name]
+}
+
+// UsmErrorType identifies why a received SNMPv3 message failed USM processing. The values match the order of the usmStats
+// counters in SNMP-USER-BASED-SM-MIB.
+type UsmErrorType int
+
+const (
+	UsmErrorType_UNSUPPORTED_SEC_LEVEL UsmErrorType = 1
+	UsmErrorType_NOT_IN_TIME_WINDOW                 = 2
+	UsmErrorType_UNKNOWN_USER_NAME                  = 3
+	UsmErrorType_UNKNOWN_ENGINE_ID                  = 4
+	UsmErrorType_WRONG_DIGEST                       = 5
+	UsmErrorType_DECRYPTION_ERROR                   = 6
+)
+
+func (errorType UsmErrorType) String() string {
+	switch errorType {
+	case UsmErrorType_UNSUPPORTED_SEC_LEVEL:
+		return "unsupportedSecLevel"
+	case UsmErrorType_NOT_IN_TIME_WINDOW:
+		return "notInTimeWindow"
+	case UsmErrorType_UNKNOWN_USER_NAME:
+		return "unknownUserName"
+	case UsmErrorType_UNKNOWN_ENGINE_ID:
+		return "unknownEngineID"
+	case UsmErrorType_WRONG_DIGEST:
+		return "wrongDigest"
+	case UsmErrorType_DECRYPTION_ERROR:
+		return "decryptionError"
+	default:
+		return "Unknown"
+	}
+}
+
+func (errorType UsmErrorType) statType() StatType {
+	switch errorType {
+	case UsmErrorType_UNSUPPORTED_SEC_LEVEL:
+		return StatType_USM_UNSUPPORTED_SEC_LEVELS
+	case UsmErrorType_NOT_IN_TIME_WINDOW:
+		return StatType_USM_NOT_IN_TIME_WINDOWS
+	case UsmErrorType_UNKNOWN_USER_NAME:
+		return StatType_USM_UNKNOWN_USER_NAMES
+	case UsmErrorType_UNKNOWN_ENGINE_ID:
+		return StatType_USM_UNKNOWN_ENGINE_IDS
+	case UsmErrorType_WRONG_DIGEST:
+		return StatType_USM_WRONG_DIGESTS
+	default:
+		return StatType_USM_DECRYPTION_ERRORS
+	}
+}
+
+// oid returns the oid of the usmStats counter for the error type
+func (errorType UsmErrorType) oid() ObjectIdentifier {
+	oid := make(ObjectIdentifier, len(USM_STATS_OID), len(USM_STATS_OID)+2)
+	copy(oid, USM_STATS_OID)
+	return append(oid, uint32(errorType), 0)
+}
+
+// UsmError is returned when a received SNMPv3 message fails USM processing.
+type UsmError struct {
+	Type    UsmErrorType
+	details string
+
+	// params and requestId describe the message that failed, as far as it could be decoded, so that the failure can be
+	// reported to its sender.
+	params    *v3Params
+	requestId uint32
+}
+
+func (e UsmError) Error() string {
+	return fmt.Sprintf("USM error %s: %s", e.Type, e.details)
+}
@@ -0,0 +1,503 @@
+package gosnmp
+
+import (
+	"encoding/hex"
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+	"sync"
+	"time"
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	Ω(err).Should(BeNil())
+	return b
+}
+
+// fakeV3Agent is an authoritative engine that answers every request it can authenticate with a response holding a single
+// sysDescr.0 varbind. Requests for any other engine get a usmStatsUnknownEngineIDs report, and authenticated requests
+// outside its time window get a usmStatsNotInTimeWindows report. If downgrade is set, responses are sent without
+// authentication.
+type fakeV3Agent struct {
+	conn           *net.UDPConn
+	encoderFactory *berEncoderFactory
+	users          *usmUserTable
+	downgrade      bool
+	engineId       []byte
+
+	lock           sync.Mutex
+	engineBoots    int32
+	bootTime       time.Time
+	probesReceived int
+}
+
+func newFakeV3Agent(port int, users *usmUserTable, downgrade bool, logger Logger) *fakeV3Agent {
+	agent := &fakeV3Agent{users: users, downgrade: downgrade, encoderFactory: newberEncoderFactory(logger),
+		engineId: mustDecodeHex("80001f8880e9630000d61ff449"), engineBoots: 1, bootTime: time.Now().Add(-1000 * time.Second)}
+	var err error
+	agent.conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	Ω(err).Should(BeNil())
+	go agent.serve()
+	return agent
+}
+
+func (agent *fakeV3Agent) current() (engineBoots int32, engineTime int32) {
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+	return agent.engineBoots, int32(time.Since(agent.bootTime).Seconds())
+}
+
+// reboot starts a new engine boot, so that requests carrying the old boots fall outside the time window
+func (agent *fakeV3Agent) reboot() {
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+	agent.engineBoots++
+	agent.bootTime = time.Now()
+}
+
+func (agent *fakeV3Agent) numProbesReceived() int {
+	agent.lock.Lock()
+	defer agent.lock.Unlock()
+	return agent.probesReceived
+}
+
+func (agent *fakeV3Agent) serve() {
+	buf := make([]byte, 2000)
+	for {
+		n, addr, err := agent.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		engineBoots, engineTime := agent.current()
+		msg, err := decodeMsgWithUsm(buf[:n], agent.users, nil)
+		if usmErr, ok := err.(UsmError); ok {
+			msg, err = agent.undecodableRequest(buf[:n])
+			if err != nil {
+				continue
+			}
+			report := agent.newReport(msg.(*communityRequest), usmErr.Type, 0)
+			report.v3.engineBoots, report.v3.engineTime = engineBoots, engineTime
+			encoded, _ := report.encode(agent.encoderFactory)
+			agent.conn.WriteToUDP(encoded, addr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		req := msg.(*communityRequest)
+		var reply *communityRequestResponse
+		switch {
+		case string(req.v3.engineId) != string(agent.engineId):
+			if len(req.v3.engineId) == 0 {
+				agent.lock.Lock()
+				agent.probesReceived++
+				agent.lock.Unlock()
+			}
+			reply = agent.newReport(req, UsmErrorType_UNKNOWN_ENGINE_ID, 0)
+		case req.v3.isAuthenticated() && (req.v3.engineBoots != engineBoots || req.v3.engineTime < engineTime-150 || req.v3.engineTime > engineTime+150):
+			reply = agent.newReport(req, UsmErrorType_NOT_IN_TIME_WINDOW, v3MsgFlags_AUTH)
+		default:
+			resp := req.createResponse()
+			if agent.downgrade {
+				resp.v3.flags = 0
+			}
+			resp.AddVarbind(NewStringVarbind(SYS_DESCR_OID, "fake v3 agent"))
+			reply = &resp.communityRequestResponse
+		}
+		reply.v3.engineBoots, reply.v3.engineTime = engineBoots, engineTime
+		encoded, _ := reply.encode(agent.encoderFactory)
+		agent.conn.WriteToUDP(encoded, addr)
+	}
+}
+
+// undecodableRequest pulls the msgID out of a request that failed USM processing, so that it can be reported
+func (agent *fakeV3Agent) undecodableRequest(rawMsg []byte) (SnmpMessage, error) {
+	decoder := newberDecoder(rawMsg)
+	if err := decoder.decodeSequenceHeader(); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.decodeIntegerWithHeader(); err != nil {
+		return nil, err
+	}
+	req := newCommunityRequest()
+	req.v3 = new(v3Params)
+	if err := req.v3.decodeGlobalData(decoder); err != nil {
+		return nil, err
+	}
+	if _, err := req.v3.decodeSecurityParameters(decoder); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (agent *fakeV3Agent) newReport(req *communityRequest, errorType UsmErrorType, flags v3MsgFlags) *communityRequestResponse {
+	report := newReport(Version3, req.requestId)
+	report.v3 = &v3Params{msgId: req.v3.msgId, msgMaxSize: v3MsgMaxSize, flags: flags, engineId: agent.engineId,
+		userName: req.v3.userName, user: req.v3.user}
+	report.AddVarbind(NewCounter32Varbind(errorType.oid(), 1))
+	return &report.communityRequestResponse
+}
+
+func SetupUsmTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("USM", func() {
+		// RFC 3414 section A.3
+		engineId := mustDecodeHex("000000000000000000000002")
+
+		Describe("key localization", func() {
+			It("should match the RFC 3414 test vector for MD5", func() {
+				user, err := NewUsmUser("md5", AuthProtocol_MD5, "maplesyrup")
+				Ω(err).Should(BeNil())
+				Ω(user.authKey).Should(Equal(mustDecodeHex("9faf3283884e92834ebc9847d8edd963")))
+				Ω(user.localizedAuthKey(engineId)).Should(Equal(mustDecodeHex("526f5eed9fcce26f8964c2930787d82b")))
+			})
+			It("should match the RFC 3414 test vector for SHA", func() {
+				user, err := NewUsmUser("sha", AuthProtocol_SHA, "maplesyrup")
+				Ω(err).Should(BeNil())
+				Ω(user.authKey).Should(Equal(mustDecodeHex("9fb5cc0381497b3793528939ff788d5d79145211")))
+				Ω(user.localizedAuthKey(engineId)).Should(Equal(mustDecodeHex("6695febc9288e36282235fc7151f128497b38f3f")))
+			})
+			It("should localize SHA-2 keys the same way", func() {
+				user, err := NewUsmUser("sha256", AuthProtocol_SHA256, "maplesyrup")
+				Ω(err).Should(BeNil())
+				Ω(user.localizedAuthKey(engineId)).Should(Equal(mustDecodeHex("8982e0e549e866db361a6b625d84cccc11162d453ee8ce3a6445c2d6776f0f8b")))
+			})
+			It("should reject short passwords", func() {
+				_, err := NewUsmUser("short", AuthProtocol_SHA, "maple")
+				Ω(err).ShouldNot(BeNil())
+			})
+		})
+
+		Describe("message authentication", func() {
+			var (
+				encoderFactory *berEncoderFactory
+				users          *usmUserTable
+			)
+			BeforeEach(func() {
+				encoderFactory = newberEncoderFactory(logger)
+				users = newUsmUserTable()
+			})
+			newRequest := func(user *UsmUser) *communityRequest {
+				req := newCommunityRequest()
+				req.version = Version3
+				req.pduType = pduType_GET_REQUEST
+				req.requestId = 1234
+				req.v3 = &v3Params{msgMaxSize: v3MsgMaxSize, flags: v3MsgFlags_AUTH | v3MsgFlags_REPORTABLE, engineId: engineId,
+					engineBoots: 3, engineTime: 1000, userName: user.Name, contextEngineId: engineId, contextName: "ctx", user: user}
+				req.AddOid(SYS_DESCR_OID)
+				return req
+			}
+			for _, authProtocol := range []AuthProtocol{AuthProtocol_MD5, AuthProtocol_SHA, AuthProtocol_SHA224, AuthProtocol_SHA256, AuthProtocol_SHA384, AuthProtocol_SHA512} {
+				authProtocol := authProtocol
+				It("should round trip a request authenticated with "+authProtocol.String(), func() {
+					user, _ := NewUsmUser("user", authProtocol, "maplesyrup")
+					users.addUser(user)
+					encoded, err := newRequest(user).encode(encoderFactory)
+					Ω(err).Should(BeNil())
+					msg, err := decodeMsgWithUsm(encoded, users, nil)
+					Ω(err).Should(BeNil())
+					decoded := msg.(*communityRequest)
+					Ω(decoded.getVersion()).Should(Equal(SnmpVersion(Version3)))
+					Ω(decoded.getRequestId()).Should(Equal(uint32(1234)))
+					Ω(decoded.v3.msgId).Should(Equal(int32(1234)))
+					Ω(decoded.v3.engineId).Should(Equal(engineId))
+					Ω(decoded.v3.engineBoots).Should(Equal(int32(3)))
+					Ω(decoded.v3.engineTime).Should(Equal(int32(1000)))
+					Ω(decoded.v3.contextName).Should(Equal("ctx"))
+					Ω(decoded.v3.authParams).Should(HaveLen(authProtocol.macLength()))
+					Ω(decoded.v3.user).Should(Equal(user))
+					Ω(decoded.Varbinds()).Should(HaveLen(1))
+				})
+			}
+			It("should detect a modified message", func() {
+				user, _ := NewUsmUser("user", AuthProtocol_SHA, "maplesyrup")
+				users.addUser(user)
+				encoded, err := newRequest(user).encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				encoded[len(encoded)-1] ^= 0x01
+				_, err = decodeMsgWithUsm(encoded, users, nil)
+				Ω(err.(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_WRONG_DIGEST)))
+				Ω(err.Error()).Should(Equal("USM error wrongDigest: message from user user failed authentication"))
+			})
+			It("should detect the wrong key", func() {
+				user, _ := NewUsmUser("user", AuthProtocol_SHA, "maplesyrup")
+				otherUser, _ := NewUsmUser("user", AuthProtocol_SHA, "maplesyrop")
+				users.addUser(otherUser)
+				encoded, err := newRequest(user).encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				_, err = decodeMsgWithUsm(encoded, users, nil)
+				Ω(err.(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_WRONG_DIGEST)))
+			})
+			It("should reject unknown users", func() {
+				user, _ := NewUsmUser("user", AuthProtocol_SHA, "maplesyrup")
+				encoded, err := newRequest(user).encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				_, err = decodeMsgWithUsm(encoded, users, nil)
+				Ω(err.(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_UNKNOWN_USER_NAME)))
+			})
+			It("should accept unauthenticated messages without looking up the user", func() {
+				user, _ := NewUsmUser("", AuthProtocol_NONE, "")
+				req := newRequest(user)
+				req.v3.flags = v3MsgFlags_REPORTABLE
+				encoded, err := req.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				msg, err := decodeMsg(encoded)
+				Ω(err).Should(BeNil())
+				Ω(msg.(*communityRequest).v3.authParams).Should(BeEmpty())
+			})
+		})
+
+		Describe("privacy", func() {
+			// cross checked using openssl enc
+			plaintext := []byte("scopedPDU for openssl cross check")
+			It("should extend keys using the Blumenthal algorithm", func() {
+				user, err := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_AES256, "maplesyrup")
+				Ω(err).Should(BeNil())
+				Ω(user.localizedPrivKey(engineId)).Should(Equal(mustDecodeHex("6695febc9288e36282235fc7151f128497b38f3f505e07eb9af25568fa1f5dbe")))
+			})
+			It("should extend keys using the Reeder algorithm", func() {
+				user, err := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_AES256C, "maplesyrup")
+				Ω(err).Should(BeNil())
+				Ω(user.localizedPrivKey(engineId)).Should(Equal(mustDecodeHex("6695febc9288e36282235fc7151f128497b38f3f9b8b6d78936ba6e7d19dfd9c")))
+			})
+			It("should encrypt using AES-128 in CFB mode", func() {
+				key := mustDecodeHex("6695febc9288e36282235fc7151f1284")
+				ciphertext, privParams, err := PrivProtocol(PrivProtocol_AES).encrypt(key, 1, 100, 0x0102030405060708, plaintext)
+				Ω(err).Should(BeNil())
+				Ω(privParams).Should(Equal(mustDecodeHex("0102030405060708")))
+				Ω(ciphertext).Should(Equal(mustDecodeHex("30b5df45abe740dc15e7c114c53c3d04a088e9b916104fee077bb25e76b005ca18")))
+				decrypted, err := PrivProtocol(PrivProtocol_AES).decrypt(key, 1, 100, privParams, ciphertext)
+				Ω(err).Should(BeNil())
+				Ω(decrypted).Should(Equal(plaintext))
+			})
+			It("should encrypt using DES in CBC mode", func() {
+				key := mustDecodeHex("526f5eed9fcce26f8964c2930787d82b")
+				ciphertext, privParams, err := PrivProtocol(PrivProtocol_DES).encrypt(key, 1, 100, 2, plaintext)
+				Ω(err).Should(BeNil())
+				Ω(privParams).Should(Equal(mustDecodeHex("0000000100000002")))
+				Ω(ciphertext).Should(Equal(mustDecodeHex("7f1023b8b7aaf3c4fcea48287b93769081c0d89ac2a4da3b16863215166e68627b64f4442dcb0c02")))
+				decrypted, err := PrivProtocol(PrivProtocol_DES).decrypt(key, 1, 100, privParams, ciphertext)
+				Ω(err).Should(BeNil())
+				Ω(decrypted[:len(plaintext)]).Should(Equal(plaintext))
+			})
+			It("should refuse privacy without authentication", func() {
+				_, err := NewUsmUserWithPrivacy("user", AuthProtocol_NONE, "", PrivProtocol_AES, "maplesyrup")
+				Ω(err).ShouldNot(BeNil())
+			})
+
+			var (
+				encoderFactory *berEncoderFactory
+				users          *usmUserTable
+			)
+			BeforeEach(func() {
+				encoderFactory = newberEncoderFactory(logger)
+				users = newUsmUserTable()
+			})
+			encodeRequest := func(user *UsmUser) []byte {
+				req := newCommunityRequest()
+				req.version = Version3
+				req.pduType = pduType_GET_REQUEST
+				req.requestId = 99
+				req.v3 = &v3Params{msgMaxSize: v3MsgMaxSize, flags: v3MsgFlags_AUTH | v3MsgFlags_PRIV | v3MsgFlags_REPORTABLE, engineId: engineId,
+					engineBoots: 3, engineTime: 1000, userName: user.Name, contextEngineId: engineId, contextName: "secret context", user: user}
+				req.AddOid(SYS_DESCR_OID)
+				encoded, err := req.encode(encoderFactory)
+				Ω(err).Should(BeNil())
+				return encoded
+			}
+			for _, privProtocol := range []PrivProtocol{PrivProtocol_DES, PrivProtocol_AES, PrivProtocol_AES192, PrivProtocol_AES256, PrivProtocol_AES192C, PrivProtocol_AES256C} {
+				privProtocol := privProtocol
+				It("should round trip a request encrypted with "+privProtocol.String(), func() {
+					user, _ := NewUsmUserWithPrivacy("user", AuthProtocol_MD5, "maplesyrup", privProtocol, "privpassword")
+					users.addUser(user)
+					encoded := encodeRequest(user)
+					Ω(string(encoded)).ShouldNot(ContainSubstring("secret context"))
+					msg, err := decodeMsgWithUsm(encoded, users, nil)
+					Ω(err).Should(BeNil())
+					decoded := msg.(*communityRequest)
+					Ω(decoded.getRequestId()).Should(Equal(uint32(99)))
+					Ω(decoded.v3.contextName).Should(Equal("secret context"))
+					Ω(decoded.v3.privParams).Should(HaveLen(8))
+					Ω(decoded.Varbinds()).Should(HaveLen(1))
+				})
+			}
+			It("should use a new salt for every message", func() {
+				user, _ := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_AES, "privpassword")
+				users.addUser(user)
+				first, _ := decodeMsgWithUsm(encodeRequest(user), users, nil)
+				second, _ := decodeMsgWithUsm(encodeRequest(user), users, nil)
+				Ω(first.(*communityRequest).v3.privParams).ShouldNot(Equal(second.(*communityRequest).v3.privParams))
+			})
+			It("should reject encrypted messages for users without privacy", func() {
+				user, _ := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_AES, "privpassword")
+				authOnlyUser, _ := NewUsmUser("user", AuthProtocol_SHA, "maplesyrup")
+				users.addUser(authOnlyUser)
+				_, err := decodeMsgWithUsm(encodeRequest(user), users, nil)
+				Ω(err.(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_UNSUPPORTED_SEC_LEVEL)))
+			})
+			It("should fail to decrypt with the wrong key", func() {
+				user, _ := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_DES, "privpassword")
+				otherUser, _ := NewUsmUserWithPrivacy("user", AuthProtocol_SHA, "maplesyrup", PrivProtocol_DES, "privpassworb")
+				users.addUser(otherUser)
+				_, err := decodeMsgWithUsm(encodeRequest(user), users, nil)
+				Ω(err).ShouldNot(BeNil())
+			})
+		})
+
+		Describe("remoteEngine", func() {
+			var engine *remoteEngine
+			BeforeEach(func() {
+				engine = new(remoteEngine)
+				engine.reset(mustDecodeHex("80001f8880e9630000d61ff449"), 5, 1000)
+			})
+			It("should accept messages within the time window", func() {
+				Ω(engine.synchronize(5, 1000)).Should(BeTrue())
+				Ω(engine.synchronize(5, 851)).Should(BeTrue())
+			})
+			It("should reject messages that lag too far behind", func() {
+				Ω(engine.synchronize(5, 849)).Should(BeFalse())
+			})
+			It("should reject messages from an earlier boot", func() {
+				Ω(engine.synchronize(4, 1000)).Should(BeFalse())
+			})
+			It("should move forward to a later time or boot", func() {
+				Ω(engine.synchronize(5, 2000)).Should(BeTrue())
+				_, boots, engineTime := engine.current()
+				Ω(boots).Should(Equal(int32(5)))
+				Ω(engineTime).Should(BeNumerically(">=", 2000))
+				Ω(engine.synchronize(6, 10)).Should(BeTrue())
+				_, boots, engineTime = engine.current()
+				Ω(boots).Should(Equal(int32(6)))
+				Ω(engineTime).Should(BeNumerically("<", 100))
+			})
+		})
+
+		Describe("V3Client", func() {
+			var (
+				clientCtxt *ClientContext
+				agent      *fakeV3Agent
+				user       *UsmUser
+				downgrade  bool
+				agentUsers *usmUserTable
+			)
+			BeforeEach(func() {
+				clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+				user, _ = NewUsmUser("operator", AuthProtocol_SHA256, "correct horse")
+				downgrade = false
+				agentUsers = newUsmUserTable()
+			})
+			JustBeforeEach(func() {
+				if agentUsers.lookupUser(user.Name) == nil {
+					agentUsers.addUser(user)
+				}
+				agent = newFakeV3Agent(2164, agentUsers, downgrade, clientCtxt)
+			})
+			AfterEach(func() {
+				agent.conn.Close()
+				clientCtxt.Shutdown()
+			})
+			// stats are counted asynchronously, so they may lag slightly behind the request completing
+			stat := func(statType StatType) func() (int, error) {
+				return func() (int, error) {
+					return clientCtxt.GetStat(statType, 0)
+				}
+			}
+			newClient := func() *V3Client {
+				client, err := clientCtxt.NewV3ClientWithPort(user, "localhost", 2164)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 0
+				return client
+			}
+
+			It("should discover the engine before sending authenticated requests", func() {
+				req := clientCtxt.AllocateV3GetRequest()
+				req.AddOid(SYS_DESCR_OID)
+				newClient().SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				Ω(req.Response().Varbinds()).Should(HaveLen(1))
+				Ω(string(req.Response().Varbinds()[0].(*OctetStringVarbind).Value)).Should(Equal("fake v3 agent"))
+				Ω(req.Response().(*communityResponse).v3.engineId).Should(Equal(agent.engineId))
+				Eventually(stat(StatType_ENGINES_DISCOVERED)).Should(Equal(1))
+			})
+			It("should only discover each engine once", func() {
+				for i := 0; i < 3; i++ {
+					req := clientCtxt.AllocateV3GetRequest()
+					req.AddOid(SYS_DESCR_OID)
+					newClient().SendRequest(req)
+					Ω(req.TransportError()).Should(BeNil())
+				}
+				Ω(agent.numProbesReceived()).Should(Equal(1))
+				Eventually(stat(StatType_ENGINE_DISCOVERY_PROBES_SENT)).Should(Equal(1))
+			})
+			It("should resynchronize and resend when the engine reboots", func() {
+				client := newClient()
+				req := clientCtxt.AllocateV3GetRequest()
+				req.AddOid(SYS_DESCR_OID)
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				agent.reboot()
+				req = clientCtxt.AllocateV3GetRequest()
+				req.AddOid(SYS_DESCR_OID)
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				Ω(req.Response().(*communityResponse).v3.engineBoots).Should(Equal(int32(2)))
+				Eventually(stat(StatType_REQUESTS_RESENT_AFTER_REPORT)).Should(Equal(1))
+				Ω(agent.numProbesReceived()).Should(Equal(1))
+			})
+			It("should time out requests when the engine can't be discovered", func() {
+				client, err := clientCtxt.NewV3ClientWithPort(user, "localhost", 2165)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 0
+				req := clientCtxt.AllocateV3GetRequest()
+				req.AddOid(SYS_DESCR_OID)
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeAssignableToTypeOf(TimeoutError{}))
+				Eventually(stat(StatType_ENGINE_DISCOVERY_FAILURES)).Should(Equal(1))
+			})
+			Describe("when the agent has a different key for the user", func() {
+				BeforeEach(func() {
+					agentUser, _ := NewUsmUser("operator", AuthProtocol_SHA256, "wrong horse")
+					agentUsers.addUser(agentUser)
+				})
+				It("should fail the request with the reported error", func() {
+					req := clientCtxt.AllocateV3GetRequest()
+					req.AddOid(SYS_DESCR_OID)
+					newClient().SendRequest(req)
+					Ω(req.TransportError()).Should(BeAssignableToTypeOf(UsmError{}))
+					Ω(req.TransportError().(UsmError).Type).Should(Equal(UsmErrorType(UsmErrorType_WRONG_DIGEST)))
+					Eventually(stat(StatType_REQUESTS_FAILED_BY_REPORT)).Should(Equal(1))
+				})
+			})
+			Describe("with a user that has privacy", func() {
+				BeforeEach(func() {
+					user, _ = NewUsmUserWithPrivacy("operator", AuthProtocol_SHA, "correct horse", PrivProtocol_AES, "battery staple")
+				})
+				It("should send encrypted requests and accept encrypted responses", func() {
+					client := newClient()
+					req := clientCtxt.AllocateV3GetRequest()
+					req.AddOid(SYS_DESCR_OID)
+					client.SendRequest(req)
+					Ω(req.TransportError()).Should(BeNil())
+					Ω(req.Response().Varbinds()).Should(HaveLen(1))
+					Ω(req.Response().(*communityResponse).v3.isEncrypted()).Should(BeTrue())
+				})
+			})
+			Describe("when the agent responds without authentication", func() {
+				BeforeEach(func() {
+					downgrade = true
+				})
+				It("should drop the response", func() {
+					client := newClient()
+					req := clientCtxt.AllocateV3GetRequest()
+					req.AddOid(SYS_DESCR_OID)
+					client.SendRequest(req)
+					Ω(req.TransportError()).Should(BeAssignableToTypeOf(TimeoutError{}))
+					Ω(clientCtxt.GetStat(StatType_RESPONSES_DROPPED_ON_SECURITY_LEVEL_MISMATCH, 0)).Should(Equal(1))
+				})
+			})
+		})
+	})
+}
@@ -1,11 +1,14 @@
 package gosnmp
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type V2cClient struct {
@@ -15,8 +18,15 @@ type V2cClient struct {
 	TimeoutSeconds int
 	Retries        int
 	Community      string
+	// Timeout, if set, is used instead of TimeoutSeconds, for timeouts that aren't a whole number of seconds.
+	Timeout time.Duration
+	// RetryPolicy chooses the timeout of each attempt at sending a request. If it's nil, every attempt uses the same timeout.
+	RetryPolicy RetryPolicy
+	// Window is the number of requests sent with SendAsync that may be in flight at once.
+	Window int
 
-	mutex sync.Mutex
+	mutex  sync.Mutex
+	window requestWindow
 }
 
 // NewV2cClient creates a new v2c client, using the default snmp port (161). It is equivalent to calling NewV2cClientWithPort(community, address, 161)
@@ -28,7 +38,8 @@ func (ctxt *ClientContext) NewV2cClient(community string, address string) (*V2cC
 // It uses default TimeoutSeconds and Retries values of 10 and 2, meaning that by default, requests sent through this client will be sent
 // 3 times, with 10 seconds in between sends, for an overall timeout of 30 seconds.
 // This client is only intended to be used by a single goroutine, and as such, all calls to SendRequest() when a request is already in
-// flight will cause the the calling goroutine to be blocked until all preceding calls to SendRequest return.
+// flight will cause the the calling goroutine to be blocked until all preceding calls to SendRequest return. Requests sent with
+// SendAsync aren't serialized this way; up to Window of them, 10 by default, can be in flight at once.
 func (ctxt *ClientContext) NewV2cClientWithPort(community string, address string, port int) (*V2cClient, error) {
 	var err error
 	client := new(V2cClient)
@@ -43,22 +54,46 @@ func (ctxt *ClientContext) NewV2cClientWithPort(community string, address string
 	}
 	client.TimeoutSeconds = 10
 	client.Retries = 2
+	client.Window = 10
 	return client, nil
 }
 
 // SendRequest sends one request to the host associated with this client and waits for a response or a timeout.
-// The values currently set on this client for TimeoutSeconds and Retries will be used to control the request.
+// The values currently set on this client for Timeout, TimeoutSeconds, Retries and RetryPolicy will be used to control the
+// request, unless the request overrides them.
 // On return, the request will either have a response attached, or it's error field will be filled in.
 func (client *V2cClient) SendRequest(req CommunityRequest) {
+	client.SendRequestContext(context.Background(), req)
+}
+
+// SendRequestContext sends a request as SendRequest does, but gives up on it if ctx is cancelled or reaches its deadline
+// first. The request is then withdrawn, so that a late response is ignored and no more retries are sent, and the
+// context's error is returned and set as the request's transport error. Otherwise the request's transport error is
+// returned once it completes.
+func (client *V2cClient) SendRequestContext(ctx context.Context, req CommunityRequest) error {
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
+	client.prepareRequest(req)
+	client.snmpContext.sendRequestContext(ctx, req)
+	return req.TransportError()
+}
+
+// SendAsync sends a request without waiting for it to complete. Once the request has a response attached, or its error
+// field filled in, callback is called with it from a new goroutine. If Window requests sent with SendAsync are already in
+// flight, SendAsync blocks until one of them completes. Unlike SendRequest, SendAsync can be called from any number of
+// goroutines, as long as the client's fields aren't being changed.
+func (client *V2cClient) SendAsync(req CommunityRequest, callback func(CommunityRequest)) {
+	client.window.acquire(client.Window)
+	client.prepareRequest(req)
+	client.snmpContext.sendAsync(req, &client.window, callback)
+}
+
+func (client *V2cClient) prepareRequest(req CommunityRequest) {
 	req.setAddress(client.Address)
 	req.setCommunity(client.Community)
-	req.setTimeoutSeconds(client.TimeoutSeconds)
+	req.setTimeout(configuredTimeout(client.Timeout, client.TimeoutSeconds))
 	req.setRetriesRemaining(client.Retries)
-	client.snmpContext.sendRequest(req)
-	req.wait()
-	return
+	req.setRetryPolicy(client.RetryPolicy)
 }
 
 func (ctxt *ClientContext) AllocateV2cGetRequestWithOids(oids []ObjectIdentifier) V2cGetRequest {
@@ -79,6 +114,27 @@ func (ctxt *ClientContext) AllocateV2cGetNextRequest() V2cGetRequest {
 	return req
 }
 
+// AllocateV2cGetBulkRequest creates a GetBulk request. The first nonRepeaters oids added to the request will each be
+// retrieved with a single GetNext, and up to maxRepetitions successors will be retrieved for each of the remaining oids.
+// Negative values are treated as 0.
+func (ctxt *ClientContext) AllocateV2cGetBulkRequest(nonRepeaters int, maxRepetitions int) V2cGetBulkRequest {
+	req := ctxt.allocateV2cRequest()
+	req.pduType = pduType_GET_BULK_REQUEST
+	req.nonRepeaters = clampBulkParam(nonRepeaters)
+	req.maxRepetitions = clampBulkParam(maxRepetitions)
+	return req
+}
+
+func clampBulkParam(val int) int32 {
+	if val < 0 {
+		return 0
+	}
+	if val > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(val)
+}
+
 func (ctxt *ClientContext) AllocateV2cSetRequest() V2cSetRequest {
 	req := ctxt.allocateV2cRequest()
 	req.pduType = pduType_SET_REQUEST
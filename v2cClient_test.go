@@ -3,6 +3,7 @@
 package gosnmp_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/cihub/seelog"
 	snmp "github.com/idawes/gosnmp"
@@ -12,6 +13,7 @@ import (
 	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -280,6 +282,112 @@ func setupV2cClientTest(logger seelog.LoggerInterface, testIdGenerator chan stri
 			})
 		})
 
+		Describe("sending a request with a context", func() {
+			It("should give up when the context reaches its deadline, long before the request would time out", func(done Done) {
+				clients[0].Retries = 2
+				clients[0].TimeoutSeconds = 10
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				req := clientCtxt.AllocateV2cGetRequest()
+				start := time.Now()
+				Ω(clients[0].SendRequestContext(ctx, req)).Should(Equal(context.DeadlineExceeded))
+				Ω(time.Since(start).Seconds()).Should(BeNumerically("<", 0.5))
+				Ω(req.TransportError()).Should(Equal(context.DeadlineExceeded))
+				Eventually(func() int {
+					cancelled, _ := clientCtxt.GetStat(snmp.StatType_REQUESTS_CANCELLED, 0)
+					return cancelled
+				}).Should(Equal(1))
+				close(done)
+			}, 2)
+			It("should not send a request whose context is already done", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				req := clientCtxt.AllocateV2cGetRequest()
+				Ω(clients[0].SendRequestContext(ctx, req)).Should(Equal(context.Canceled))
+				sent, _ := clientCtxt.GetStat(snmp.StatType_REQUESTS_SENT, 0)
+				Ω(sent).Should(Equal(0))
+			})
+			It("should withdraw a v3 request that's waiting for engine discovery when its context is done", func() {
+				user, _ := snmp.NewUsmUserWithPrivacy("operator", snmp.AuthProtocol_SHA256, "correct horse", snmp.PrivProtocol_AES, "battery staple")
+				client, err := clientCtxt.NewV3ClientWithPort(user, "localhost", 2178)
+				Ω(err).Should(BeNil())
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				defer cancel()
+				req := clientCtxt.AllocateV3GetRequest()
+				req.AddOid(snmp.SYS_DESCR_OID)
+				Ω(client.SendRequestContext(ctx, req)).Should(Equal(context.DeadlineExceeded))
+				Eventually(func() int {
+					cancelled, _ := clientCtxt.GetStat(snmp.StatType_REQUESTS_CANCELLED, 0)
+					return cancelled
+				}).Should(Equal(1))
+			})
+		})
+
+		Describe("sending asynchronous requests to a non-existent agent", func() {
+			It("should keep no more than the client's window of requests in flight", func(done Done) {
+				clients[0].Retries = 0
+				clients[0].TimeoutSeconds = 1
+				clients[0].Window = 2
+				var waitGroup sync.WaitGroup
+				var timeouts int32
+				waitGroup.Add(4)
+				start := time.Now()
+				for i := 0; i < 4; i++ {
+					clients[0].SendAsync(clientCtxt.AllocateV2cGetRequest(), func(req snmp.CommunityRequest) {
+						if _, ok := req.TransportError().(snmp.TimeoutError); ok {
+							atomic.AddInt32(&timeouts, 1)
+						}
+						waitGroup.Done()
+					})
+				}
+				// the last two requests can't be sent until the first two time out
+				Ω(time.Since(start).Seconds()).Should(BeNumerically(">=", 1))
+				waitGroup.Wait()
+				Ω(time.Since(start).Seconds()).Should(BeNumerically("<", 2.5))
+				Ω(atomic.LoadInt32(&timeouts)).Should(Equal(int32(4)))
+				close(done)
+			}, 4)
+		})
+
+		Describe("sending a request with a sub-second timeout to a non-existent agent", func() {
+			It("should wait for the timeouts chosen by the retry policy, and record each attempt's flight time", func(done Done) {
+				clients[0].Retries = 2
+				clients[0].Timeout = 100 * time.Millisecond
+				clients[0].RetryPolicy = snmp.ExponentialRetryPolicy{}
+				req := clientCtxt.AllocateV2cGetRequest()
+				clients[0].SendRequest(req)
+				_, ok := req.TransportError().(snmp.TimeoutError)
+				Ω(ok).Should(BeTrue())
+				flightTimes := req.AttemptFlightTimes()
+				Ω(flightTimes).Should(HaveLen(3))
+				var total time.Duration
+				for i, flightTime := range flightTimes {
+					// a timer never fires early, but may fire late on a busy machine
+					Ω(flightTime).Should(BeNumerically(">=", 100*time.Millisecond<<uint(i)))
+					Ω(flightTime).Should(BeNumerically("<", time.Second))
+					total += flightTime
+				}
+				Ω(req.FlightTime()).Should(Equal(total))
+				close(done)
+			}, 4)
+			It("should use the timeout and retries set on the request instead of the client's", func(done Done) {
+				clients[0].Retries = 2
+				clients[0].TimeoutSeconds = 10
+				req := clientCtxt.AllocateV2cGetRequest()
+				req.SetTimeout(200 * time.Millisecond)
+				req.SetRetries(1)
+				clients[0].SendRequest(req)
+				_, ok := req.TransportError().(snmp.TimeoutError)
+				Ω(ok).Should(BeTrue())
+				Ω(req.AttemptFlightTimes()).Should(HaveLen(2))
+				for _, flightTime := range req.AttemptFlightTimes() {
+					Ω(flightTime).Should(BeNumerically(">=", 200*time.Millisecond))
+					Ω(flightTime).Should(BeNumerically("<", 2*time.Second))
+				}
+				close(done)
+			}, 5)
+		})
+
 		Describe("sending a request to an active agent", func() {
 			var (
 				agent *snmp.Agent
@@ -337,6 +445,32 @@ func setupV2cClientTest(logger seelog.LoggerInterface, testIdGenerator chan stri
 					close(done)
 				}, 2)
 			}
+			It("should complete many requests sent asynchronously from a single client", func(done Done) {
+				clients[0].Window = 5
+				var waitGroup sync.WaitGroup
+				var responses int32
+				waitGroup.Add(50)
+				for i := 0; i < 50; i++ {
+					req := clientCtxt.AllocateV2cGetRequestWithOids([]snmp.ObjectIdentifier{snmp.SYS_DESCR_OID})
+					clients[0].SendAsync(req, func(req snmp.CommunityRequest) {
+						if req.TransportError() == nil && len(req.Response().Varbinds()) == 1 {
+							atomic.AddInt32(&responses, 1)
+						}
+						waitGroup.Done()
+					})
+				}
+				waitGroup.Wait()
+				Ω(atomic.LoadInt32(&responses)).Should(Equal(int32(50)))
+				close(done)
+			}, 2)
+			It("should complete a request sent with a context that isn't done", func(done Done) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				req := clientCtxt.AllocateV2cGetRequestWithOids([]snmp.ObjectIdentifier{snmp.SYS_DESCR_OID})
+				Ω(clients[0].SendRequestContext(ctx, req)).Should(BeNil())
+				Ω(req.Response().Varbinds()).Should(HaveLen(1))
+				close(done)
+			}, 2)
 			Context("from a single client", func() {
 				Context("using 0 retries and a timeout of 1 second", func() {
 					ValidateResponse(0, 1)
@@ -0,0 +1,152 @@
+package gosnmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type V3Client struct {
+	snmpContext *ClientContext
+
+	Address        *net.UDPAddr
+	TimeoutSeconds int
+	Retries        int
+	ContextName    string
+	// Timeout, if set, is used instead of TimeoutSeconds, for timeouts that aren't a whole number of seconds.
+	Timeout time.Duration
+	// RetryPolicy chooses the timeout of each attempt at sending a request. If it's nil, every attempt uses the same timeout.
+	RetryPolicy RetryPolicy
+	// Window is the number of requests sent with SendAsync that may be in flight at once.
+	Window int
+
+	user *UsmUser
+
+	mutex  sync.Mutex
+	window requestWindow
+}
+
+// NewV3Client creates a new v3 client, using the default snmp port (161). It is equivalent to calling
+// NewV3ClientWithPort(user, address, 161)
+func (ctxt *ClientContext) NewV3Client(user *UsmUser, address string) (*V3Client, error) {
+	return ctxt.NewV3ClientWithPort(user, address, 161)
+}
+
+// NewV3ClientWithPort creates a new v3 client that sends requests as the given user, to the host address and port as
+// specified. Requests are authenticated if the user has an auth protocol, and encrypted if it also has a priv protocol.
+// The user is added to the context, so that responses sent to it can be authenticated and decrypted. Like V2cClient, it
+// uses default TimeoutSeconds, Retries and Window values of 10, 2 and 10, and SendRequest is only intended to be used by a
+// single goroutine.
+func (ctxt *ClientContext) NewV3ClientWithPort(user *UsmUser, address string, port int) (*V3Client, error) {
+	var err error
+	if user == nil {
+		return nil, errors.New("user must not be nil")
+	}
+	client := new(V3Client)
+	client.snmpContext = ctxt
+	client.user = user
+	if port < 1 || port > 65535 {
+		return nil, errors.New(fmt.Sprintf("invalid port: %d", port))
+	}
+	address += ":" + strconv.Itoa(port)
+	if client.Address, err = net.ResolveUDPAddr("udp", address); err != nil {
+		return nil, err
+	}
+	client.TimeoutSeconds = 10
+	client.Retries = 2
+	client.Window = 10
+	ctxt.usmUsers.addUser(user)
+	return client, nil
+}
+
+// SendRequest sends one request to the host associated with this client and waits for a response or a timeout.
+// The values currently set on this client for Timeout, TimeoutSeconds, Retries, RetryPolicy and ContextName will be used to
+// control the request, unless the request overrides its timeout, retries or retry policy.
+// On return, the request will either have a response attached, or it's error field will be filled in.
+// The agent's snmpEngineID, engineBoots and engineTime are discovered by the context the first time any client sends it a
+// request, and are kept up to date from then on.
+func (client *V3Client) SendRequest(req CommunityRequest) {
+	client.SendRequestContext(context.Background(), req)
+}
+
+// SendRequestContext sends a request as SendRequest does, but gives up on it if ctx is done first, as described for
+// V2cClient.SendRequestContext. A request that is waiting for the agent's engine to be discovered is withdrawn without
+// stopping the discovery.
+func (client *V3Client) SendRequestContext(ctx context.Context, req CommunityRequest) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.prepareRequest(req)
+	client.snmpContext.sendRequestContext(ctx, req)
+	return req.TransportError()
+}
+
+// SendAsync sends a request without waiting for it to complete, calling callback once it does, as described for
+// V2cClient.SendAsync. Requests waiting for the agent's engine to be discovered count towards the client's Window.
+func (client *V3Client) SendAsync(req CommunityRequest, callback func(CommunityRequest)) {
+	client.window.acquire(client.Window)
+	client.prepareRequest(req)
+	client.snmpContext.sendAsync(req, &client.window, callback)
+}
+
+func (client *V3Client) prepareRequest(req CommunityRequest) {
+	req.setVersion(Version3)
+	req.setAddress(client.Address)
+	req.setV3Params(client.newRequestParams())
+	req.setTimeout(configuredTimeout(client.Timeout, client.TimeoutSeconds))
+	req.setRetriesRemaining(client.Retries)
+	req.setRetryPolicy(client.RetryPolicy)
+}
+
+func (client *V3Client) newRequestParams() *v3Params {
+	params := new(v3Params)
+	params.msgMaxSize = v3MsgMaxSize
+	params.flags = v3MsgFlags_REPORTABLE
+	if client.user.AuthProtocol != AuthProtocol_NONE {
+		params.flags |= v3MsgFlags_AUTH
+	}
+	if client.user.PrivProtocol != PrivProtocol_NONE {
+		params.flags |= v3MsgFlags_PRIV
+	}
+	params.userName = client.user.Name
+	params.contextName = client.ContextName
+	params.user = client.user
+	return params
+}
+
+// SNMPv3 messages carry the same PDUs as v2c messages, so v3 requests are allocated and used just like v2c requests.
+
+func (ctxt *ClientContext) AllocateV3GetRequest() V2cGetRequest {
+	return ctxt.allocateV3Request(pduType_GET_REQUEST)
+}
+
+func (ctxt *ClientContext) AllocateV3GetNextRequest() V2cGetRequest {
+	return ctxt.allocateV3Request(pduType_GET_NEXT_REQUEST)
+}
+
+// AllocateV3GetBulkRequest creates a GetBulk request, with nonRepeaters and maxRepetitions treated as in
+// AllocateV2cGetBulkRequest.
+func (ctxt *ClientContext) AllocateV3GetBulkRequest(nonRepeaters int, maxRepetitions int) V2cGetBulkRequest {
+	req := ctxt.allocateV3Request(pduType_GET_BULK_REQUEST)
+	req.nonRepeaters = clampBulkParam(nonRepeaters)
+	req.maxRepetitions = clampBulkParam(maxRepetitions)
+	return req
+}
+
+func (ctxt *ClientContext) AllocateV3SetRequest() V2cSetRequest {
+	return ctxt.allocateV3Request(pduType_SET_REQUEST)
+}
+
+func (ctxt *ClientContext) allocateV3Request(pduType pduType) *communityRequest {
+	req := newCommunityRequest()
+	req.version = Version3
+	req.pduType = pduType
+	return req
+}
+
+func (ctxt *ClientContext) FreeV3Request(req CommunityRequest) {
+	ctxt.freeCommunityRequest(req)
+}
@@ -0,0 +1,382 @@
+package gosnmp
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	usmSecurityModel = 3
+	// v3MsgMaxSize is the msgMaxSize advertised in our messages. It matches the size of the buffer used by listen().
+	v3MsgMaxSize = 2000
+)
+
+type v3MsgFlags byte
+
+const (
+	v3MsgFlags_AUTH       v3MsgFlags = 0x01
+	v3MsgFlags_PRIV                  = 0x02
+	v3MsgFlags_REPORTABLE            = 0x04
+)
+
+// v3Params holds everything an SNMPv3 message carries outside of its PDU: msgGlobalData, the USM security parameters and
+// the scopedPDU's context.
+type v3Params struct {
+	msgId           int32 // if 0 when encoding, the PDU's request-id is used
+	msgMaxSize      int32
+	flags           v3MsgFlags
+	engineId        []byte // msgAuthoritativeEngineID
+	engineBoots     int32
+	engineTime      int32
+	userName        string
+	authParams      []byte
+	privParams      []byte
+	contextEngineId []byte
+	contextName     string
+
+	// user holds the keys for the message. On a received message it is the user from the table that matched userName.
+	user *UsmUser
+
+	// engine is set by the request tracker on outgoing v3 requests. Its id, boots and time are used in place of the ones
+	// above when the request is encoded, so that retries always carry the current engine time.
+	engine            *remoteEngine
+	resentAfterReport bool
+}
+
+// forResponse creates the parameters for a response to the message these parameters came from. The response is sent with
+// the same msgID, user and security level, but isn't reportable.
+func (params *v3Params) forResponse() *v3Params {
+	resp := *params
+	resp.msgMaxSize = v3MsgMaxSize
+	resp.flags &^= v3MsgFlags_REPORTABLE
+	resp.authParams = nil
+	resp.privParams = nil
+	resp.engine = nil
+	return &resp
+}
+
+func (params *v3Params) isAuthenticated() bool {
+	return params.flags&v3MsgFlags_AUTH != 0
+}
+
+func (params *v3Params) isEncrypted() bool {
+	return params.flags&v3MsgFlags_PRIV != 0
+}
+
+// encodeV3 wraps the PDU in an SNMPv3 message. If the message's security level calls for authentication, the HMAC is
+// calculated over the encoded message and then written into the space left for it in msgAuthenticationParameters.
+func (msg *communityRequestResponse) encodeV3(encoderFactory *berEncoderFactory) ([]byte, error) {
+	params := msg.v3
+	if params == nil {
+		return nil, fmt.Errorf("SNMPv3 message %s has no security parameters", msg.LoggingId())
+	}
+	engineId, engineBoots, engineTime := params.engineId, params.engineBoots, params.engineTime
+	if params.engine != nil {
+		engineId, engineBoots, engineTime = params.engine.current()
+	}
+	var authKey []byte
+	if params.isAuthenticated() {
+		if params.user == nil || params.user.AuthProtocol == AuthProtocol_NONE {
+			return nil, fmt.Errorf("SNMPv3 message %s requires authentication, but has no user auth key", msg.LoggingId())
+		}
+		authKey = params.user.localizedAuthKey(engineId)
+	}
+	privParams := params.privParams
+	var encryptedScopedPdu []byte
+	if params.isEncrypted() {
+		if authKey == nil || params.user.PrivProtocol == PrivProtocol_NONE {
+			return nil, fmt.Errorf("SNMPv3 message %s requires privacy, but has no user priv key", msg.LoggingId())
+		}
+		var err error
+		if encryptedScopedPdu, privParams, err = msg.encryptScopedPdu(encoderFactory, engineId, engineBoots, engineTime); err != nil {
+			return nil, err
+		}
+	}
+	msgId := params.msgId
+	if msgId == 0 {
+		msgId = int32(msg.requestId & math.MaxInt32)
+	}
+	encoder := encoderFactory.newberEncoder()
+	defer encoder.destroy()
+	msgHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
+	msgLen := encoder.encodeInteger(int64(msg.version))
+
+	globalDataHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
+	globalDataLen := encoder.encodeInteger(int64(msgId))
+	globalDataLen += encoder.encodeInteger(int64(params.msgMaxSize))
+	globalDataLen += encoder.encodeOctetString([]byte{byte(params.flags)})
+	globalDataLen += encoder.encodeInteger(usmSecurityModel)
+	_, blockLen := globalDataHeader.setContentLength(globalDataLen)
+	msgLen += blockLen
+
+	securityParamsHeader := encoder.newHeader(snmpBlockType_OCTET_STRING)
+	usmHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
+	usmLen := encoder.encodeOctetString(engineId)
+	usmLen += encoder.encodeInteger(int64(engineBoots))
+	usmLen += encoder.encodeInteger(int64(engineTime))
+	usmLen += encoder.encodeOctetString([]byte(params.userName))
+	authParamsHeader := encoder.newHeader(snmpBlockType_OCTET_STRING)
+	authParamsBuf := encoder.append()
+	if authKey != nil {
+		authParamsBuf.Write(make([]byte, params.user.AuthProtocol.macLength()))
+	}
+	_, blockLen = authParamsHeader.setContentLength(authParamsBuf.Len())
+	usmLen += blockLen
+	usmLen += encoder.encodeOctetString(privParams)
+	_, blockLen = usmHeader.setContentLength(usmLen)
+	_, blockLen = securityParamsHeader.setContentLength(blockLen)
+	msgLen += blockLen
+
+	if encryptedScopedPdu != nil {
+		blockLen = encoder.encodeOctetString(encryptedScopedPdu)
+	} else {
+		var err error
+		if blockLen, err = msg.encodeScopedPdu(encoder, engineId); err != nil {
+			return nil, err
+		}
+	}
+	msgLen += blockLen
+	msgHeader.setContentLength(msgLen)
+
+	if authKey == nil {
+		return encoder.serialize(), nil
+	}
+	authParamsPos := encoder.offsetOf(authParamsBuf)
+	encodedMsg := encoder.serialize()
+	copy(encodedMsg[authParamsPos:], params.user.AuthProtocol.mac(authKey, encodedMsg))
+	return encodedMsg, nil
+}
+
+// encodeScopedPdu writes the scopedPDU to the encoder. If the message has no contextEngineID, the authoritative engine's
+// id is used. It returns the number of bytes written to the encoder
+func (msg *communityRequestResponse) encodeScopedPdu(encoder *berEncoder, engineId []byte) (int, error) {
+	contextEngineId := msg.v3.contextEngineId
+	if len(contextEngineId) == 0 {
+		contextEngineId = engineId
+	}
+	scopedPduHeader := encoder.newHeader(snmpBlockType_SEQUENCE)
+	scopedPduLen := encoder.encodeOctetString(contextEngineId)
+	scopedPduLen += encoder.encodeOctetString([]byte(msg.v3.contextName))
+	pduLen, err := msg.encodePdu(encoder)
+	if err != nil {
+		return 0, err
+	}
+	_, blockLen := scopedPduHeader.setContentLength(scopedPduLen + pduLen)
+	return blockLen, nil
+}
+
+// encryptScopedPdu encodes the scopedPDU on its own, and encrypts it. It returns the encrypted scopedPDU and the
+// msgPrivacyParameters needed to decrypt it.
+func (msg *communityRequestResponse) encryptScopedPdu(encoderFactory *berEncoderFactory, engineId []byte, engineBoots int32, engineTime int32) (encryptedScopedPdu []byte, privParams []byte, err error) {
+	encoder := encoderFactory.newberEncoder()
+	defer encoder.destroy()
+	if _, err = msg.encodeScopedPdu(encoder, engineId); err != nil {
+		return nil, nil, err
+	}
+	user := msg.v3.user
+	return user.PrivProtocol.encrypt(user.localizedPrivKey(engineId), engineBoots, engineTime, user.nextSalt(), encoder.serialize())
+}
+
+// decodeV3Message decodes the rest of an SNMPv3 message, following the version. Authenticated messages are checked against
+// the keys of the matching user in the table. If we are the authoritative engine for the message (engine isn't nil), the
+// message must also be addressed to that engine, from a known user, and authenticated messages must be within its time
+// window. Messages that fail USM processing are reported with a UsmError.
+func decodeV3Message(decoder *berDecoder, rawMsg []byte, users *usmUserTable, engine *localEngine) (snmpCommunityMessage, error) {
+	params := new(v3Params)
+	if err := params.decodeGlobalData(decoder); err != nil {
+		return nil, err
+	}
+	authParamsPos, err := params.decodeSecurityParameters(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if engine != nil && string(params.engineId) != string(engine.engineId) {
+		return nil, params.usmFailure(UsmError{Type: UsmErrorType_UNKNOWN_ENGINE_ID, details: fmt.Sprintf("unknown engine id % x", params.engineId)}, decoder)
+	}
+	if err := params.authenticate(rawMsg, authParamsPos, users); err != nil {
+		return nil, params.usmFailure(err, decoder)
+	}
+	if engine != nil {
+		if params.user == nil {
+			return nil, params.usmFailure(UsmError{Type: UsmErrorType_UNKNOWN_USER_NAME, details: fmt.Sprintf("no user named %q", params.userName)}, decoder)
+		}
+		if params.isAuthenticated() && !engine.isInTimeWindow(params.engineBoots, params.engineTime) {
+			return nil, params.usmFailure(UsmError{Type: UsmErrorType_NOT_IN_TIME_WINDOW,
+				details: fmt.Sprintf("engine boots %d, time %d", params.engineBoots, params.engineTime)}, decoder)
+		}
+	}
+	if params.isEncrypted() {
+		if decoder, err = params.decrypt(decoder); err != nil {
+			return nil, params.usmFailure(err, decoder)
+		}
+	}
+	msg, err := params.decodeScopedPdu(decoder)
+	if err != nil {
+		return nil, err
+	}
+	msg.setV3Params(params)
+	return msg, nil
+}
+
+// usmFailure attaches what is known about a message that failed USM processing to the error, so that an authoritative
+// engine can report the failure. The request-id can only be recovered from messages that aren't encrypted.
+func (params *v3Params) usmFailure(err error, decoder *berDecoder) error {
+	usmErr, ok := err.(UsmError)
+	if !ok {
+		return err
+	}
+	usmErr.params = params
+	if !params.isEncrypted() {
+		if msg, err := params.decodeScopedPdu(decoder); err == nil {
+			if req, ok := msg.(*communityRequest); ok {
+				usmErr.requestId = req.requestId
+			}
+		}
+	}
+	return usmErr
+}
+
+func (params *v3Params) decodeGlobalData(decoder *berDecoder) (err error) {
+	if err = decoder.decodeSequenceHeader(); err != nil {
+		return fmt.Errorf("Unable to decode msgGlobalData header - err: %s", err)
+	}
+	if params.msgId, err = decoder.decodeInt32WithHeader(); err != nil {
+		return err
+	}
+	if params.msgMaxSize, err = decoder.decodeInt32WithHeader(); err != nil {
+		return err
+	}
+	flags, err := decoder.decodeOctetStringWithHeader()
+	if err != nil {
+		return err
+	}
+	if len(flags) != 1 {
+		return fmt.Errorf("Invalid msgFlags length: %d", len(flags))
+	}
+	params.flags = v3MsgFlags(flags[0])
+	if params.flags&(v3MsgFlags_AUTH|v3MsgFlags_PRIV) == v3MsgFlags_PRIV {
+		return fmt.Errorf("Invalid msgFlags 0x%x - privacy without authentication", params.flags)
+	}
+	securityModel, err := decoder.decodeInt32WithHeader()
+	if err != nil {
+		return err
+	}
+	if securityModel != usmSecurityModel {
+		return fmt.Errorf("Unsupported security model: %d", securityModel)
+	}
+	return nil
+}
+
+// decodeSecurityParameters decodes the UsmSecurityParameters. It returns the position of msgAuthenticationParameters in
+// the message, so that they can be zeroed out to check the message's HMAC.
+func (params *v3Params) decodeSecurityParameters(decoder *berDecoder) (authParamsPos int, err error) {
+	blockType, blockLength, err := decoder.decodeHeader()
+	if err != nil {
+		return 0, fmt.Errorf("Unable to decode msgSecurityParameters header - err: %s", err)
+	}
+	if blockType != snmpBlockType_OCTET_STRING {
+		return 0, fmt.Errorf("Invalid msgSecurityParameters type 0x%x - not 0x%x", blockType, snmpBlockType_OCTET_STRING)
+	}
+	endPos := decoder.pos + blockLength
+	if err = decoder.decodeSequenceHeader(); err != nil {
+		return 0, fmt.Errorf("Unable to decode UsmSecurityParameters header - err: %s", err)
+	}
+	if params.engineId, err = decoder.decodeOctetStringWithHeader(); err != nil {
+		return 0, err
+	}
+	if params.engineBoots, err = decoder.decodeInt32WithHeader(); err != nil {
+		return 0, err
+	}
+	if params.engineTime, err = decoder.decodeInt32WithHeader(); err != nil {
+		return 0, err
+	}
+	userName, err := decoder.decodeOctetStringWithHeader()
+	if err != nil {
+		return 0, err
+	}
+	params.userName = string(userName)
+	if params.authParams, err = decoder.decodeOctetStringWithHeader(); err != nil {
+		return 0, err
+	}
+	authParamsPos = decoder.pos - len(params.authParams)
+	if params.privParams, err = decoder.decodeOctetStringWithHeader(); err != nil {
+		return 0, err
+	}
+	if decoder.pos != endPos {
+		return 0, fmt.Errorf("Encoded msgSecurityParameters length %d doesn't match decoded length", blockLength)
+	}
+	return authParamsPos, nil
+}
+
+// authenticate finds the user the message is from, and checks the message's HMAC if it has one.
+func (params *v3Params) authenticate(rawMsg []byte, authParamsPos int, users *usmUserTable) error {
+	params.user = users.lookupUser(params.userName)
+	if !params.isAuthenticated() {
+		return nil
+	}
+	if params.user == nil {
+		return UsmError{Type: UsmErrorType_UNKNOWN_USER_NAME, details: fmt.Sprintf("no user named %q", params.userName)}
+	}
+	if params.user.AuthProtocol == AuthProtocol_NONE {
+		return UsmError{Type: UsmErrorType_UNSUPPORTED_SEC_LEVEL, details: fmt.Sprintf("user %s doesn't support authentication", params.userName)}
+	}
+	if params.isEncrypted() && params.user.PrivProtocol == PrivProtocol_NONE {
+		return UsmError{Type: UsmErrorType_UNSUPPORTED_SEC_LEVEL, details: fmt.Sprintf("user %s doesn't support privacy", params.userName)}
+	}
+	if !params.user.AuthProtocol.verifyMac(params.user.localizedAuthKey(params.engineId), rawMsg, authParamsPos, params.authParams) {
+		return UsmError{Type: UsmErrorType_WRONG_DIGEST, details: fmt.Sprintf("message from user %s failed authentication", params.userName)}
+	}
+	return nil
+}
+
+// decrypt decrypts the encryptedPDU that makes up the rest of the message. It returns a decoder for the plaintext scopedPDU.
+func (params *v3Params) decrypt(decoder *berDecoder) (*berDecoder, error) {
+	ciphertext, err := decoder.decodeOctetStringWithHeader()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode encryptedPDU - err: %s", err)
+	}
+	if decoder.Len() != 0 {
+		return nil, fmt.Errorf("Found %d bytes following encryptedPDU", decoder.Len())
+	}
+	plaintext, err := params.user.PrivProtocol.decrypt(params.user.localizedPrivKey(params.engineId), params.engineBoots, params.engineTime,
+		params.privParams, ciphertext)
+	if err != nil {
+		return nil, UsmError{Type: UsmErrorType_DECRYPTION_ERROR, details: err.Error()}
+	}
+	// Drop any padding, so that the scopedPDU takes up the whole decoder
+	plaintextDecoder := newberDecoder(plaintext)
+	if _, blockLength, err := plaintextDecoder.decodeHeader(); err != nil {
+		return nil, UsmError{Type: UsmErrorType_DECRYPTION_ERROR, details: fmt.Sprintf("decrypted scopedPDU is invalid - err: %s", err)}
+	} else {
+		plaintext = plaintext[:plaintextDecoder.pos+blockLength]
+	}
+	return newberDecoder(plaintext), nil
+}
+
+func (params *v3Params) decodeScopedPdu(decoder *berDecoder) (snmpCommunityMessage, error) {
+	if err := decoder.decodeSequenceHeader(); err != nil {
+		return nil, fmt.Errorf("Unable to decode scopedPDU header - err: %s", err)
+	}
+	var err error
+	if params.contextEngineId, err = decoder.decodeOctetStringWithHeader(); err != nil {
+		return nil, err
+	}
+	contextName, err := decoder.decodeOctetStringWithHeader()
+	if err != nil {
+		return nil, err
+	}
+	params.contextName = string(contextName)
+	return decodePdu(decoder, Version3)
+}
+
+// decodeSequenceHeader pulls a block header from the decoder, checking that it's a SEQUENCE.
+func (decoder *berDecoder) decodeSequenceHeader() error {
+	blockType, _, err := decoder.decodeHeader()
+	if err != nil {
+		return err
+	}
+	if blockType != snmpBlockType_SEQUENCE {
+		return fmt.Errorf("Invalid type 0x%x - not 0x%x", blockType, snmpBlockType_SEQUENCE)
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package gosnmp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SecurityModel identifies how the sender of a request was authenticated, as defined in RFC 3411.
+type SecurityModel int
+
+const (
+	SecurityModel_ANY SecurityModel = 0
+	SecurityModel_V1                = 1 // community based, SNMPv1
+	SecurityModel_V2C               = 2 // community based, SNMPv2c
+	SecurityModel_USM               = 3 // SNMPv3 User-based Security Model
+)
+
+func (model SecurityModel) String() string {
+	switch model {
+	case SecurityModel_ANY:
+		return "any"
+	case SecurityModel_V1:
+		return "SNMPv1"
+	case SecurityModel_V2C:
+		return "SNMPv2c"
+	case SecurityModel_USM:
+		return "USM"
+	default:
+		return "Unknown"
+	}
+}
+
+// SecurityLevel orders the ways a request can be secured. Community based requests are always noAuthNoPriv.
+type SecurityLevel int
+
+const (
+	SecurityLevel_NO_AUTH_NO_PRIV SecurityLevel = 1
+	SecurityLevel_AUTH_NO_PRIV                  = 2
+	SecurityLevel_AUTH_PRIV                     = 3
+)
+
+func (level SecurityLevel) String() string {
+	switch level {
+	case SecurityLevel_NO_AUTH_NO_PRIV:
+		return "noAuthNoPriv"
+	case SecurityLevel_AUTH_NO_PRIV:
+		return "authNoPriv"
+	case SecurityLevel_AUTH_PRIV:
+		return "authPriv"
+	default:
+		return "Unknown"
+	}
+}
+
+// ViewType selects which of a group's views is used to check access.
+type ViewType int
+
+const (
+	ViewType_READ   ViewType = 0
+	ViewType_WRITE           = 1
+	ViewType_NOTIFY          = 2
+)
+
+// VacmErrorType identifies why access was denied, as listed in RFC 3415 section 3.2.
+type VacmErrorType int
+
+const (
+	VacmErrorType_NO_SUCH_CONTEXT VacmErrorType = 1
+	VacmErrorType_NO_GROUP_NAME                 = 2
+	VacmErrorType_NO_ACCESS_ENTRY               = 3
+	VacmErrorType_NO_SUCH_VIEW                  = 4
+	VacmErrorType_NOT_IN_VIEW                   = 5
+)
+
+func (errorType VacmErrorType) String() string {
+	switch errorType {
+	case VacmErrorType_NO_SUCH_CONTEXT:
+		return "noSuchContext"
+	case VacmErrorType_NO_GROUP_NAME:
+		return "noGroupName"
+	case VacmErrorType_NO_ACCESS_ENTRY:
+		return "noAccessEntry"
+	case VacmErrorType_NO_SUCH_VIEW:
+		return "noSuchView"
+	case VacmErrorType_NOT_IN_VIEW:
+		return "notInView"
+	default:
+		return "Unknown"
+	}
+}
+
+// VacmError is returned by IsAccessAllowed when access is denied.
+type VacmError struct {
+	Type VacmErrorType
+}
+
+func (e VacmError) Error() string {
+	return fmt.Sprintf("Access denied: %s", e.Type)
+}
+
+// viewFamily is a view subtree family, as described in RFC 3415 section 5. Each bit of the mask, starting from the most
+// significant bit of the first byte, says whether the matching sub-identifier of the subtree has to match exactly (1) or
+// is a wildcard (0). Bits missing from the end of the mask are treated as 1.
+type viewFamily struct {
+	subtree  ObjectIdentifier
+	mask     []byte
+	included bool
+}
+
+func (family *viewFamily) matches(oid ObjectIdentifier) bool {
+	if len(oid) < len(family.subtree) {
+		return false
+	}
+	for i, subId := range family.subtree {
+		if i/8 < len(family.mask) && family.mask[i/8]&(0x80>>uint(i%8)) == 0 {
+			continue
+		}
+		if oid[i] != subId {
+			return false
+		}
+	}
+	return true
+}
+
+// View is a MIB view: a set of subtrees that are included in the view, with some of their parts excluded again.
+type View struct {
+	lock     sync.RWMutex
+	families []*viewFamily
+}
+
+// Include adds the subtree to the view. mask may be nil to match the subtree exactly. It returns the view, so that calls
+// can be chained.
+func (view *View) Include(subtree ObjectIdentifier, mask []byte) *View {
+	return view.addFamily(subtree, mask, true)
+}
+
+// Exclude removes the subtree from the view. mask may be nil to match the subtree exactly. It returns the view, so that
+// calls can be chained.
+func (view *View) Exclude(subtree ObjectIdentifier, mask []byte) *View {
+	return view.addFamily(subtree, mask, false)
+}
+
+func (view *View) addFamily(subtree ObjectIdentifier, mask []byte, included bool) *View {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	for _, family := range view.families {
+		if family.subtree.Equal(subtree) {
+			family.mask = mask
+			family.included = included
+			return view
+		}
+	}
+	view.families = append(view.families, &viewFamily{subtree, mask, included})
+	return view
+}
+
+// removeFamily removes the family with the given subtree, returning false if the view doesn't have one.
+func (view *View) removeFamily(subtree ObjectIdentifier) bool {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	for i, family := range view.families {
+		if family.subtree.Equal(subtree) {
+			view.families = append(view.families[:i], view.families[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// family returns a copy of the family with the given subtree, or nil if the view doesn't have one.
+func (view *View) family(subtree ObjectIdentifier) *viewFamily {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	for _, family := range view.families {
+		if family.subtree.Equal(subtree) {
+			familyCopy := *family
+			return &familyCopy
+		}
+	}
+	return nil
+}
+
+// subtrees returns the subtrees of the view's families.
+func (view *View) subtrees() []ObjectIdentifier {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	subtrees := make([]ObjectIdentifier, len(view.families))
+	for i, family := range view.families {
+		subtrees[i] = family.subtree
+	}
+	return subtrees
+}
+
+// copy returns a new view with the same families.
+func (view *View) copy() *View {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	viewCopy := &View{families: make([]*viewFamily, len(view.families))}
+	for i, family := range view.families {
+		familyCopy := *family
+		viewCopy.families[i] = &familyCopy
+	}
+	return viewCopy
+}
+
+// Contains checks whether the oid is in the view. When more than one family matches the oid, the one with the longest
+// subtree decides, with ties going to the lexicographically greater subtree.
+func (view *View) Contains(oid ObjectIdentifier) bool {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	var best *viewFamily
+	for _, family := range view.families {
+		if !family.matches(oid) {
+			continue
+		}
+		if best == nil || len(family.subtree) > len(best.subtree) ||
+			(len(family.subtree) == len(best.subtree) && family.subtree.Compare(best.subtree) > 0) {
+			best = family
+		}
+	}
+	return best != nil && best.included
+}
+
+// VacmAccess grants a group access to a set of contexts, for requests received with a given security model and at least
+// the given security level. An empty view name grants no access of that type.
+type VacmAccess struct {
+	Group             string
+	ContextPrefix     string
+	ExactContextMatch bool
+	SecurityModel     SecurityModel
+	SecurityLevel     SecurityLevel
+	ReadView          string
+	WriteView         string
+	NotifyView        string
+}
+
+func (access *VacmAccess) matches(group string, model SecurityModel, level SecurityLevel, contextName string) bool {
+	if access.Group != group || access.SecurityLevel > level {
+		return false
+	}
+	if access.SecurityModel != SecurityModel_ANY && access.SecurityModel != model {
+		return false
+	}
+	if access.ExactContextMatch {
+		return contextName == access.ContextPrefix
+	}
+	return strings.HasPrefix(contextName, access.ContextPrefix)
+}
+
+// preferredTo implements the selection rules of RFC 3415 section 4, for picking between access entries that both match
+// a request.
+func (access *VacmAccess) preferredTo(other *VacmAccess) bool {
+	if (access.SecurityModel != SecurityModel_ANY) != (other.SecurityModel != SecurityModel_ANY) {
+		return access.SecurityModel != SecurityModel_ANY
+	}
+	if access.ExactContextMatch != other.ExactContextMatch {
+		return access.ExactContextMatch
+	}
+	if len(access.ContextPrefix) != len(other.ContextPrefix) {
+		return len(access.ContextPrefix) > len(other.ContextPrefix)
+	}
+	return access.SecurityLevel > other.SecurityLevel
+}
+
+func (access *VacmAccess) viewName(viewType ViewType) string {
+	switch viewType {
+	case ViewType_READ:
+		return access.ReadView
+	case ViewType_WRITE:
+		return access.WriteView
+	default:
+		return access.NotifyView
+	}
+}
+
+type vacmSecurityKey struct {
+	model        SecurityModel
+	securityName string
+}
+
+// Vacm holds the configuration of the View-based Access Control Model described in RFC 3415. Security names are mapped to
+// groups, groups are granted access to views, and views decide which oids can be read, written or sent in notifications.
+// For community based requests, the community is the security name. The default context ("") always exists.
+type Vacm struct {
+	lock     sync.RWMutex
+	contexts map[string]bool
+	groups   map[vacmSecurityKey]string
+	access   []*VacmAccess
+	views    map[string]*View
+}
+
+func NewVacm() *Vacm {
+	return &Vacm{contexts: map[string]bool{"": true}, groups: make(map[vacmSecurityKey]string), views: make(map[string]*View)}
+}
+
+// AddContext makes a context available, so that SNMPv3 requests can be made to it.
+func (vacm *Vacm) AddContext(contextName string) {
+	vacm.lock.Lock()
+	defer vacm.lock.Unlock()
+	vacm.contexts[contextName] = true
+}
+
+// AddGroupMember puts the security name, as authenticated by the given security model, into a group.
+func (vacm *Vacm) AddGroupMember(model SecurityModel, securityName string, group string) {
+	vacm.lock.Lock()
+	defer vacm.lock.Unlock()
+	vacm.groups[vacmSecurityKey{model, securityName}] = group
+}
+
+// AddAccess grants access to a group.
+func (vacm *Vacm) AddAccess(access VacmAccess) {
+	vacm.lock.Lock()
+	defer vacm.lock.Unlock()
+	vacm.access = append(vacm.access, &access)
+}
+
+// AddView returns the view with the given name, creating an empty one if it doesn't exist yet.
+func (vacm *Vacm) AddView(viewName string) *View {
+	vacm.lock.Lock()
+	defer vacm.lock.Unlock()
+	view := vacm.views[viewName]
+	if view == nil {
+		view = new(View)
+		vacm.views[viewName] = view
+	}
+	return view
+}
+
+// IsAccessAllowed checks whether the security name, authenticated by the given model at the given level, may access the
+// oid in the context, as described in RFC 3415 section 3.2. It returns a VacmError if access is denied.
+func (vacm *Vacm) IsAccessAllowed(model SecurityModel, securityName string, level SecurityLevel, contextName string, viewType ViewType, oid ObjectIdentifier) error {
+	view, err := vacm.lookupView(model, securityName, level, contextName, viewType)
+	if err != nil {
+		return err
+	}
+	if !view.Contains(oid) {
+		return VacmError{VacmErrorType_NOT_IN_VIEW}
+	}
+	return nil
+}
+
+// lookupView finds the view that applies to a request, without checking any oid against it.
+func (vacm *Vacm) lookupView(model SecurityModel, securityName string, level SecurityLevel, contextName string, viewType ViewType) (*View, error) {
+	vacm.lock.RLock()
+	defer vacm.lock.RUnlock()
+	if !vacm.contexts[contextName] {
+		return nil, VacmError{VacmErrorType_NO_SUCH_CONTEXT}
+	}
+	group, ok := vacm.groups[vacmSecurityKey{model, securityName}]
+	if !ok {
+		return nil, VacmError{VacmErrorType_NO_GROUP_NAME}
+	}
+	var selected *VacmAccess
+	for _, access := range vacm.access {
+		if access.matches(group, model, level, contextName) && (selected == nil || access.preferredTo(selected)) {
+			selected = access
+		}
+	}
+	if selected == nil {
+		return nil, VacmError{VacmErrorType_NO_ACCESS_ENTRY}
+	}
+	view := vacm.views[selected.viewName(viewType)]
+	if view == nil {
+		return nil, VacmError{VacmErrorType_NO_SUCH_VIEW}
+	}
+	return view, nil
+}
@@ -0,0 +1,209 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var (
+	testSystemOid     = ObjectIdentifier{1, 3, 6, 1, 2, 1, 1}
+	testIfTableOid    = ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2}
+	testIfInOctetsOid = ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10}
+)
+
+func SetupVacmTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("VACM", func() {
+		Describe("a view", func() {
+			var view *View
+			BeforeEach(func() {
+				view = new(View)
+			})
+			It("should contain nothing by default", func() {
+				Ω(view.Contains(SYS_DESCR_OID)).Should(BeFalse())
+			})
+			It("should contain everything under an included subtree", func() {
+				view.Include(testSystemOid, nil)
+				Ω(view.Contains(SYS_DESCR_OID)).Should(BeTrue())
+				Ω(view.Contains(testSystemOid)).Should(BeTrue())
+				Ω(view.Contains(testSystemOid[:len(testSystemOid)-1])).Should(BeFalse())
+				Ω(view.Contains(testIfTableOid)).Should(BeFalse())
+			})
+			It("should let the most specific subtree decide", func() {
+				view.Include(ObjectIdentifier{1, 3, 6, 1, 2, 1}, nil).Exclude(testIfTableOid, nil).Include(testIfInOctetsOid, nil)
+				Ω(view.Contains(SYS_DESCR_OID)).Should(BeTrue())
+				Ω(view.Contains(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 1})).Should(BeFalse())
+				Ω(view.Contains(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1})).Should(BeTrue())
+			})
+			It("should treat masked sub-identifiers as wildcards", func() {
+				// ifEntry columns for interface 3 only: 1.3.6.1.2.1.2.2.1.*.3
+				view.Include(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 0, 3}, []byte{0xff, 0xa0})
+				Ω(view.Contains(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 3})).Should(BeTrue())
+				Ω(view.Contains(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 3})).Should(BeTrue())
+				Ω(view.Contains(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 4})).Should(BeFalse())
+			})
+		})
+
+		Describe("access decisions", func() {
+			var vacm *Vacm
+			BeforeEach(func() {
+				vacm = NewVacm()
+				vacm.AddView("system").Include(testSystemOid, nil)
+				vacm.AddView("all").Include(ObjectIdentifier{1}, nil)
+				vacm.AddContext("bridge1")
+				vacm.AddGroupMember(SecurityModel_V2C, "public", "readers")
+				vacm.AddGroupMember(SecurityModel_USM, "operator", "operators")
+				vacm.AddAccess(VacmAccess{Group: "readers", SecurityModel: SecurityModel_ANY, SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV,
+					ReadView: "system"})
+				vacm.AddAccess(VacmAccess{Group: "operators", ExactContextMatch: true, SecurityModel: SecurityModel_USM,
+					SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV, ReadView: "system"})
+				vacm.AddAccess(VacmAccess{Group: "operators", ContextPrefix: "bridge", SecurityModel: SecurityModel_USM,
+					SecurityLevel: SecurityLevel_AUTH_PRIV, ReadView: "all", WriteView: "all"})
+			})
+			check := func(model SecurityModel, securityName string, level SecurityLevel, contextName string, viewType ViewType, oid ObjectIdentifier) VacmErrorType {
+				err := vacm.IsAccessAllowed(model, securityName, level, contextName, viewType, oid)
+				if err == nil {
+					return 0
+				}
+				return err.(VacmError).Type
+			}
+			It("should allow access to oids in the view", func() {
+				Ω(check(SecurityModel_V2C, "public", SecurityLevel_NO_AUTH_NO_PRIV, "", ViewType_READ, SYS_DESCR_OID)).Should(BeZero())
+			})
+			It("should deny access to oids outside the view", func() {
+				Ω(check(SecurityModel_V2C, "public", SecurityLevel_NO_AUTH_NO_PRIV, "", ViewType_READ, testIfInOctetsOid)).Should(Equal(VacmErrorType(VacmErrorType_NOT_IN_VIEW)))
+			})
+			It("should deny access when there's no view of the requested type", func() {
+				Ω(check(SecurityModel_V2C, "public", SecurityLevel_NO_AUTH_NO_PRIV, "", ViewType_WRITE, SYS_DESCR_OID)).Should(Equal(VacmErrorType(VacmErrorType_NO_SUCH_VIEW)))
+			})
+			It("should deny access to unknown security names", func() {
+				Ω(check(SecurityModel_V2C, "private", SecurityLevel_NO_AUTH_NO_PRIV, "", ViewType_READ, SYS_DESCR_OID)).Should(Equal(VacmErrorType(VacmErrorType_NO_GROUP_NAME)))
+				Ω(check(SecurityModel_V1, "operator", SecurityLevel_NO_AUTH_NO_PRIV, "", ViewType_READ, SYS_DESCR_OID)).Should(Equal(VacmErrorType(VacmErrorType_NO_GROUP_NAME)))
+			})
+			It("should deny access to unknown contexts", func() {
+				Ω(check(SecurityModel_USM, "operator", SecurityLevel_AUTH_PRIV, "router", ViewType_READ, SYS_DESCR_OID)).Should(Equal(VacmErrorType(VacmErrorType_NO_SUCH_CONTEXT)))
+			})
+			It("should deny access when the security level is too low", func() {
+				Ω(check(SecurityModel_USM, "operator", SecurityLevel_AUTH_NO_PRIV, "bridge1", ViewType_READ, SYS_DESCR_OID)).Should(Equal(VacmErrorType(VacmErrorType_NO_ACCESS_ENTRY)))
+			})
+			It("should pick the most specific access entry", func() {
+				Ω(check(SecurityModel_USM, "operator", SecurityLevel_AUTH_PRIV, "", ViewType_READ, testIfInOctetsOid)).Should(Equal(VacmErrorType(VacmErrorType_NOT_IN_VIEW)))
+				Ω(check(SecurityModel_USM, "operator", SecurityLevel_AUTH_PRIV, "bridge1", ViewType_WRITE, testIfInOctetsOid)).Should(BeZero())
+			})
+		})
+
+		It("should map v2 errors and exceptions to v1 errors", func() {
+			req := newCommunityRequest()
+			req.version = Version1
+			req.AddOid(SYS_DESCR_OID)
+			req.AddOid(SYS_NAME_OID)
+			resp := req.createResponse()
+			resp.AddVarbind(NewStringVarbind(SYS_DESCR_OID, "system"))
+			resp.AddVarbind(NewNoSuchObjectVarbind(SYS_NAME_OID))
+			convertToV1(req, resp)
+			Ω(resp.errorVal).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_SUCH_NAME)))
+			Ω(resp.errorIdx).Should(Equal(int32(2)))
+			Ω(resp.varbinds).Should(Equal(req.varbinds))
+
+			resp = req.createResponse()
+			resp.errorVal = SnmpRequestErrorType_WRONG_TYPE
+			convertToV1(req, resp)
+			Ω(resp.errorVal).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_BAD_VALUE)))
+		})
+
+		Describe("in an agent", func() {
+			var (
+				agent      *Agent
+				clientCtxt *ClientContext
+				tempDir    string
+			)
+			BeforeEach(func() {
+				agent = NewAgentWithPort(<-testIdGenerator, 10, 2167, logger, new(testTxnProvider))
+				agent.RegisterSingleVarOidHandler(SYS_DESCR_OID, &testStringHandler{val: "vacm agent"})
+				agent.RegisterSingleVarOidHandler(SYS_NAME_OID, &testStringHandler{val: "name", writable: true})
+				agent.RegisterSingleVarOidHandler(SYS_CONTACT_OID, &testStringHandler{val: "contact", writable: true})
+				agent.RegisterSingleVarOidHandler(testIfInOctetsOid, &testStringHandler{val: "octets"})
+				vacm := NewVacm()
+				vacm.AddView("system").Include(testSystemOid, nil)
+				vacm.AddView("name").Include(SYS_NAME_OID, nil)
+				vacm.AddGroupMember(SecurityModel_V2C, "public", "readers")
+				vacm.AddGroupMember(SecurityModel_V2C, "private", "writers")
+				vacm.AddGroupMember(SecurityModel_USM, "operator", "writers")
+				vacm.AddAccess(VacmAccess{Group: "readers", SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV, ReadView: "system"})
+				vacm.AddAccess(VacmAccess{Group: "writers", SecurityLevel: SecurityLevel_NO_AUTH_NO_PRIV, ReadView: "system", WriteView: "name"})
+				agent.SetVacm(vacm)
+
+				var err error
+				tempDir, err = ioutil.TempDir("", "gosnmp")
+				Ω(err).Should(BeNil())
+				Ω(agent.EnableV3(mustDecodeHex("80001f8880e9630000d61ff449"), &FileEngineBootsStore{filepath.Join(tempDir, "engineBoots")})).Should(BeNil())
+				clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			})
+			AfterEach(func() {
+				clientCtxt.Shutdown()
+				agent.Shutdown()
+				os.RemoveAll(tempDir)
+			})
+			newClient := func(community string) *V2cClient {
+				client, err := clientCtxt.NewV2cClientWithPort(community, "localhost", 2167)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 0
+				return client
+			}
+			set := func(client *V2cClient, oid ObjectIdentifier, val string) SnmpResponse {
+				req := clientCtxt.AllocateV2cSetRequest()
+				req.AddVarbind(NewStringVarbind(oid, val))
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				return req.Response()
+			}
+
+			It("should hide oids outside the read view", func() {
+				req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{SYS_DESCR_OID, testIfInOctetsOid})
+				newClient("public").SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				varbinds := req.Response().Varbinds()
+				Ω(string(varbinds[0].(*OctetStringVarbind).Value)).Should(Equal("vacm agent"))
+				Ω(varbinds[1]).Should(BeAssignableToTypeOf(new(NoSuchObjectVarbind)))
+			})
+			It("should refuse requests from communities without a group", func() {
+				req := clientCtxt.AllocateV2cGetRequestWithOids([]ObjectIdentifier{SYS_DESCR_OID})
+				newClient("nobody").SendRequest(req)
+				Ω(req.TransportError()).Should(BeNil())
+				Ω(req.Response().ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_AUTHORIZATION_ERROR)))
+			})
+			It("should refuse writes from groups without a write view", func() {
+				resp := set(newClient("public"), SYS_NAME_OID, "new name")
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_AUTHORIZATION_ERROR)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(1)))
+			})
+			It("should refuse writes outside the write view", func() {
+				resp := set(newClient("private"), SYS_CONTACT_OID, "new contact")
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ACCESS)))
+				Ω(resp.ErrorIdx()).Should(Equal(int32(1)))
+				Ω(string(resp.Varbinds()[0].(*OctetStringVarbind).Value)).Should(Equal("new contact"))
+			})
+			It("should allow writes inside the write view", func() {
+				resp := set(newClient("private"), SYS_NAME_OID, "new name")
+				Ω(resp.ErrorVal()).Should(Equal(SnmpRequestErrorType(SnmpRequestErrorType_NO_ERROR)))
+			})
+			It("should report SNMPv3 requests for unknown contexts", func() {
+				user, _ := NewUsmUser("operator", AuthProtocol_SHA, "maplesyrup")
+				agent.AddUsmUser(user)
+				client, err := clientCtxt.NewV3ClientWithPort(user, "localhost", 2167)
+				Ω(err).Should(BeNil())
+				client.TimeoutSeconds = 1
+				client.Retries = 0
+				client.ContextName = "router"
+				req := clientCtxt.AllocateV3GetRequest()
+				req.AddOid(SYS_DESCR_OID)
+				client.SendRequest(req)
+				Ω(req.TransportError()).Should(Equal(ReportError{SNMP_UNKNOWN_CONTEXTS_OID}))
+			})
+		})
+	})
+}
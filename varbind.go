@@ -109,43 +109,47 @@ type Counter32Varbind struct { // type 0x41
 	Value uint32
 }
 
-func NewCounter32Varbind(oid ObjectIdentifier) *Counter32Varbind {
+func NewCounter32Varbind(oid ObjectIdentifier, val uint32) *Counter32Varbind {
 	vb := new(Counter32Varbind)
 	vb.oid = oid
+	vb.Value = val
 	return vb
 }
 
+func (vb *Counter32Varbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeUnsignedInteger(snmpBlockType_COUNTER_32, uint64(vb.Value)), nil
+}
+
 type Gauge32Varbind struct { // type 0x42
 	baseVarbind
 	Value uint32
 }
 
-func NewGauge32Varbind(oid ObjectIdentifier) *Gauge32Varbind {
+func NewGauge32Varbind(oid ObjectIdentifier, val uint32) *Gauge32Varbind {
 	vb := new(Gauge32Varbind)
 	vb.oid = oid
+	vb.Value = val
 	return vb
 }
 
+func (vb *Gauge32Varbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeUnsignedInteger(snmpBlockType_GAUGE_32, uint64(vb.Value)), nil
+}
+
 type TimeTicksVarbind struct { // type 0x43
 	baseVarbind
 	Value uint32
 }
 
-func NewTimeTicksVarbind(oid ObjectIdentifier) *TimeTicksVarbind {
+func NewTimeTicksVarbind(oid ObjectIdentifier, val uint32) *TimeTicksVarbind {
 	vb := new(TimeTicksVarbind)
 	vb.oid = oid
+	vb.Value = val
 	return vb
 }
 
-type OpaqueVarbind struct { // type 0x44
-	baseVarbind
-	Value []byte
-}
-
-func NewOpaqueVarbind(oid ObjectIdentifier) *OpaqueVarbind {
-	vb := new(OpaqueVarbind)
-	vb.oid = oid
-	return vb
+func (vb *TimeTicksVarbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeUnsignedInteger(snmpBlockType_TIME_TICKS, uint64(vb.Value)), nil
 }
 
 type NsapAddressVarbind struct { // type 0x45
@@ -164,23 +168,33 @@ type Counter64Varbind struct { // type 0x46
 	Value uint64
 }
 
-func NewCounter64Varbind(oid ObjectIdentifier) *Counter64Varbind {
+func NewCounter64Varbind(oid ObjectIdentifier, val uint64) *Counter64Varbind {
 	vb := new(Counter64Varbind)
 	vb.oid = oid
+	vb.Value = val
 	return vb
 }
 
+func (vb *Counter64Varbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeUnsignedInteger(snmpBlockType_COUNTER_64, vb.Value), nil
+}
+
 type Uint32Varbind struct { // type 0x47
 	baseVarbind
-	val uint32
+	Value uint32
 }
 
-func NewUint32Varbind(oid ObjectIdentifier) *Uint32Varbind {
+func NewUint32Varbind(oid ObjectIdentifier, val uint32) *Uint32Varbind {
 	vb := new(Uint32Varbind)
 	vb.oid = oid
+	vb.Value = val
 	return vb
 }
 
+func (vb *Uint32Varbind) encodeValue(encoder *berEncoder) (int, error) {
+	return encoder.encodeUnsignedInteger(snmpBlockType_UINT_32, uint64(vb.Value)), nil
+}
+
 type NoSuchObjectVarbind struct { // type 0x80
 	baseVarbind
 }
@@ -253,18 +267,18 @@ func decodeVarbind(decoder *berDecoder) (varbind Varbind, err error) {
 		varbind = NewObjectIdentifierVarbind(oid, value.(ObjectIdentifier))
 	case snmpBlockType_IP_ADDRESS:
 		varbind = NewIPv4AddressVarbind(oid, value.(net.IP))
-	// case snmpBlockType_COUNTER_32:
-	// 	varbind = NewCounter32Varbind(oid)
-	// case snmpBlockType_GAUGE_32:
-	// 	varbind = NewGauge32Varbind(oid)
-	// case TIME_TICKS:
-	// 	varbind = NewTimeTicksVarbind(oid)
-	// case OPAQUE:
-	// 	varbind = NewOpaqueVarbind(oid)
-	// case COUNTER_64:
-	// 	varbind = NewCounter64Varbind(oid)
-	// case UINT_32:
-	// 	varbind = NewUint32Varbind(oid)
+	case snmpBlockType_COUNTER_32:
+		varbind = NewCounter32Varbind(oid, value.(uint32))
+	case snmpBlockType_GAUGE_32:
+		varbind = NewGauge32Varbind(oid, value.(uint32))
+	case snmpBlockType_TIME_TICKS:
+		varbind = NewTimeTicksVarbind(oid, value.(uint32))
+	case snmpBlockType_OPAQUE:
+		varbind = NewOpaqueVarbind(oid, value.([]byte))
+	case snmpBlockType_COUNTER_64:
+		varbind = NewCounter64Varbind(oid, value.(uint64))
+	case snmpBlockType_UINT_32:
+		varbind = NewUint32Varbind(oid, value.(uint32))
 	case snmpBlockType_NO_SUCH_OBJECT:
 		varbind = NewNoSuchObjectVarbind(oid)
 	case snmpBlockType_NO_SUCH_INSTANCE:
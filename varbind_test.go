@@ -0,0 +1,142 @@
+package gosnmp
+
+import (
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"math"
+)
+
+func SetupVarbindCodecTest(logger seelog.LoggerInterface) {
+	Describe("Varbind codec", func() {
+		var (
+			encoderFactory *berEncoderFactory
+			oid            ObjectIdentifier
+		)
+		BeforeEach(func() {
+			encoderFactory = newberEncoderFactory(logger)
+			oid = ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}
+		})
+		encode := func(vb Varbind) []byte {
+			encoder := encoderFactory.newberEncoder()
+			defer encoder.destroy()
+			_, err := encoder.encodeVarbind(vb)
+			Ω(err).Should(BeNil())
+			return encoder.serialize()
+		}
+		roundTrip := func(vb Varbind) Varbind {
+			decoded, err := decodeVarbind(newberDecoder(encode(vb)))
+			Ω(err).Should(BeNil())
+			Ω(decoded.GetOid()).Should(Equal(oid))
+			return decoded
+		}
+		// encodedValue strips the varbind sequence header and oid, leaving just the encoded value TLV. The test oid is short
+		// enough that both headers use the single byte length form.
+		encodedValue := func(vb Varbind) []byte {
+			encoded := encode(vb)
+			return encoded[2+2+int(encoded[3]):]
+		}
+
+		Describe("for unsigned application types", func() {
+			ValidateUint32 := func(val uint32, expectedEncoding []byte) {
+				It("should round trip Counter32", func() {
+					vb := roundTrip(NewCounter32Varbind(oid, val))
+					Ω(vb).Should(BeAssignableToTypeOf(new(Counter32Varbind)))
+					Ω(vb.(*Counter32Varbind).Value).Should(Equal(val))
+					Ω(encodedValue(NewCounter32Varbind(oid, val))).Should(Equal(append([]byte{snmpBlockType_COUNTER_32}, expectedEncoding...)))
+				})
+				It("should round trip Gauge32", func() {
+					vb := roundTrip(NewGauge32Varbind(oid, val))
+					Ω(vb).Should(BeAssignableToTypeOf(new(Gauge32Varbind)))
+					Ω(vb.(*Gauge32Varbind).Value).Should(Equal(val))
+				})
+				It("should round trip TimeTicks", func() {
+					vb := roundTrip(NewTimeTicksVarbind(oid, val))
+					Ω(vb).Should(BeAssignableToTypeOf(new(TimeTicksVarbind)))
+					Ω(vb.(*TimeTicksVarbind).Value).Should(Equal(val))
+				})
+				It("should round trip Uint32", func() {
+					vb := roundTrip(NewUint32Varbind(oid, val))
+					Ω(vb).Should(BeAssignableToTypeOf(new(Uint32Varbind)))
+					Ω(vb.(*Uint32Varbind).Value).Should(Equal(val))
+				})
+			}
+			Context("with a value of 0", func() {
+				ValidateUint32(0, []byte{0x01, 0x00})
+			})
+			Context("with a value of 127", func() {
+				ValidateUint32(127, []byte{0x01, 0x7f})
+			})
+			Context("with a value of 128", func() {
+				ValidateUint32(128, []byte{0x02, 0x00, 0x80})
+			})
+			Context("with the maximum value", func() {
+				ValidateUint32(math.MaxUint32, []byte{0x05, 0x00, 0xff, 0xff, 0xff, 0xff})
+			})
+
+			ValidateCounter64 := func(val uint64, expectedEncoding []byte) {
+				It("should round trip Counter64", func() {
+					vb := roundTrip(NewCounter64Varbind(oid, val))
+					Ω(vb).Should(BeAssignableToTypeOf(new(Counter64Varbind)))
+					Ω(vb.(*Counter64Varbind).Value).Should(Equal(val))
+					Ω(encodedValue(NewCounter64Varbind(oid, val))).Should(Equal(append([]byte{snmpBlockType_COUNTER_64}, expectedEncoding...)))
+				})
+			}
+			Context("with a 32 bit Counter64 value", func() {
+				ValidateCounter64(math.MaxUint32, []byte{0x05, 0x00, 0xff, 0xff, 0xff, 0xff})
+			})
+			Context("with the maximum Counter64 value", func() {
+				ValidateCounter64(math.MaxUint64, []byte{0x09, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+			})
+
+			Context("decoding a value with the high bit set and no leading zero", func() {
+				It("should not sign extend the value", func() {
+					decoder := newberDecoder([]byte{0x30, 0x10, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x0a, 0x01, 0x41, 0x02, 0xff, 0xfe})
+					vb, err := decodeVarbind(decoder)
+					Ω(err).Should(BeNil())
+					Ω(vb.(*Counter32Varbind).Value).Should(Equal(uint32(0xfffe)))
+				})
+			})
+			Context("decoding a Counter32 value that's too large", func() {
+				It("should fail", func() {
+					decoder := newberDecoder([]byte{0x30, 0x13, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x0a, 0x01, 0x41, 0x05, 0x01, 0x00, 0x00, 0x00, 0x00})
+					_, err := decodeVarbind(decoder)
+					Ω(err).ShouldNot(BeNil())
+				})
+			})
+		})
+
+		Describe("for opaque values", func() {
+			ValidateWrappedValue := func(vb *OpaqueVarbind, expectedEncoding []byte) {
+				It("should use the net-snmp encoding", func() {
+					Ω(encodedValue(vb)).Should(Equal(append([]byte{snmpBlockType_OPAQUE}, expectedEncoding...)))
+				})
+				It("should round trip the wrapped value", func() {
+					decoded := roundTrip(vb)
+					Ω(decoded).Should(BeAssignableToTypeOf(new(OpaqueVarbind)))
+					Ω(decoded.(*OpaqueVarbind).Value).Should(Equal(vb.Value))
+					Ω(decoded.(*OpaqueVarbind).WrappedValue).Should(Equal(vb.WrappedValue))
+				})
+			}
+			Context("wrapping a float", func() {
+				ValidateWrappedValue(NewOpaqueFloatVarbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}, 1.5), []byte{0x07, 0x9f, 0x78, 0x04, 0x3f, 0xc0, 0x00, 0x00})
+			})
+			Context("wrapping a double", func() {
+				ValidateWrappedValue(NewOpaqueDoubleVarbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}, -2.25), []byte{0x0b, 0x9f, 0x79, 0x08, 0xc0, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+			})
+			Context("wrapping a negative int64", func() {
+				ValidateWrappedValue(NewOpaqueInt64Varbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}, -129), []byte{0x05, 0x9f, 0x7a, 0x02, 0xff, 0x7f})
+			})
+			Context("wrapping the maximum uint64", func() {
+				ValidateWrappedValue(NewOpaqueUint64Varbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 10, 1}, math.MaxUint64), []byte{0x0c, 0x9f, 0x7b, 0x09, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+			})
+			Context("holding arbitrary data", func() {
+				It("should round trip the raw value with no wrapped value", func() {
+					decoded := roundTrip(NewOpaqueVarbind(oid, []byte{0x01, 0x02, 0x03}))
+					Ω(decoded.(*OpaqueVarbind).Value).Should(Equal([]byte{0x01, 0x02, 0x03}))
+					Ω(decoded.(*OpaqueVarbind).WrappedValue).Should(BeNil())
+				})
+			})
+		})
+	})
+}
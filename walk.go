@@ -0,0 +1,170 @@
+package gosnmp
+
+import (
+	"fmt"
+)
+
+// RequestError is returned by the walk and table helpers when the agent responds with a non-zero error-status.
+type RequestError struct {
+	ErrorVal SnmpRequestErrorType
+	ErrorIdx int32
+}
+
+func (e RequestError) Error() string {
+	return fmt.Sprintf("Request failed with error-status %d, error-index %d", e.ErrorVal, e.ErrorIdx)
+}
+
+// OidNotIncreasingError is returned when an agent answers a GetNext or GetBulk with an oid that doesn't come after the oid
+// that was asked for. Walking any further would loop forever.
+type OidNotIncreasingError struct {
+	Requested ObjectIdentifier
+	Returned  ObjectIdentifier
+}
+
+func (e OidNotIncreasingError) Error() string {
+	return fmt.Sprintf("Agent returned oid %v, which doesn't follow requested oid %v", e.Returned, e.Requested)
+}
+
+// WalkFunc is called for each varbind found by Walk or BulkWalk. If it returns an error, the walk stops and that error is
+// returned from the walk.
+type WalkFunc func(vb Varbind) error
+
+// Walk retrieves every varbind in the subtree under root, using GetNext requests, and calls fn for each of them in order.
+func (client *V2cClient) Walk(root ObjectIdentifier, fn WalkFunc) error {
+	return walk(client.NewWalker(root), fn)
+}
+
+// BulkWalk retrieves every varbind in the subtree under root, using GetBulk requests asking for maxRepetitions varbinds at a
+// time, and calls fn for each of them in order.
+func (client *V2cClient) BulkWalk(root ObjectIdentifier, maxRepetitions int, fn WalkFunc) error {
+	return walk(client.NewBulkWalker(root, maxRepetitions), fn)
+}
+
+func walk(walker *Walker, fn WalkFunc) error {
+	for walker.Next() {
+		if err := fn(walker.Varbind()); err != nil {
+			return err
+		}
+	}
+	return walker.Err()
+}
+
+// Walker is an iterator over the varbinds in a subtree. It is used like this:
+//
+//	walker := client.NewBulkWalker(root, 20)
+//	for walker.Next() {
+//		vb := walker.Varbind()
+//		...
+//	}
+//	if err := walker.Err(); err != nil {
+//		...
+//	}
+//
+// The walk ends at the end of the subtree, when the agent reports endOfMibView, or when an error occurs. Like the client
+// it belongs to, a Walker is only intended to be used by a single goroutine.
+type Walker struct {
+	client         *V2cClient
+	root           ObjectIdentifier
+	maxRepetitions int // 0 for GetNext
+	lastOid        ObjectIdentifier
+	pending        []Varbind
+	current        Varbind
+	done           bool
+	err            error
+}
+
+// NewWalker creates an iterator over the subtree under root that uses GetNext requests.
+func (client *V2cClient) NewWalker(root ObjectIdentifier) *Walker {
+	return &Walker{client: client, root: root, lastOid: root}
+}
+
+// NewBulkWalker creates an iterator over the subtree under root that uses GetBulk requests asking for maxRepetitions
+// varbinds at a time.
+func (client *V2cClient) NewBulkWalker(root ObjectIdentifier, maxRepetitions int) *Walker {
+	if maxRepetitions < 1 {
+		maxRepetitions = 1
+	}
+	return &Walker{client: client, root: root, lastOid: root, maxRepetitions: maxRepetitions}
+}
+
+// Next advances to the next varbind in the subtree, which is then available from Varbind(). It returns false when the walk
+// is over, either because the end of the subtree was reached, or because of an error, which will be available from Err().
+func (w *Walker) Next() bool {
+	for len(w.pending) == 0 {
+		if w.done {
+			w.current = nil
+			return false
+		}
+		w.fetch()
+	}
+	w.current = w.pending[0]
+	w.pending = w.pending[1:]
+	return true
+}
+
+// Varbind returns the varbind the walker is currently positioned on.
+func (w *Walker) Varbind() Varbind {
+	return w.current
+}
+
+// Err returns the error that ended the walk, if any. Reaching the end of the subtree is not an error.
+func (w *Walker) Err() error {
+	return w.err
+}
+
+// fetch sends one request for the varbinds following lastOid, and queues any that are still inside the subtree
+func (w *Walker) fetch() {
+	ctxt := w.client.snmpContext
+	var req V2cGetRequest
+	if w.maxRepetitions > 0 {
+		req = ctxt.AllocateV2cGetBulkRequest(0, w.maxRepetitions)
+	} else {
+		req = ctxt.AllocateV2cGetNextRequest()
+	}
+	defer ctxt.FreeV2cRequest(req)
+	req.AddOid(w.lastOid)
+	w.client.SendRequest(req)
+	if err := req.TransportError(); err != nil {
+		w.finish(err)
+		return
+	}
+	resp := req.Response()
+	if resp.ErrorVal() == SnmpRequestErrorType_NO_SUCH_NAME {
+		// v1 style end of mib
+		w.finish(nil)
+		return
+	}
+	if resp.ErrorVal() != SnmpRequestErrorType_NO_ERROR {
+		w.finish(RequestError{resp.ErrorVal(), resp.ErrorIdx()})
+		return
+	}
+	varbinds := resp.Varbinds()
+	if len(varbinds) == 0 {
+		w.finish(nil)
+		return
+	}
+	for _, vb := range varbinds {
+		switch vb.(type) {
+		case *EndOfMibViewVarbind, *NoSuchObjectVarbind, *NoSuchInstanceVarbind:
+			w.finish(nil)
+			return
+		}
+		oid := vb.GetOid()
+		if w.root.MatchLength(oid) != len(w.root) {
+			// we've walked off the end of the subtree
+			w.finish(nil)
+			return
+		}
+		if oid.Compare(w.lastOid) <= 0 {
+			w.finish(OidNotIncreasingError{w.lastOid, oid})
+			return
+		}
+		w.pending = append(w.pending, vb)
+		w.lastOid = oid
+	}
+}
+
+func (w *Walker) finish(err error) {
+	w.done = true
+	w.err = err
+}
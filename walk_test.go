@@ -0,0 +1,159 @@
+package gosnmp
+
+import (
+	"errors"
+	"github.com/cihub/seelog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net"
+)
+
+// fakeWalkAgent answers GetNext and GetBulk requests from a sorted list of varbinds. If loopAfter is set, any request for
+// that oid is answered with the first varbind in the list, simulating a broken agent.
+type fakeWalkAgent struct {
+	conn           *net.UDPConn
+	encoderFactory *berEncoderFactory
+	varbinds       []Varbind
+	loopAfter      ObjectIdentifier
+}
+
+func newFakeWalkAgent(port int, varbinds []Varbind, loopAfter ObjectIdentifier, logger Logger) *fakeWalkAgent {
+	agent := &fakeWalkAgent{varbinds: varbinds, loopAfter: loopAfter, encoderFactory: newberEncoderFactory(logger)}
+	var err error
+	agent.conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	Ω(err).Should(BeNil())
+	go agent.serve()
+	return agent
+}
+
+func (agent *fakeWalkAgent) next(oid ObjectIdentifier) Varbind {
+	if agent.loopAfter != nil && oid.Equal(agent.loopAfter) {
+		return agent.varbinds[0]
+	}
+	for _, vb := range agent.varbinds {
+		if vb.GetOid().Compare(oid) > 0 {
+			return vb
+		}
+	}
+	return NewEndOfMibViewVarbind(oid)
+}
+
+func (agent *fakeWalkAgent) serve() {
+	buf := make([]byte, 2000)
+	for {
+		n, addr, err := agent.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		msg, err := decodeMsg(buf[:n])
+		if err != nil {
+			continue
+		}
+		req := msg.(*communityRequest)
+		resp := req.createResponse()
+		repetitions := 1
+		if req.pduType == pduType_GET_BULK_REQUEST {
+			repetitions = int(req.maxRepetitions)
+		}
+		oids := make([]ObjectIdentifier, len(req.varbinds))
+		for i, vb := range req.varbinds {
+			oids[i] = vb.GetOid()
+		}
+		for i := 0; i < repetitions; i++ {
+			for j, oid := range oids {
+				nextVb := agent.next(oid)
+				resp.AddVarbind(nextVb)
+				oids[j] = nextVb.GetOid()
+			}
+		}
+		encoded, _ := resp.encode(agent.encoderFactory)
+		agent.conn.WriteToUDP(encoded, addr)
+	}
+}
+
+func SetupWalkTest(logger seelog.LoggerInterface, testIdGenerator chan string) {
+	Describe("Walking a subtree", func() {
+		var (
+			clientCtxt *ClientContext
+			client     *V2cClient
+			agent      *fakeWalkAgent
+			ifDescr    = ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 2}
+			varbinds   = []Varbind{
+				NewStringVarbind(SYS_DESCR_OID, "test"),
+				NewStringVarbind(append(ifDescr, 1), "lo"),
+				NewStringVarbind(append(ifDescr, 2), "eth0"),
+				NewStringVarbind(append(ifDescr, 3), "eth1"),
+				NewStringVarbind(append(ifDescr, 4), "eth2"),
+				NewIntegerVarbind(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 3, 1}, 24),
+			}
+			loopAfter ObjectIdentifier
+			collected []Varbind
+			collect   = func(vb Varbind) error {
+				collected = append(collected, vb)
+				return nil
+			}
+		)
+		BeforeEach(func() {
+			loopAfter = nil
+			clientCtxt = NewClientContext(<-testIdGenerator, 100, logger)
+			client, _ = clientCtxt.NewV2cClientWithPort("public", "localhost", 2163)
+			client.TimeoutSeconds = 1
+			client.Retries = 0
+			collected = nil
+		})
+		JustBeforeEach(func() {
+			agent = newFakeWalkAgent(2163, varbinds, loopAfter, clientCtxt)
+		})
+		AfterEach(func() {
+			agent.conn.Close()
+			clientCtxt.Shutdown()
+		})
+
+		It("should return the whole subtree using GetNext", func() {
+			Ω(client.Walk(ifDescr, collect)).Should(BeNil())
+			Ω(collected).Should(Equal(varbinds[1:5]))
+			Ω(clientCtxt.GetStat(StatType_REQUESTS_SENT, 0)).Should(Equal(5))
+		})
+		It("should return the whole subtree using GetBulk", func() {
+			Ω(client.BulkWalk(ifDescr, 3, collect)).Should(BeNil())
+			Ω(collected).Should(Equal(varbinds[1:5]))
+			Ω(clientCtxt.GetStat(StatType_REQUESTS_SENT, 0)).Should(Equal(2))
+		})
+		It("should stop at endOfMibView", func() {
+			Ω(client.BulkWalk(ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 3}, 10, collect)).Should(BeNil())
+			Ω(collected).Should(Equal(varbinds[5:]))
+		})
+		Describe("with an agent that loops", func() {
+			BeforeEach(func() {
+				loopAfter = append(ifDescr, 2)
+			})
+			It("should detect oids that don't increase", func() {
+				err := client.Walk(ObjectIdentifier{1, 3, 6, 1}, collect)
+				Ω(err).Should(BeAssignableToTypeOf(OidNotIncreasingError{}))
+				Ω(collected).Should(Equal(varbinds[0:3]))
+			})
+		})
+		It("should stop when the callback fails", func() {
+			callbackErr := errors.New("stop")
+			err := client.BulkWalk(ifDescr, 10, func(vb Varbind) error {
+				collected = append(collected, vb)
+				return callbackErr
+			})
+			Ω(err).Should(Equal(callbackErr))
+			Ω(collected).Should(HaveLen(1))
+		})
+		It("should be possible using an iterator", func() {
+			walker := client.NewBulkWalker(ifDescr, 2)
+			for walker.Next() {
+				collected = append(collected, walker.Varbind())
+			}
+			Ω(walker.Err()).Should(BeNil())
+			Ω(collected).Should(Equal(varbinds[1:5]))
+		})
+		It("should report timeouts", func() {
+			agent.conn.Close()
+			err := client.Walk(ifDescr, collect)
+			Ω(err).Should(BeAssignableToTypeOf(TimeoutError{}))
+		})
+	})
+}